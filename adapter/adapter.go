@@ -39,6 +39,142 @@ type Adapter interface {
 	Name() string
 }
 
+// GeneratedInserter is an optional interface for adapters that can report values
+// generated by the data source during insert (auto-increment IDs, server timestamps).
+// The mapper prefers it over Insert when an adapter implements it.
+type GeneratedInserter interface {
+	// InsertGenerated creates new objects like Insert and returns, for each object,
+	// the generated values keyed by data field. The result is aligned with objects;
+	// an entry may be nil when nothing was generated for that object.
+	InsertGenerated(ctx context.Context, op *Operation, objects []interface{}) ([]map[string]interface{}, error)
+}
+
+// Upserter is an optional interface for adapters that can insert or update
+// objects in one atomic step per object. Objects that do not exist are inserted,
+// others are updated. Without it the mapper inserts each object and updates it
+// when the insert fails with ErrConflict, so adapters should report duplicate
+// inserts with that code.
+type Upserter interface {
+	// Upsert creates or updates objects. The objects are given like for Insert.
+	Upsert(ctx context.Context, op *Operation, objects []interface{}) error
+}
+
+// Counter is an optional interface for adapters that can count the results of
+// a multi-result fetch without returning them. op.Type is OpCount or OpExists;
+// for OpExists adapters may stop at the first result and return 1.
+type Counter interface {
+	// Count returns the number of results for the operation and parameters,
+	// applying op.Criteria when set.
+	Count(ctx context.Context, op *Operation, params map[string]interface{}) (int, error)
+}
+
+// Transactional is an optional interface for adapters that can group writes
+// into an atomic unit of work.
+type Transactional interface {
+	// Begin starts a new transaction. The returned transaction must be finished
+	// with exactly one call to Commit or Rollback.
+	Begin(ctx context.Context) (Transaction, error)
+}
+
+// Transaction is a unit of work begun on a Transactional adapter.
+// Its data methods behave like the adapter's, but writes only become visible
+// to others when Commit succeeds. Reads observe the transaction's own writes.
+type Transaction interface {
+	// Fetch retrieves objects like Adapter.Fetch.
+	Fetch(ctx context.Context, op *Operation, params map[string]interface{}) ([]interface{}, error)
+
+	// Insert creates new objects like Adapter.Insert.
+	Insert(ctx context.Context, op *Operation, objects []interface{}) error
+
+	// Update modifies existing objects like Adapter.Update.
+	Update(ctx context.Context, op *Operation, objects []interface{}) error
+
+	// Delete removes objects like Adapter.Delete.
+	Delete(ctx context.Context, op *Operation, identifiers []interface{}) error
+
+	// Commit applies all writes of the transaction. Adapters guarantee at least
+	// that each record is written atomically; when they cannot apply the writes
+	// all-or-nothing, a commit failing partway reports in its error which writes
+	// were already applied.
+	// A transaction whose writes conflict with concurrent changes fails with ErrConflict.
+	Commit(ctx context.Context) error
+
+	// Rollback discards all writes of the transaction.
+	Rollback(ctx context.Context) error
+}
+
+// ActionExecutor is an optional interface for transactions that run custom actions
+// within the transaction, observing its writes. Inside a transaction, the mapper
+// runs actions on adapters whose transactions do not implement it directly.
+type ActionExecutor interface {
+	// Execute runs a custom action like Adapter.Execute.
+	Execute(ctx context.Context, action *Action, params map[string]interface{}) (interface{}, error)
+}
+
+// Streamer is an optional interface for adapters that can return fetch results
+// one at a time instead of materializing them all. The mapper uses it for
+// FetchIter and falls back to Fetch for adapters that do not implement it.
+type Streamer interface {
+	// Stream starts a multi-result fetch and returns a cursor over its results.
+	// The caller must Close the cursor.
+	Stream(ctx context.Context, op *Operation, params map[string]interface{}) (Cursor, error)
+}
+
+// Cursor iterates over fetch results row by row.
+//
+//	for cur.Next(ctx) {
+//		row := cur.Value()
+//	}
+//	if err := cur.Err(); err != nil { ... }
+type Cursor interface {
+	// Next advances to the next result. It returns false when the results are
+	// exhausted, an error occurred or ctx was cancelled; Err tells them apart.
+	Next(ctx context.Context) bool
+
+	// Value returns the current result, normally a map[string]interface{}.
+	Value() interface{}
+
+	// Err returns the error that stopped iteration, if any.
+	Err() error
+
+	// Close releases the resources held by the cursor. It is safe to call more than once.
+	Close() error
+}
+
+// Paginator is an optional interface for adapters that can page results natively.
+// The mapper calls FetchPage for multi-result fetches with Operation.Page set and
+// pages Fetch results in memory for adapters that do not implement it.
+type Paginator interface {
+	// FetchPage retrieves the page of results described by op.Page.
+	FetchPage(ctx context.Context, op *Operation, params map[string]interface{}) ([]interface{}, *PageInfo, error)
+}
+
+// Pagination describes one page of a multi-result fetch.
+type Pagination struct {
+	// Limit is the maximum number of results to return; 0 means no limit.
+	Limit int
+
+	// Offset is the number of results to skip. It is ignored when Cursor is set.
+	Offset int
+
+	// Cursor is an opaque continuation token taken from PageInfo.NextCursor.
+	// Cursors are only valid for the mapping and parameters they were issued for.
+	Cursor string
+
+	// WithTotal requests the total number of results across all pages.
+	WithTotal bool
+}
+
+// PageInfo describes the page returned by a paged fetch.
+type PageInfo struct {
+	// NextCursor continues after the returned page; empty on the last page.
+	NextCursor string
+
+	// Total is the number of results across all pages, or -1 when it was
+	// not requested or the adapter cannot count.
+	Total int
+}
+
 // OperationType represents the type of database operation.
 type OperationType string
 
@@ -55,6 +191,16 @@ const (
 	// OpDelete removes data (DELETE in SQL, file delete, API DELETE).
 	OpDelete OperationType = "delete"
 
+	// OpUpsert creates data or replaces existing data (INSERT ... ON CONFLICT in SQL,
+	// file write, API PUT).
+	OpUpsert OperationType = "upsert"
+
+	// OpCount counts the results of a fetch (SELECT COUNT(*) in SQL, file glob).
+	OpCount OperationType = "count"
+
+	// OpExists checks whether a fetch has any result (SELECT EXISTS in SQL).
+	OpExists OperationType = "exists"
+
 	// OpAction executes custom operations (stored procedures, complex queries).
 	OpAction OperationType = "action"
 )
@@ -80,12 +226,30 @@ type Operation struct {
 	// Condition specifies conditional fields (e.g., optimistic locking version).
 	Condition []PropertyMapping
 
+	// ConditionValues holds, for each object of an update, the expected stored values
+	// of the Condition fields keyed by data field. It is aligned with the objects slice.
+	// Adapters must reject an object whose stored values differ with ErrConflict.
+	ConditionValues []map[string]interface{}
+
+	// Partial marks an update whose objects carry only the fields to change
+	// (along with the identifier fields). Adapters must keep the other stored fields.
+	// Properties then lists only the fields sent, though each object may carry a
+	// subset of them (e.g. for the SET clause of one row).
+	Partial bool
+
 	// Bulk indicates whether this is a bulk operation (multiple objects).
 	Bulk bool
 
 	// Multi indicates whether to return multiple results (for fetch).
 	Multi bool
 
+	// Page limits a multi-result fetch to one page (nil fetches all results).
+	Page *Pagination
+
+	// Criteria filters fetch results by data field. It is only set for adapters
+	// that report native support through CriteriaSupporter.
+	Criteria *Criterion
+
 	// Source is the source name (used for CQRS pattern).
 	Source string
 
@@ -111,6 +275,11 @@ type PropertyMapping struct {
 	Generated bool
 }
 
+// TypeInteger is the type hint of integer fields. The mapper sets it on generated
+// mappings without a hint whose object field is an integer, so adapters know to
+// generate a number rather than a string for them.
+const TypeInteger = "integer"
+
 // Action represents a custom action (stored procedure, complex query, etc.).
 type Action struct {
 	// Name is the action identifier.
@@ -165,6 +334,9 @@ var (
 	// ErrConnection indicates a connection failure to the data source.
 	ErrConnection = &AdapterError{Code: "CONNECTION", Message: "connection failed"}
 
+	// ErrTimeout indicates the data source did not answer in time.
+	ErrTimeout = &AdapterError{Code: "TIMEOUT", Message: "operation timed out"}
+
 	// ErrAdapter indicates a general adapter error.
 	ErrAdapter = &AdapterError{Code: "ADAPTER", Message: "adapter error"}
 
@@ -173,6 +345,10 @@ var (
 
 	// ErrConflict indicates a conflict (e.g., optimistic locking version mismatch).
 	ErrConflict = &AdapterError{Code: "CONFLICT", Message: "conflict detected"}
+
+	// ErrCircuitOpen indicates a call refused without reaching the data source
+	// because the source's circuit breaker is open.
+	ErrCircuitOpen = &AdapterError{Code: "CIRCUIT_OPEN", Message: "circuit breaker is open"}
 )
 
 // AdapterError represents an error from an adapter.
@@ -202,6 +378,14 @@ func (e *AdapterError) Unwrap() error {
 	return e.Cause
 }
 
+// Is reports whether target is an AdapterError with the same code.
+// This lets errors.Is match a detailed error against the predefined ones,
+// e.g. errors.Is(NewAdapterError("CONFLICT", "version mismatch", nil), ErrConflict).
+func (e *AdapterError) Is(target error) bool {
+	t, ok := target.(*AdapterError)
+	return ok && t.Code == e.Code
+}
+
 // NewAdapterError creates a new AdapterError with a cause.
 func NewAdapterError(code, message string, cause error) *AdapterError {
 	return &AdapterError{
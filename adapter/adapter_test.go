@@ -2,6 +2,7 @@ package adapter
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -105,6 +106,8 @@ func TestPredefinedErrors(t *testing.T) {
 		{"ErrNotFound", ErrNotFound, "NOT_FOUND"},
 		{"ErrValidation", ErrValidation, "VALIDATION"},
 		{"ErrConnection", ErrConnection, "CONNECTION"},
+		{"ErrTimeout", ErrTimeout, "TIMEOUT"},
+		{"ErrCircuitOpen", ErrCircuitOpen, "CIRCUIT_OPEN"},
 		{"ErrAdapter", ErrAdapter, "ADAPTER"},
 		{"ErrConfiguration", ErrConfiguration, "CONFIGURATION"},
 		{"ErrConflict", ErrConflict, "CONFLICT"},
@@ -122,6 +125,21 @@ func TestPredefinedErrors(t *testing.T) {
 	}
 }
 
+func TestAdapterError_Is(t *testing.T) {
+	detailed := NewAdapterError("CONFLICT", "version mismatch for users/1.json", nil)
+	if !errors.Is(detailed, ErrConflict) {
+		t.Error("errors.Is should match errors with the same code")
+	}
+	if errors.Is(detailed, ErrNotFound) {
+		t.Error("errors.Is should not match errors with a different code")
+	}
+
+	wrapped := fmt.Errorf("update failed: %w", detailed)
+	if !errors.Is(wrapped, ErrConflict) {
+		t.Error("errors.Is should match through wrapping")
+	}
+}
+
 func TestOperation_Structure(t *testing.T) {
 	// Test that Operation struct can be created with all fields
 	op := &Operation{
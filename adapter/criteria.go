@@ -0,0 +1,306 @@
+package adapter
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CriteriaSupporter is an optional interface for adapters that evaluate criteria
+// natively (e.g. by translating them to a WHERE clause). The mapper sets
+// Operation.Criteria only when SupportsCriteria reports true and otherwise
+// filters the fetched results in memory with Criterion.Match.
+type CriteriaSupporter interface {
+	// SupportsCriteria reports whether the adapter can evaluate c natively.
+	SupportsCriteria(c Criterion) bool
+}
+
+// CriteriaScanner is an optional interface for CriteriaSupporter adapters that
+// evaluate criteria by reading every record of an operation rather than through
+// an index. The mapper then looks up keys that are fetch parameters one by one
+// instead of with a single in-criteria fetch.
+type CriteriaScanner interface {
+	// ScansCriteria reports whether evaluating c natively reads every record.
+	ScansCriteria(c Criterion) bool
+}
+
+// Operator is a criteria operator.
+type Operator string
+
+const (
+	// OperatorEq matches values equal to Value.
+	OperatorEq Operator = "eq"
+
+	// OperatorNe matches values not equal to Value.
+	OperatorNe Operator = "ne"
+
+	// OperatorLt matches values less than Value.
+	OperatorLt Operator = "lt"
+
+	// OperatorGt matches values greater than Value.
+	OperatorGt Operator = "gt"
+
+	// OperatorIn matches values equal to one of the values in Value.
+	OperatorIn Operator = "in"
+
+	// OperatorLike matches string values against a SQL LIKE pattern
+	// (% matches any sequence, _ any single character).
+	OperatorLike Operator = "like"
+
+	// OperatorNull matches null or missing values.
+	OperatorNull Operator = "null"
+
+	// OperatorAnd matches when all Criteria match.
+	OperatorAnd Operator = "and"
+
+	// OperatorOr matches when any of the Criteria match.
+	OperatorOr Operator = "or"
+
+	// OperatorNot matches when its single criterion does not match.
+	OperatorNot Operator = "not"
+)
+
+// Criterion is an adapter-agnostic filter condition.
+// Comparison criteria use Field and Value; and, or and not combine Criteria.
+// Field names are object field names when passed to the mapper and are
+// translated to data field names before reaching an adapter.
+type Criterion struct {
+	// Op is the criterion operator.
+	Op Operator
+
+	// Field is the field compared by comparison operators.
+	Field string
+
+	// Value is the operand of comparison operators; a []interface{} for in.
+	Value interface{}
+
+	// Criteria are the operands of and, or and not.
+	Criteria []Criterion
+}
+
+// Eq matches records whose field equals value.
+func Eq(field string, value interface{}) Criterion {
+	return Criterion{Op: OperatorEq, Field: field, Value: value}
+}
+
+// Ne matches records whose field does not equal value.
+func Ne(field string, value interface{}) Criterion {
+	return Criterion{Op: OperatorNe, Field: field, Value: value}
+}
+
+// Lt matches records whose field is less than value.
+func Lt(field string, value interface{}) Criterion {
+	return Criterion{Op: OperatorLt, Field: field, Value: value}
+}
+
+// Gt matches records whose field is greater than value.
+func Gt(field string, value interface{}) Criterion {
+	return Criterion{Op: OperatorGt, Field: field, Value: value}
+}
+
+// In matches records whose field equals one of values.
+func In(field string, values ...interface{}) Criterion {
+	return Criterion{Op: OperatorIn, Field: field, Value: values}
+}
+
+// Like matches records whose field matches a SQL LIKE pattern.
+func Like(field, pattern string) Criterion {
+	return Criterion{Op: OperatorLike, Field: field, Value: pattern}
+}
+
+// IsNull matches records whose field is null or missing.
+func IsNull(field string) Criterion {
+	return Criterion{Op: OperatorNull, Field: field}
+}
+
+// And matches records matching all criteria.
+func And(criteria ...Criterion) Criterion {
+	return Criterion{Op: OperatorAnd, Criteria: criteria}
+}
+
+// Or matches records matching any of the criteria.
+func Or(criteria ...Criterion) Criterion {
+	return Criterion{Op: OperatorOr, Criteria: criteria}
+}
+
+// Not matches records not matching c.
+func Not(c Criterion) Criterion {
+	return Criterion{Op: OperatorNot, Criteria: []Criterion{c}}
+}
+
+// Validate checks that the criterion is well formed.
+func (c Criterion) Validate() error {
+	switch c.Op {
+	case OperatorEq, OperatorNe, OperatorLt, OperatorGt, OperatorNull:
+		if c.Field == "" {
+			return fmt.Errorf("%s criterion requires a field", c.Op)
+		}
+	case OperatorIn:
+		if c.Field == "" {
+			return fmt.Errorf("in criterion requires a field")
+		}
+		if _, ok := c.Value.([]interface{}); !ok {
+			return fmt.Errorf("in criterion requires a list of values, got %T", c.Value)
+		}
+	case OperatorLike:
+		if c.Field == "" {
+			return fmt.Errorf("like criterion requires a field")
+		}
+		if _, ok := c.Value.(string); !ok {
+			return fmt.Errorf("like criterion requires a string pattern, got %T", c.Value)
+		}
+	case OperatorAnd, OperatorOr:
+		for _, sub := range c.Criteria {
+			if err := sub.Validate(); err != nil {
+				return err
+			}
+		}
+	case OperatorNot:
+		if len(c.Criteria) != 1 {
+			return fmt.Errorf("not criterion requires exactly one operand, got %d", len(c.Criteria))
+		}
+		return c.Criteria[0].Validate()
+	default:
+		return fmt.Errorf("unknown criteria operator '%s'", c.Op)
+	}
+	return nil
+}
+
+// Match evaluates the criterion against a data record keyed by data field.
+// Numbers are compared by value regardless of their Go type, strings and times
+// by their natural order. Ordered comparisons between unrelated types never match.
+func (c Criterion) Match(data map[string]interface{}) bool {
+	switch c.Op {
+	case OperatorEq:
+		return valuesEqual(data[c.Field], c.Value)
+	case OperatorNe:
+		return !valuesEqual(data[c.Field], c.Value)
+	case OperatorLt:
+		cmp, ok := compareValues(data[c.Field], c.Value)
+		return ok && cmp < 0
+	case OperatorGt:
+		cmp, ok := compareValues(data[c.Field], c.Value)
+		return ok && cmp > 0
+	case OperatorIn:
+		values, _ := c.Value.([]interface{})
+		for _, value := range values {
+			if valuesEqual(data[c.Field], value) {
+				return true
+			}
+		}
+		return false
+	case OperatorLike:
+		value, exists := data[c.Field]
+		if !exists || value == nil {
+			return false
+		}
+		pattern, _ := c.Value.(string)
+		return likePattern(pattern).MatchString(fmt.Sprint(value))
+	case OperatorNull:
+		return data[c.Field] == nil
+	case OperatorAnd:
+		for _, sub := range c.Criteria {
+			if !sub.Match(data) {
+				return false
+			}
+		}
+		return true
+	case OperatorOr:
+		for _, sub := range c.Criteria {
+			if sub.Match(data) {
+				return true
+			}
+		}
+		return false
+	case OperatorNot:
+		return len(c.Criteria) == 1 && !c.Criteria[0].Match(data)
+	default:
+		return false
+	}
+}
+
+// valuesEqual compares two values as Match does.
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if cmp, ok := compareValues(a, b); ok {
+		return cmp == 0
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// compareValues orders two numbers, strings or times.
+// It reports false when the values cannot be ordered against each other.
+func compareValues(a, b interface{}) (int, bool) {
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			switch {
+			case af < bf:
+				return -1, true
+			case af > bf:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+		return 0, false
+	}
+
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), true
+		}
+		if bv, ok := b.(time.Time); ok {
+			if at, err := time.Parse(time.RFC3339, av); err == nil {
+				return at.Compare(bv), true
+			}
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return av.Compare(bv), true
+		}
+		if bv, ok := b.(string); ok {
+			if bt, err := time.Parse(time.RFC3339, bv); err == nil {
+				return av.Compare(bt), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// toFloat converts a Go number to float64.
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// likePattern compiles a SQL LIKE pattern to an anchored regular expression.
+func likePattern(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^(?s)")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			expr.WriteString(".*")
+		case '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
@@ -0,0 +1,78 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCriterion_Match(t *testing.T) {
+	data := map[string]interface{}{
+		"name":       "Alice",
+		"age":        float64(30),
+		"status":     "active",
+		"created_at": "2026-01-02T03:04:05Z",
+	}
+
+	tests := []struct {
+		name      string
+		criterion Criterion
+		want      bool
+	}{
+		{"eq string", Eq("name", "Alice"), true},
+		{"eq number across types", Eq("age", 30), true},
+		{"eq missing field", Eq("email", "x"), false},
+		{"eq nil on missing field", Eq("email", nil), true},
+		{"ne", Ne("status", "deleted"), true},
+		{"lt", Lt("age", 40), true},
+		{"gt", Gt("age", 40), false},
+		{"gt string", Gt("name", "Aaron"), true},
+		{"gt time", Gt("created_at", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)), true},
+		{"lt mismatched types", Lt("name", 5), false},
+		{"null on missing field", IsNull("deleted_at"), true},
+		{"null on set field", IsNull("name"), false},
+		{"in", In("status", "active", "pending"), true},
+		{"in miss", In("status", "deleted"), false},
+		{"like prefix", Like("name", "Al%"), true},
+		{"like single char", Like("name", "_lice"), true},
+		{"like escapes regexp", Like("name", "A.*"), false},
+		{"and", And(Eq("name", "Alice"), Gt("age", 18)), true},
+		{"and miss", And(Eq("name", "Alice"), Gt("age", 40)), false},
+		{"or", Or(Eq("name", "Bob"), Eq("status", "active")), true},
+		{"not", Not(Eq("status", "deleted")), true},
+		{"empty and", And(), true},
+		{"empty or", Or(), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.criterion.Match(data); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCriterion_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		criterion Criterion
+		wantErr   bool
+	}{
+		{"valid", And(Eq("a", 1), Not(In("b", 1, 2))), false},
+		{"missing field", Eq("", 1), true},
+		{"null without field", IsNull(""), true},
+		{"in without list", Criterion{Op: OperatorIn, Field: "a", Value: 1}, true},
+		{"like without string", Criterion{Op: OperatorLike, Field: "a", Value: 1}, true},
+		{"not with two operands", Criterion{Op: OperatorNot, Criteria: []Criterion{Eq("a", 1), Eq("b", 2)}}, true},
+		{"nested invalid", Or(Eq("a", 1), Criterion{Op: "between"}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.criterion.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
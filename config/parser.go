@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -174,6 +176,15 @@ func (p *Parser) GetMapping(fullyQualifiedID string) (*Mapping, *Config, error)
 	return &mapping, cfg, nil
 }
 
+// QualifyMappingID returns the fully qualified form of a mapping ID referenced
+// from namespace: IDs without a namespace are taken to be in namespace.
+func QualifyMappingID(namespace, mappingID string) string {
+	if strings.Contains(mappingID, ".") {
+		return mappingID
+	}
+	return namespace + "." + mappingID
+}
+
 // GetAllNamespaces returns all loaded namespace names.
 func (p *Parser) GetAllNamespaces() []string {
 	namespaces := make([]string, 0, len(p.configs))
@@ -183,6 +194,12 @@ func (p *Parser) GetAllNamespaces() []string {
 	return namespaces
 }
 
+// Sanitize removes credentials from a message (for logging).
+// See CredentialResolver.Sanitize.
+func (p *Parser) Sanitize(message string) string {
+	return p.credResolver.Sanitize(message)
+}
+
 // validateConfig performs basic validation on a configuration.
 func (p *Parser) validateConfig(cfg *Config) error {
 	if cfg.Namespace == "" {
@@ -201,6 +218,19 @@ func (p *Parser) validateConfig(cfg *Config) error {
 		return fmt.Errorf("at least one mapping is required")
 	}
 
+	// Validate source retry policies, circuit breakers and timeouts
+	for sourceID, source := range cfg.Sources {
+		if err := validateRetry(source.Retry); err != nil {
+			return fmt.Errorf("source '%s': %w", sourceID, err)
+		}
+		if err := validateCircuitBreaker(source.CircuitBreaker); err != nil {
+			return fmt.Errorf("source '%s': %w", sourceID, err)
+		}
+		if err := validateTimeout(source.Timeout); err != nil {
+			return fmt.Errorf("source '%s': %w", sourceID, err)
+		}
+	}
+
 	// Validate each mapping
 	for mappingID, mapping := range cfg.Mappings {
 		if mapping.Object == "" {
@@ -214,11 +244,180 @@ func (p *Parser) validateConfig(cfg *Config) error {
 		if !hasDefaultSource && !hasOperations {
 			return fmt.Errorf("mapping '%s': must have either a default source or operations/actions", mappingID)
 		}
+
+		// Validate relations
+		for name, relation := range mapping.Relations {
+			switch relation.Type {
+			case RelationHasOne, RelationHasMany, RelationBelongsTo:
+			default:
+				return fmt.Errorf("mapping '%s', relation '%s': invalid type '%s' (use has_one, has_many or belongs_to)",
+					mappingID, name, relation.Type)
+			}
+			if relation.Mapping == "" || relation.LocalKey == "" || relation.ForeignKey == "" {
+				return fmt.Errorf("mapping '%s', relation '%s': mapping, local_key and foreign_key are required", mappingID, name)
+			}
+		}
+
+		// Validate soft delete
+		if sd := mapping.SoftDelete; sd != nil {
+			if sd.Field == "" {
+				return fmt.Errorf("mapping '%s': soft_delete field is required", mappingID)
+			}
+			if sd.Mode != "" && sd.Mode != SoftDeleteTimestamp && sd.Mode != SoftDeleteFlag {
+				return fmt.Errorf("mapping '%s': invalid soft_delete mode '%s' (use timestamp or flag)", mappingID, sd.Mode)
+			}
+		}
+
+		// Validate audit
+		if mapping.Audit != nil && (mapping.Audit.Source == "" || mapping.Audit.Statement == "") {
+			return fmt.Errorf("mapping '%s': audit source and statement are required", mappingID)
+		}
+
+		// Validate action timeouts
+		for actionName, action := range mapping.Actions {
+			if err := validateTimeout(action.Timeout); err != nil {
+				return fmt.Errorf("mapping '%s', action '%s': %w", mappingID, actionName, err)
+			}
+		}
+
+		// Validate after action failure policies
+		for opName, op := range mapping.Operations {
+			for i, after := range op.After {
+				if after.OnError != "" && after.OnError != "fail" && after.OnError != "report" {
+					return fmt.Errorf("mapping '%s', operation '%s', after[%d]: invalid on_error '%s' (use fail or report)",
+						mappingID, opName, i, after.OnError)
+				}
+				if after.Mapping != "" && after.Action != "invalidate" {
+					return fmt.Errorf("mapping '%s', operation '%s', after[%d]: mapping is only supported by invalidate actions",
+						mappingID, opName, i)
+				}
+			}
+
+			if err := validateCache(op.Cache, opName); err != nil {
+				return fmt.Errorf("mapping '%s', operation '%s': %w", mappingID, opName, err)
+			}
+
+			if err := validateTimeout(op.Timeout); err != nil {
+				return fmt.Errorf("mapping '%s', operation '%s': %w", mappingID, opName, err)
+			}
+
+			for _, prop := range op.Properties {
+				if err := validateRules(prop.Validate); err != nil {
+					return fmt.Errorf("mapping '%s', operation '%s', property '%s': %w", mappingID, opName, prop.Object, err)
+				}
+			}
+		}
 	}
 
 	return nil
 }
 
+// validateRetry checks the retry policy of a source.
+func validateRetry(retry *RetryConfig) error {
+	if retry == nil {
+		return nil
+	}
+	if retry.MaxAttempts < 0 {
+		return fmt.Errorf("retry max_attempts must be non-negative, got %d", retry.MaxAttempts)
+	}
+	if retry.Jitter < 0 || retry.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1, got %v", retry.Jitter)
+	}
+	for name, value := range map[string]string{"base_backoff": retry.BaseBackoff, "max_backoff": retry.MaxBackoff} {
+		if value == "" {
+			continue
+		}
+		backoff, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid retry %s '%s': %w", name, value, err)
+		}
+		if backoff < 0 {
+			return fmt.Errorf("retry %s must not be negative, got '%s'", name, value)
+		}
+	}
+	return nil
+}
+
+// validateCircuitBreaker checks the circuit breaker settings of a source.
+func validateCircuitBreaker(breaker *CircuitBreakerConfig) error {
+	if breaker == nil {
+		return nil
+	}
+	if breaker.FailureThreshold < 0 || breaker.HalfOpenRequests < 0 {
+		return fmt.Errorf("circuit breaker thresholds must be non-negative")
+	}
+	if breaker.OpenTimeout != "" {
+		timeout, err := time.ParseDuration(breaker.OpenTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid circuit breaker open_timeout '%s': %w", breaker.OpenTimeout, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("circuit breaker open_timeout must be positive, got '%s'", breaker.OpenTimeout)
+		}
+	}
+	return nil
+}
+
+// validateRules checks that validation rules are consistent and their pattern compiles.
+func validateRules(rules *ValidationRules) error {
+	if rules == nil {
+		return nil
+	}
+	if rules.Min != nil && rules.Max != nil && *rules.Min > *rules.Max {
+		return fmt.Errorf("validation min %v is greater than max %v", *rules.Min, *rules.Max)
+	}
+	if (rules.MinLength != nil && *rules.MinLength < 0) || (rules.MaxLength != nil && *rules.MaxLength < 0) {
+		return fmt.Errorf("validation lengths must be non-negative")
+	}
+	if rules.MinLength != nil && rules.MaxLength != nil && *rules.MinLength > *rules.MaxLength {
+		return fmt.Errorf("validation min_length %d is greater than max_length %d", *rules.MinLength, *rules.MaxLength)
+	}
+	if rules.Pattern != "" {
+		if _, err := regexp.Compile(rules.Pattern); err != nil {
+			return fmt.Errorf("invalid validation pattern '%s': %w", rules.Pattern, err)
+		}
+	}
+	return nil
+}
+
+// validateTimeout checks an optional timeout duration.
+func validateTimeout(timeout string) error {
+	if timeout == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout '%s': %w", timeout, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("timeout must be positive, got '%s'", timeout)
+	}
+	return nil
+}
+
+// validateCache checks the query cache settings of an operation.
+func validateCache(cache *CacheConfig, opName string) error {
+	if cache == nil {
+		return nil
+	}
+	if opName != "fetch" {
+		return fmt.Errorf("cache is only supported on fetch operations")
+	}
+	if cache.TTL != "" {
+		ttl, err := time.ParseDuration(cache.TTL)
+		if err != nil {
+			return fmt.Errorf("invalid cache ttl '%s': %w", cache.TTL, err)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("cache ttl must be positive, got '%s'", cache.TTL)
+		}
+	}
+	if cache.MaxEntries < 0 {
+		return fmt.Errorf("cache max_entries cannot be negative, got %d", cache.MaxEntries)
+	}
+	return nil
+}
+
 // validateSourceReferences ensures all referenced sources exist.
 func (p *Parser) validateSourceReferences(cfg *Config) error {
 	for mappingID, mapping := range cfg.Mappings {
@@ -246,7 +445,7 @@ func (p *Parser) validateSourceReferences(cfg *Config) error {
 				}
 			}
 
-			// Check after action sources
+			// Check after action sources and mappings
 			for i, after := range op.After {
 				if after.Source != "" {
 					if _, exists := cfg.Sources[after.Source]; !exists {
@@ -254,6 +453,25 @@ func (p *Parser) validateSourceReferences(cfg *Config) error {
 							mappingID, opName, i, after.Source)
 					}
 				}
+				if after.Mapping != "" {
+					if _, _, err := p.GetMapping(QualifyMappingID(cfg.Namespace, after.Mapping)); err != nil {
+						return fmt.Errorf("mapping '%s', operation '%s', after[%d]: %w", mappingID, opName, i, err)
+					}
+				}
+			}
+		}
+
+		// Check audit source
+		if mapping.Audit != nil {
+			if _, exists := cfg.Sources[mapping.Audit.Source]; !exists {
+				return fmt.Errorf("mapping '%s', audit: source '%s' not defined", mappingID, mapping.Audit.Source)
+			}
+		}
+
+		// Check related mappings
+		for name, relation := range mapping.Relations {
+			if _, _, err := p.GetMapping(QualifyMappingID(cfg.Namespace, relation.Mapping)); err != nil {
+				return fmt.Errorf("mapping '%s', relation '%s': %w", mappingID, name, err)
 			}
 		}
 
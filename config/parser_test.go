@@ -567,6 +567,38 @@ mappings:
 	}
 }
 
+func TestParser_LoadFile_InvalidAfterActionPolicy(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+
+	content := `namespace: test
+version: "1.0"
+sources:
+  db1:
+    adapter: mysql
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: db1
+    operations:
+      update:
+        statement: "UPDATE users"
+        after:
+          - source: db1
+            action: invalidate
+            on_error: retry
+`
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	parser := NewParser()
+	if err := parser.LoadFile(configFile); err == nil {
+		t.Error("LoadFile() expected error for invalid after action on_error, got nil")
+	}
+}
+
 func TestParser_ValidateSourceReferences_FallbackChain(t *testing.T) {
 	tmpDir := t.TempDir()
 	configFile := filepath.Join(tmpDir, "config.yaml")
@@ -603,3 +635,438 @@ mappings:
 		t.Error("Validate() expected error for invalid fallback chain source, got nil")
 	}
 }
+
+func TestParser_LoadFile_CacheConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		cache     string
+		wantErr   bool
+	}{
+		{"valid", "fetch", "ttl: 30s\n          max_entries: 100\n          cache_misses: true", false},
+		{"no ttl", "fetch", "max_entries: 10", false},
+		{"invalid ttl", "fetch", "ttl: soon", true},
+		{"negative ttl", "fetch", "ttl: -1s", true},
+		{"negative max entries", "fetch", "max_entries: -1", true},
+		{"write operation", "update", "ttl: 30s", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			content := `namespace: test
+version: "1.0"
+sources:
+  db1:
+    adapter: mysql
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: db1
+    operations:
+      ` + tt.operation + `:
+        statement: "SELECT * FROM users"
+        cache:
+          ` + tt.cache + `
+`
+			if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+
+			err := NewParser().LoadFile(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParser_ValidateSourceReferences_AfterActionMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		after   string
+		wantErr bool
+	}{
+		{"same namespace", "action: invalidate\n            mapping: user", false},
+		{"qualified", "action: invalidate\n            mapping: test.user", false},
+		{"unknown mapping", "action: invalidate\n            mapping: missing", true},
+		{"not invalidate", "action: publish\n            mapping: user", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			content := `namespace: test
+version: "1.0"
+sources:
+  db1:
+    adapter: mysql
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: db1
+    operations:
+      update:
+        statement: "UPDATE users"
+        after:
+          - ` + tt.after + `
+`
+			if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+
+			parser := NewParser()
+			err := parser.LoadFile(configFile)
+			if err == nil {
+				err = parser.Validate()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadFile()/Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParser_Validate_Relations(t *testing.T) {
+	tests := []struct {
+		name     string
+		relation string
+		wantErr  bool
+	}{
+		{"valid", "type: has_many\n        mapping: line\n        local_key: ID\n        foreign_key: OrderID", false},
+		{"qualified mapping", "type: has_one\n        mapping: test.line\n        local_key: ID\n        foreign_key: OrderID", false},
+		{"invalid type", "type: many_to_many\n        mapping: line\n        local_key: ID\n        foreign_key: OrderID", true},
+		{"missing keys", "type: belongs_to\n        mapping: line", true},
+		{"unknown mapping", "type: has_many\n        mapping: missing\n        local_key: ID\n        foreign_key: OrderID", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			content := `namespace: test
+version: "1.0"
+sources:
+  db1:
+    adapter: mysql
+    connection: "localhost"
+mappings:
+  line:
+    object: Line
+    source: db1
+  order:
+    object: Order
+    source: db1
+    relations:
+      Lines:
+        ` + tt.relation + `
+`
+			if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+
+			parser := NewParser()
+			err := parser.LoadFile(configFile)
+			if err == nil {
+				err = parser.Validate()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadFile()/Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParser_LoadFile_ValidationRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr bool
+	}{
+		{"valid", "required: true\n              min_length: 3\n              max_length: 40\n              pattern: \"^[a-z]+$\"", false},
+		{"one of", "one_of: [draft, published]", false},
+		{"min above max", "min: 10\n              max: 1", true},
+		{"negative length", "min_length: -1", true},
+		{"length bounds swapped", "min_length: 5\n              max_length: 2", true},
+		{"invalid pattern", "pattern: \"[a-z\"", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			content := `namespace: test
+version: "1.0"
+sources:
+  db1:
+    adapter: mysql
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: db1
+    operations:
+      insert:
+        statement: "users/{id}.json"
+        properties:
+          - object: Name
+            field: name
+            validate:
+              ` + tt.rules + `
+`
+			if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+
+			parser := NewParser()
+			err := parser.LoadFile(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			mapping, _, err := parser.GetMapping("test.user")
+			if err != nil {
+				t.Fatalf("GetMapping() error = %v", err)
+			}
+			if mapping.Operations["insert"].Properties[0].Validate == nil {
+				t.Error("Validate rules should be parsed")
+			}
+		})
+	}
+}
+
+func TestParser_LoadFile_RetryConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   string
+		wantErr bool
+	}{
+		{"valid", "max_attempts: 5\n      base_backoff: 50ms\n      max_backoff: 1s\n      jitter: 0.2\n      retry_on: [CONNECTION]", false},
+		{"defaults", "jitter: 0", false},
+		{"negative attempts", "max_attempts: -1", true},
+		{"jitter out of range", "jitter: 1.5", true},
+		{"invalid backoff", "base_backoff: soon", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			content := `namespace: test
+version: "1.0"
+sources:
+  db1:
+    adapter: mysql
+    connection: "localhost"
+    retry:
+      ` + tt.retry + `
+mappings:
+  user:
+    object: User
+    source: db1
+    operations:
+      update:
+        statement: "users/{id}.json"
+        idempotent: true
+`
+			if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+
+			parser := NewParser()
+			err := parser.LoadFile(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			cfg, _ := parser.GetConfig("test")
+			if cfg.Sources["db1"].Retry == nil {
+				t.Error("Retry should be parsed")
+			}
+			if !cfg.Mappings["user"].Operations["update"].Idempotent {
+				t.Error("Idempotent should be parsed")
+			}
+		})
+	}
+}
+
+func TestParser_LoadFile_CircuitBreakerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		breaker string
+		wantErr bool
+	}{
+		{"valid", "failure_threshold: 3\n      open_timeout: 10s\n      half_open_requests: 2", false},
+		{"negative threshold", "failure_threshold: -1", true},
+		{"invalid timeout", "open_timeout: later", true},
+		{"zero timeout", "open_timeout: 0s", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			content := `namespace: test
+version: "1.0"
+sources:
+  db1:
+    adapter: mysql
+    connection: "localhost"
+    circuit_breaker:
+      ` + tt.breaker + `
+mappings:
+  user:
+    object: User
+    source: db1
+`
+			if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+
+			err := NewParser().LoadFile(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParser_LoadFile_Timeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		op      string
+		action  string
+		wantErr bool
+	}{
+		{"valid", "200ms", "50ms", "1s", false},
+		{"none", "", "", "", false},
+		{"invalid source timeout", "soon", "", "", true},
+		{"negative operation timeout", "", "-1s", "", true},
+		{"invalid action timeout", "", "", "1 second", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			content := `namespace: test
+version: "1.0"
+sources:
+  db1:
+    adapter: mysql
+    connection: "localhost"
+    timeout: "` + tt.source + `"
+mappings:
+  user:
+    object: User
+    source: db1
+    operations:
+      fetch:
+        statement: "users/{id}.json"
+        timeout: "` + tt.op + `"
+    actions:
+      reindex:
+        statement: "reindex"
+        timeout: "` + tt.action + `"
+`
+			if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+
+			err := NewParser().LoadFile(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParser_LoadFile_SoftDelete(t *testing.T) {
+	tests := []struct {
+		name       string
+		softDelete string
+		wantErr    bool
+	}{
+		{"timestamp", "field: deleted_at", false},
+		{"flag", "field: archived\n      mode: flag", false},
+		{"missing field", "mode: flag", true},
+		{"invalid mode", "field: deleted_at\n      mode: hidden", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			content := `namespace: test
+version: "1.0"
+sources:
+  db1:
+    adapter: mysql
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: db1
+    soft_delete:
+      ` + tt.softDelete + `
+`
+			if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+
+			err := NewParser().LoadFile(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParser_LoadFile_Audit(t *testing.T) {
+	tests := []struct {
+		name    string
+		audit   string
+		wantErr bool
+	}{
+		{"valid", "source: audit\n      statement: \"audit/{id}.json\"", false},
+		{"missing statement", "source: audit", true},
+		{"undefined source", "source: missing\n      statement: \"audit/{id}.json\"", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			content := `namespace: test
+version: "1.0"
+sources:
+  db1:
+    adapter: mysql
+    connection: "localhost"
+  audit:
+    adapter: filesystem
+    connection: "/var/audit"
+mappings:
+  user:
+    object: User
+    source: db1
+    audit:
+      ` + tt.audit + `
+`
+			if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+
+			parser := NewParser()
+			err := parser.LoadFile(configFile)
+			if err == nil {
+				err = parser.Validate()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadFile()/Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -33,6 +33,54 @@ type Source struct {
 
 	// Options contains adapter-specific configuration options.
 	Options map[string]interface{} `yaml:"options,omitempty" json:"options,omitempty"`
+
+	// Retry retries failed adapter calls on this source (nil disables retries).
+	Retry *RetryConfig `yaml:"retry,omitempty" json:"retry,omitempty"`
+
+	// CircuitBreaker stops calling this source while it keeps failing (nil disables it).
+	CircuitBreaker *CircuitBreakerConfig `yaml:"circuit_breaker,omitempty" json:"circuit_breaker,omitempty"`
+
+	// Timeout is the default timeout of adapter calls on this source, retries
+	// included (duration string, e.g. "200ms"). It only applies to operations and
+	// actions without a timeout of their own; streaming fetches are not bounded by it.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// CircuitBreakerConfig configures the circuit breaker of a source. After
+// FailureThreshold consecutive failures the circuit opens and calls fail fast;
+// once OpenTimeout has passed, HalfOpenRequests trial calls are let through and
+// the circuit closes when they all succeed, or opens again when one fails.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit (default 5).
+	FailureThreshold int `yaml:"failure_threshold,omitempty" json:"failure_threshold,omitempty"`
+
+	// OpenTimeout is how long the circuit stays open before trial calls are let
+	// through (duration string, default "30s").
+	OpenTimeout string `yaml:"open_timeout,omitempty" json:"open_timeout,omitempty"`
+
+	// HalfOpenRequests is the number of trial calls of a half-open circuit (default 1).
+	HalfOpenRequests int `yaml:"half_open_requests,omitempty" json:"half_open_requests,omitempty"`
+}
+
+// RetryConfig configures retries of transient adapter failures with exponential backoff.
+// Fetches are always retried; writes and actions only when marked idempotent.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, the first one included (default 3).
+	MaxAttempts int `yaml:"max_attempts,omitempty" json:"max_attempts,omitempty"`
+
+	// BaseBackoff is the delay before the first retry, doubled for each further
+	// retry (duration string, default "100ms").
+	BaseBackoff string `yaml:"base_backoff,omitempty" json:"base_backoff,omitempty"`
+
+	// MaxBackoff caps the delay between attempts (duration string, default "2s").
+	MaxBackoff string `yaml:"max_backoff,omitempty" json:"max_backoff,omitempty"`
+
+	// Jitter is the fraction (0 to 1) of each delay that is randomized.
+	Jitter float64 `yaml:"jitter,omitempty" json:"jitter,omitempty"`
+
+	// RetryOn lists the adapter error codes that are retried
+	// (default CONNECTION and TIMEOUT).
+	RetryOn []string `yaml:"retry_on,omitempty" json:"retry_on,omitempty"`
 }
 
 // Mapping defines how a domain object maps to data operations.
@@ -44,14 +92,96 @@ type Mapping struct {
 	// Individual operations can override this.
 	Source string `yaml:"source,omitempty" json:"source,omitempty"`
 
-	// Operations defines CRUD operations for this object.
+	// Operations defines CRUD operations for this object, keyed by name:
+	// fetch, count, insert, update, upsert or delete. Counts use the fetch
+	// operation unless a count operation is defined.
 	Operations map[string]OperationConfig `yaml:"operations,omitempty" json:"operations,omitempty"`
 
 	// Actions defines custom actions (stored procedures, complex queries).
 	Actions map[string]ActionConfig `yaml:"actions,omitempty" json:"actions,omitempty"`
+
+	// Relations defines named relationships to other mappings, loaded on request.
+	Relations map[string]RelationConfig `yaml:"relations,omitempty" json:"relations,omitempty"`
+
+	// SoftDelete turns deletes into updates of a marker field (nil deletes records).
+	SoftDelete *SoftDeleteConfig `yaml:"soft_delete,omitempty" json:"soft_delete,omitempty"`
+
+	// Audit writes an audit trail of the mapping's writes (nil disables it).
+	Audit *AuditConfig `yaml:"audit,omitempty" json:"audit,omitempty"`
+}
+
+// Soft-delete modes.
+const (
+	// SoftDeleteTimestamp marks deleted records with the time of deletion;
+	// records whose field is null or missing are active.
+	SoftDeleteTimestamp = "timestamp"
+
+	// SoftDeleteFlag marks deleted records with true; records whose field is
+	// false, null or missing are active.
+	SoftDeleteFlag = "flag"
+)
+
+// SoftDeleteConfig makes the deletes of a mapping mark records instead of
+// removing them. Fetches exclude marked records unless asked otherwise.
+type SoftDeleteConfig struct {
+	// Field is the data field marking deleted records (e.g. deleted_at).
+	Field string `yaml:"field" json:"field"`
+
+	// Mode is timestamp (default) or flag.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Statement is the update statement that marks a record, receiving the
+	// identifier fields and Field. It defaults to the delete operation's statement.
+	Statement string `yaml:"statement,omitempty" json:"statement,omitempty"`
+}
+
+// AuditConfig records an audit entry on a separate source for each successful
+// insert, update and delete of a mapping.
+type AuditConfig struct {
+	// Source is the source audit entries are inserted into.
+	Source string `yaml:"source" json:"source"`
+
+	// Statement is the insert statement for entries (e.g. "audit/{id}.json").
+	Statement string `yaml:"statement" json:"statement"`
 }
 
-// OperationConfig defines configuration for a single operation (fetch, insert, update, delete).
+// Relation types.
+const (
+	// RelationHasOne links an object to a single related object holding its key.
+	RelationHasOne = "has_one"
+
+	// RelationHasMany links an object to the related objects holding its key.
+	RelationHasMany = "has_many"
+
+	// RelationBelongsTo links an object to the related object whose key it holds.
+	RelationBelongsTo = "belongs_to"
+)
+
+// RelationConfig links a mapping to another mapping.
+// Objects are related when the related object's ForeignKey field equals the
+// object's LocalKey field. For has_one and has_many the related object holds the
+// key (e.g. LocalKey ID, ForeignKey OrderID); for belongs_to the object does
+// (e.g. LocalKey CustomerID, ForeignKey ID).
+type RelationConfig struct {
+	// Type is the relation type: has_one, has_many or belongs_to.
+	Type string `yaml:"type" json:"type"`
+
+	// Mapping is the related mapping, either fully qualified ("namespace.mapping")
+	// or within the same namespace.
+	Mapping string `yaml:"mapping" json:"mapping"`
+
+	// Object is the struct field receiving the related object(s).
+	// Defaults to the relation name.
+	Object string `yaml:"object,omitempty" json:"object,omitempty"`
+
+	// LocalKey is the object field linking to the related objects.
+	LocalKey string `yaml:"local_key" json:"local_key"`
+
+	// ForeignKey is the related object field matched against LocalKey.
+	ForeignKey string `yaml:"foreign_key" json:"foreign_key"`
+}
+
+// OperationConfig defines configuration for a single operation (fetch, count, insert, update, upsert, delete).
 type OperationConfig struct {
 	// Source overrides the default source for this operation (CQRS pattern).
 	Source string `yaml:"source,omitempty" json:"source,omitempty"`
@@ -88,6 +218,35 @@ type OperationConfig struct {
 
 	// After defines actions to run after the operation (cache invalidation, etc.).
 	After []AfterActionConfig `yaml:"after,omitempty" json:"after,omitempty"`
+
+	// Cache enables the in-process query cache for a fetch operation.
+	Cache *CacheConfig `yaml:"cache,omitempty" json:"cache,omitempty"`
+
+	// Idempotent marks a write that may safely be repeated, so that it is
+	// retried according to the source's retry policy.
+	Idempotent bool `yaml:"idempotent,omitempty" json:"idempotent,omitempty"`
+
+	// Timeout bounds the operation's adapter calls (duration string, e.g. "50ms").
+	// For fetches it is the budget of the whole source chain, split across the
+	// sources still to be tried; FetchIter applies it to the whole iteration.
+	// It replaces the timeouts of the sources called.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// CacheConfig configures the in-process query cache of a fetch operation.
+// Entries are keyed by mapping ID and parameters and are dropped whenever the
+// mapping's insert, update or delete runs.
+type CacheConfig struct {
+	// TTL is how long an entry is served, as a Go duration (e.g. "30s", "5m").
+	// Entries without a TTL live until they are invalidated or evicted.
+	TTL string `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+
+	// MaxEntries bounds the number of cached queries; the least recently used
+	// entry is evicted first. Zero means unbounded.
+	MaxEntries int `yaml:"max_entries,omitempty" json:"max_entries,omitempty"`
+
+	// CacheMisses also caches not-found answers (negative caching).
+	CacheMisses bool `yaml:"cache_misses,omitempty" json:"cache_misses,omitempty"`
 }
 
 // SourceRef references a source with fallback behavior (for CQRS).
@@ -115,6 +274,31 @@ type PropertyMap struct {
 
 	// Generated indicates this field is auto-generated.
 	Generated bool `yaml:"generated,omitempty" json:"generated,omitempty"`
+
+	// Validate holds rules the value must satisfy before it is inserted or updated.
+	Validate *ValidationRules `yaml:"validate,omitempty" json:"validate,omitempty"`
+}
+
+// ValidationRules are declarative constraints on a property value, checked on the
+// mapped data of insert and update operations.
+// Rules other than Required are skipped when the value is absent (nil or an empty string).
+type ValidationRules struct {
+	// Required rejects absent values (nil or an empty string); false and 0 are valid.
+	Required bool `yaml:"required,omitempty" json:"required,omitempty"`
+
+	// Min and Max bound numeric values (inclusive).
+	Min *float64 `yaml:"min,omitempty" json:"min,omitempty"`
+	Max *float64 `yaml:"max,omitempty" json:"max,omitempty"`
+
+	// MinLength and MaxLength bound the length of strings (in characters), slices and maps.
+	MinLength *int `yaml:"min_length,omitempty" json:"min_length,omitempty"`
+	MaxLength *int `yaml:"max_length,omitempty" json:"max_length,omitempty"`
+
+	// Pattern is a regular expression string values must match.
+	Pattern string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+
+	// OneOf lists the allowed values.
+	OneOf []interface{} `yaml:"one_of,omitempty" json:"one_of,omitempty"`
 }
 
 // ResultConfig defines how to map operation results to objects.
@@ -142,6 +326,14 @@ type ActionConfig struct {
 
 	// Result defines how to map action results.
 	Result *ResultConfig `yaml:"result,omitempty" json:"result,omitempty"`
+
+	// Idempotent marks an action that may safely be repeated, so that it is
+	// retried according to the source's retry policy.
+	Idempotent bool `yaml:"idempotent,omitempty" json:"idempotent,omitempty"`
+
+	// Timeout bounds the action's adapter call (duration string, e.g. "1s"),
+	// replacing the timeout of its source.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 }
 
 // AfterActionConfig defines an action to execute after an operation.
@@ -155,8 +347,16 @@ type AfterActionConfig struct {
 	// Statement is the adapter-specific statement.
 	Statement string `yaml:"statement,omitempty" json:"statement,omitempty"`
 
+	// Mapping names a mapping whose query cache an invalidate action clears,
+	// either fully qualified ("namespace.mapping") or within the same namespace.
+	Mapping string `yaml:"mapping,omitempty" json:"mapping,omitempty"`
+
 	// Config contains additional configuration.
 	Config map[string]interface{} `yaml:"config,omitempty" json:"config,omitempty"`
+
+	// OnError overrides the mapper's after-action failure policy for this action.
+	// "fail" returns the failure to the caller, "report" only reports it.
+	OnError string `yaml:"on_error,omitempty" json:"on_error,omitempty"`
 }
 
 // CredentialsConfig represents a credentials file structure.
@@ -0,0 +1,262 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// AfterActionHandler runs an after-action hook once the primary operation has succeeded.
+// adp is the adapter resolved for the action's source (nil when no source is configured)
+// and data holds the data maps written by the primary operation, or the identifier maps
+// for deletes.
+type AfterActionHandler func(ctx context.Context, adp adapter.Adapter, action *adapter.AfterAction, data []map[string]interface{}) error
+
+// AfterActionErrorReporter receives after-action failures that do not fail the primary operation.
+type AfterActionErrorReporter func(ctx context.Context, action config.AfterActionConfig, err error)
+
+// AfterActionPolicy controls how after-action failures affect the primary operation.
+type AfterActionPolicy int
+
+const (
+	// AfterActionFail returns after-action failures to the caller. This is the default.
+	// The primary operation has already been applied when the failure is returned.
+	AfterActionFail AfterActionPolicy = iota
+
+	// AfterActionReport passes after-action failures to the reporter and lets the
+	// primary operation succeed.
+	AfterActionReport
+)
+
+// Built-in after-action types.
+const (
+	// AfterInvalidate deletes the written objects from the target source.
+	// With a mapping, it clears that mapping's query cache instead, and also
+	// deletes from the source when one is given.
+	AfterInvalidate = "invalidate"
+
+	// AfterCacheSet writes the written objects to the target source.
+	AfterCacheSet = "cache_set"
+
+	// AfterPublish executes a "publish" action on the target source for each written object.
+	AfterPublish = "publish"
+)
+
+// RegisterAfterAction registers a handler for an after-action type.
+// The actionType matches the "action" field of after blocks in configuration.
+// Registering a built-in type replaces the built-in handler.
+func (m *Mapper) RegisterAfterAction(actionType string, handler AfterActionHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.afterActions == nil {
+		m.afterActions = make(map[string]AfterActionHandler)
+	}
+	m.afterActions[actionType] = handler
+}
+
+// SetAfterActionPolicy sets the default after-action failure policy.
+// Individual after blocks can override it with on_error: fail or on_error: report.
+// reporter receives reported failures and may be nil to discard them.
+func (m *Mapper) SetAfterActionPolicy(policy AfterActionPolicy, reporter AfterActionErrorReporter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.afterPolicy = policy
+	m.afterReporter = reporter
+}
+
+// registerBuiltinAfterActions installs the built-in after-action handlers.
+func (m *Mapper) registerBuiltinAfterActions() {
+	m.RegisterAfterAction(AfterInvalidate, invalidateHandler)
+	m.RegisterAfterAction(AfterCacheSet, cacheSetHandler)
+	m.RegisterAfterAction(AfterPublish, publishHandler)
+}
+
+// executeAfterActions executes after-action hooks (cache invalidation, etc.).
+// Inside a transaction the hooks are deferred until it commits.
+func (m *Mapper) executeAfterActions(ctx context.Context, mappingID string, cfg *config.Config, opConfig *config.OperationConfig, data []map[string]interface{}) error {
+	if tx := txFromContext(ctx); tx != nil {
+		if len(opConfig.After) > 0 {
+			tx.deferAfterActions(func(ctx context.Context) error {
+				return m.executeAfterActions(ctx, mappingID, cfg, opConfig, data)
+			})
+		}
+		return nil
+	}
+
+	for _, actionConfig := range opConfig.After {
+		err := m.executeAfterAction(ctx, mappingID, cfg, opConfig, actionConfig, data)
+		if err == nil {
+			continue
+		}
+
+		m.mu.RLock()
+		policy, reporter := m.afterPolicy, m.afterReporter
+		m.mu.RUnlock()
+
+		switch actionConfig.OnError {
+		case "fail":
+			policy = AfterActionFail
+		case "report":
+			policy = AfterActionReport
+		}
+
+		if policy == AfterActionFail {
+			return err
+		}
+		if reporter != nil {
+			reporter(ctx, actionConfig, err)
+		}
+	}
+
+	return nil
+}
+
+// executeAfterAction resolves the handler and adapter for a single after-action and runs it.
+// The handler's adapter calls go through the mapper's interceptors.
+func (m *Mapper) executeAfterAction(ctx context.Context, mappingID string, cfg *config.Config, opConfig *config.OperationConfig, actionConfig config.AfterActionConfig, data []map[string]interface{}) error {
+	m.mu.RLock()
+	handler, exists := m.afterActions[actionConfig.Action]
+	m.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("no handler registered for after-action '%s'", actionConfig.Action)
+	}
+
+	action := &adapter.AfterAction{
+		Type:      actionConfig.Action,
+		Source:    actionConfig.Source,
+		Statement: actionConfig.Statement,
+		Config:    actionConfig.Config,
+	}
+	if action.Statement == "" {
+		action.Statement = opConfig.Statement
+	}
+
+	// Invalidating a mapping clears its query cache, and its source only if one is given
+	if actionConfig.Action == AfterInvalidate && actionConfig.Mapping != "" {
+		m.invalidateCache(ctx, config.QualifyMappingID(cfg.Namespace, actionConfig.Mapping))
+		if actionConfig.Source == "" {
+			return nil
+		}
+	}
+
+	var adp adapter.Adapter
+	if actionConfig.Source != "" {
+		source, exists := cfg.Sources[actionConfig.Source]
+		if !exists {
+			return fmt.Errorf("after-action '%s': source '%s' not found", actionConfig.Action, actionConfig.Source)
+		}
+
+		target, err := m.registry.GetAdapter(ctx, source, actionConfig.Source)
+		if err != nil {
+			return fmt.Errorf("after-action '%s': failed to get adapter: %w", actionConfig.Action, err)
+		}
+		adp = &interceptedAdapter{Adapter: target, mapper: m, mappingID: mappingID, sourceID: actionConfig.Source}
+	}
+
+	if err := handler(ctx, adp, action, data); err != nil {
+		return fmt.Errorf("after-action '%s' on source '%s': %w", actionConfig.Action, actionConfig.Source, err)
+	}
+	return nil
+}
+
+// invalidateHandler deletes each written object from the target source.
+// Objects that are already absent are ignored.
+func invalidateHandler(ctx context.Context, adp adapter.Adapter, action *adapter.AfterAction, data []map[string]interface{}) error {
+	if adp == nil {
+		return fmt.Errorf("invalidate requires a source")
+	}
+
+	op := &adapter.Operation{Type: adapter.OpDelete, Statement: action.Statement, Source: action.Source}
+	for _, item := range data {
+		if err := adp.Delete(ctx, op, []interface{}{item}); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheSetHandler writes each written object to the target source,
+// updating existing entries and inserting missing ones.
+func cacheSetHandler(ctx context.Context, adp adapter.Adapter, action *adapter.AfterAction, data []map[string]interface{}) error {
+	if adp == nil {
+		return fmt.Errorf("cache_set requires a source")
+	}
+
+	updateOp := &adapter.Operation{Type: adapter.OpUpdate, Statement: action.Statement, Source: action.Source}
+	insertOp := &adapter.Operation{Type: adapter.OpInsert, Statement: action.Statement, Source: action.Source}
+	for _, item := range data {
+		err := adp.Update(ctx, updateOp, []interface{}{item})
+		if isNotFound(err) {
+			err = adp.Insert(ctx, insertOp, []interface{}{item})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishHandler executes a "publish" action on the target source for each written object.
+func publishHandler(ctx context.Context, adp adapter.Adapter, action *adapter.AfterAction, data []map[string]interface{}) error {
+	if adp == nil {
+		return fmt.Errorf("publish requires a source")
+	}
+
+	publish := &adapter.Action{Name: AfterPublish, Statement: action.Statement}
+	for _, item := range data {
+		if _, err := adp.Execute(ctx, publish, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dataMaps converts mapped data objects to the form passed to after-action handlers.
+func dataMaps(objects []interface{}) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		if dataMap, ok := obj.(map[string]interface{}); ok {
+			result = append(result, dataMap)
+		}
+	}
+	return result
+}
+
+// identifierMaps converts delete identifiers to data maps keyed by data field.
+// Scalar identifiers are keyed by the first identifier (or parameter) field;
+// struct identifiers are mapped through the identifier mappings.
+func (m *Mapper) identifierMaps(opConfig *config.OperationConfig, identifiers []interface{}) []map[string]interface{} {
+	keyFields := opConfig.Identifier
+	if len(keyFields) == 0 {
+		keyFields = opConfig.Parameters
+	}
+
+	result := make([]map[string]interface{}, 0, len(identifiers))
+	for _, id := range identifiers {
+		if dataMap, ok := id.(map[string]interface{}); ok {
+			result = append(result, dataMap)
+			continue
+		}
+
+		if len(keyFields) == 0 {
+			continue
+		}
+
+		value := reflect.ValueOf(id)
+		if value.Kind() == reflect.Ptr {
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Struct {
+			if dataMap, err := m.propMap.MapFromObject(id, keyFields); err == nil {
+				result = append(result, dataMap)
+			}
+			continue
+		}
+
+		result = append(result, map[string]interface{}{keyFields[0].Field: id})
+	}
+	return result
+}
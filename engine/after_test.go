@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+const afterTestConfig = `namespace: shop
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+  cache:
+    adapter: cache
+    connection: "memory"
+mappings:
+  product:
+    object: Product
+    source: primary
+    operations:
+      insert:
+        statement: "products/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+        after:
+          - action: cache_set
+            source: cache
+      update:
+        statement: "products/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+        after:
+          - action: invalidate
+            source: cache
+            statement: "cache/products/{id}.json"
+      delete:
+        statement: "products/{id}.json"
+        identifier:
+          - object: ID
+            field: id
+        after:
+          - action: invalidate
+            source: cache
+          - action: audit
+            on_error: report
+`
+
+type afterProduct struct {
+	ID   string
+	Name string
+}
+
+func TestMapper_AfterActions_Invalidate(t *testing.T) {
+	mapper := newTestMapper(t, afterTestConfig)
+	var deleted []interface{}
+	cache := &stubAdapter{
+		deleteFn: func(ctx context.Context, op *adapter.Operation, identifiers []interface{}) error {
+			if op.Statement != "cache/products/{id}.json" {
+				t.Errorf("invalidate statement = %q", op.Statement)
+			}
+			deleted = append(deleted, identifiers...)
+			return adapter.ErrNotFound
+		},
+	}
+	registerStub(mapper, "primary", &stubAdapter{})
+	registerStub(mapper, "cache", cache)
+
+	if err := mapper.Update(context.Background(), "shop.product", &afterProduct{ID: "1", Name: "Desk"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if len(deleted) != 1 {
+		t.Fatalf("invalidated %d entries, want 1", len(deleted))
+	}
+	if data := deleted[0].(map[string]interface{}); data["id"] != "1" {
+		t.Errorf("invalidated data = %v, want id=1", data)
+	}
+}
+
+func TestMapper_AfterActions_CacheSet(t *testing.T) {
+	mapper := newTestMapper(t, afterTestConfig)
+	var inserted []interface{}
+	cache := &stubAdapter{
+		updateFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			return adapter.ErrNotFound
+		},
+		insertFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			if op.Statement != "products/{id}.json" {
+				t.Errorf("cache_set should default to the operation statement, got %q", op.Statement)
+			}
+			inserted = append(inserted, objects...)
+			return nil
+		},
+	}
+	registerStub(mapper, "primary", &stubAdapter{})
+	registerStub(mapper, "cache", cache)
+
+	if err := mapper.Insert(context.Background(), "shop.product", afterProduct{ID: "2", Name: "Lamp"}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	if cache.callCount("update") != 1 || len(inserted) != 1 {
+		t.Fatalf("cache calls = %v, want update then insert", cache.calls)
+	}
+	if data := inserted[0].(map[string]interface{}); data["name"] != "Lamp" {
+		t.Errorf("cached data = %v, want name=Lamp", data)
+	}
+}
+
+func TestMapper_AfterActions_FailurePolicy(t *testing.T) {
+	mapper := newTestMapper(t, afterTestConfig)
+	cache := &stubAdapter{
+		deleteFn: func(ctx context.Context, op *adapter.Operation, identifiers []interface{}) error {
+			return adapter.ErrConnection
+		},
+	}
+	primary := &stubAdapter{}
+	registerStub(mapper, "primary", primary)
+	registerStub(mapper, "cache", cache)
+
+	// Default policy fails the primary operation
+	err := mapper.Update(context.Background(), "shop.product", afterProduct{ID: "1"})
+	if !errors.Is(err, adapter.ErrConnection) {
+		t.Fatalf("Update() error = %v, want ErrConnection", err)
+	}
+	if primary.callCount("update") != 1 {
+		t.Error("primary update should have been applied before the after-action")
+	}
+
+	// Report policy only reports the failure
+	var reported []string
+	mapper.SetAfterActionPolicy(AfterActionReport, func(ctx context.Context, action config.AfterActionConfig, err error) {
+		reported = append(reported, action.Action)
+	})
+	if err := mapper.Update(context.Background(), "shop.product", afterProduct{ID: "1"}); err != nil {
+		t.Fatalf("Update() with report policy error = %v", err)
+	}
+	if len(reported) != 1 || reported[0] != "invalidate" {
+		t.Errorf("reported = %v, want [invalidate]", reported)
+	}
+}
+
+func TestMapper_AfterActions_CustomHandler(t *testing.T) {
+	mapper := newTestMapper(t, afterTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{})
+	registerStub(mapper, "cache", &stubAdapter{})
+
+	var reported []error
+	mapper.SetAfterActionPolicy(AfterActionFail, func(ctx context.Context, action config.AfterActionConfig, err error) {
+		reported = append(reported, err)
+	})
+
+	// No handler for "audit": on_error: report overrides the fail policy
+	if err := mapper.Delete(context.Background(), "shop.product", "7"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if len(reported) != 1 || !strings.Contains(reported[0].Error(), "no handler registered") {
+		t.Errorf("reported = %v, want missing handler error", reported)
+	}
+
+	var audited []map[string]interface{}
+	mapper.RegisterAfterAction("audit", func(ctx context.Context, adp adapter.Adapter, action *adapter.AfterAction, data []map[string]interface{}) error {
+		if adp != nil {
+			t.Error("audit has no source, adapter should be nil")
+		}
+		audited = append(audited, data...)
+		return nil
+	})
+
+	if err := mapper.Delete(context.Background(), "shop.product", []interface{}{"7", map[string]interface{}{"id": "8"}}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if len(audited) != 2 || audited[0]["id"] != "7" || audited[1]["id"] != "8" {
+		t.Errorf("audited = %v, want identifiers 7 and 8", audited)
+	}
+}
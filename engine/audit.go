@@ -0,0 +1,294 @@
+package engine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// Audit entry fields, as written to the audit source.
+const (
+	// AuditID holds a unique entry ID (16 hex characters).
+	AuditID = "id"
+
+	// AuditMapping holds the fully qualified mapping ID.
+	AuditMapping = "mapping"
+
+	// AuditOperation holds the operation type: insert, update, upsert or delete.
+	AuditOperation = "operation"
+
+	// AuditIdentifier holds the identifier values of the written record, by data field.
+	AuditIdentifier = "identifier"
+
+	// AuditChanges holds, by data field, a map with the "before" and "after"
+	// values of each changed field of an update, or the "after" values of an insert.
+	AuditChanges = "changes"
+
+	// AuditTimestamp holds the time of the write (UTC).
+	AuditTimestamp = "timestamp"
+
+	// AuditActor holds the actor set on the context with WithActor ("" if none).
+	AuditActor = "actor"
+)
+
+// actorKey is the context key of the audit actor.
+type actorKey struct{}
+
+// WithActor returns a context whose writes are recorded as made by actor in the
+// audit trail of mappings that configure one.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set with WithActor, or "".
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// AuditError is returned by a write whose data was written (or, inside a
+// transaction, committed) but whose audit entries could not be recorded. The
+// write is not undone; only its audit trail is missing. Outside a transaction the
+// write's after hooks and after-actions are not run.
+type AuditError struct {
+	// MappingID is the mapping whose write was not audited.
+	MappingID string
+
+	// Err is the error of the audit source.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *AuditError) Error() string {
+	return fmt.Sprintf("%s was written but its audit trail failed: %v", e.MappingID, e.Err)
+}
+
+// Unwrap returns the error of the audit source.
+func (e *AuditError) Unwrap() error {
+	return e.Err
+}
+
+// auditBefore reads the stored records an update is about to change, for the
+// before values of the audit trail. It returns nil when the mapping has no audit
+// or no fetch operation; records that cannot be found have a nil entry. Records
+// are read together like the keys of a BatchLoader: with one fetch selecting all
+// identifiers when the mapping has a single identity field that is not a fetch
+// parameter or that the adapter selects natively, and otherwise with a fetch per
+// record, run in parallel (one at a time inside a transaction).
+func (m *Mapper) auditBefore(ctx context.Context, mappingID string, cfg *config.Config, mapping *config.Mapping, dataObjects []interface{}) ([]map[string]interface{}, error) {
+	fetchOp, exists := mapping.Operations["fetch"]
+	if mapping.Audit == nil || !exists || len(dataObjects) == 0 {
+		return nil, nil
+	}
+	// Read the source itself, never a cached answer
+	fetchOp.Cache = nil
+
+	keyFields := identityFields(mapping)
+	before := make([]map[string]interface{}, len(dataObjects))
+
+	if len(keyFields) == 1 {
+		key := keyFields[0]
+		keys := make([]interface{}, 0, len(dataObjects))
+		for _, obj := range dataObjects {
+			if value, exists := obj.(map[string]interface{})[key.Field]; exists {
+				keys = append(keys, value)
+			}
+		}
+
+		_, byParams := keyParameter(mapping, key.Object)
+		if !byParams || m.nativeKeyCriteria(ctx, mappingID, cfg, mapping, key.Field, keys) {
+			options := newFetchOptions([]FetchOption{WithDeleted(), Where(adapter.In(key.Object, keys...))})
+			answer, err := m.fetchFromChain(ctx, mappingID, cfg, mapping, &fetchOp, true, map[string]interface{}{}, options)
+			if isNotFound(err) {
+				return before, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("audit failed to read the stored objects: %w", err)
+			}
+
+			stored := make(map[string]map[string]interface{}, len(answer.data))
+			for _, item := range answer.data {
+				if data, ok := item.(map[string]interface{}); ok {
+					stored[batchKey(data[key.Field])] = data
+				}
+			}
+			for i, obj := range dataObjects {
+				if value, exists := obj.(map[string]interface{})[key.Field]; exists {
+					before[i] = stored[batchKey(value)]
+				}
+			}
+			return before, nil
+		}
+	}
+
+	// Adapter transactions are not meant for concurrent use
+	parallelism := DefaultBatchParallelism
+	if txFromContext(ctx) != nil {
+		parallelism = 1
+	}
+	options := newFetchOptions([]FetchOption{WithDeleted()})
+	errs := make([]error, len(dataObjects))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallelism)
+	for i, obj := range dataObjects {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, data map[string]interface{}) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			params := auditIdentifier(keyFields, data)
+			answer, err := m.fetchFromChain(ctx, mappingID, cfg, mapping, &fetchOp, false, params, options)
+			if isNotFound(err) {
+				return
+			}
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if len(answer.data) > 0 {
+				before[i], _ = answer.data[0].(map[string]interface{})
+			}
+		}(i, obj.(map[string]interface{}))
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("audit failed to read object %d: %w", i, err)
+		}
+	}
+	return before, nil
+}
+
+// writeAudit records an audit entry for each written record of a mapping with an
+// audit trail. before holds the stored records of an update (see auditBefore) and
+// after the data written, or the identifiers of a delete. Entries are built with
+// the actor and time of the write; inside a transaction they are written once it
+// commits. A failing audit source is reported with an *AuditError.
+func (m *Mapper) writeAudit(ctx context.Context, mappingID string, cfg *config.Config, mapping *config.Mapping, opType adapter.OperationType, before, after []map[string]interface{}) error {
+	audit := mapping.Audit
+	if audit == nil || len(after) == 0 {
+		return nil
+	}
+
+	actor := ActorFromContext(ctx)
+	timestamp := time.Now().UTC()
+	keyFields := identityFields(mapping)
+
+	entries := make([]interface{}, len(after))
+	for i, data := range after {
+		id, err := auditEntryID()
+		if err != nil {
+			return &AuditError{MappingID: mappingID, Err: err}
+		}
+
+		entry := map[string]interface{}{
+			AuditID:        id,
+			AuditMapping:   mappingID,
+			AuditOperation: string(opType),
+			AuditTimestamp: timestamp,
+			AuditActor:     actor,
+		}
+		switch opType {
+		case adapter.OpDelete:
+			entry[AuditIdentifier] = data
+		case adapter.OpUpdate:
+			var stored map[string]interface{}
+			if i < len(before) {
+				stored = before[i]
+			}
+			entry[AuditIdentifier] = auditIdentifier(keyFields, data)
+			entry[AuditChanges] = auditChanges(stored, data)
+		default:
+			entry[AuditIdentifier] = auditIdentifier(keyFields, data)
+			entry[AuditChanges] = auditChanges(nil, data)
+		}
+		entries[i] = entry
+	}
+
+	insert := func(ctx context.Context) error {
+		if err := m.insertAudit(ctx, mappingID, cfg, audit, entries); err != nil {
+			return &AuditError{MappingID: mappingID, Err: err}
+		}
+		return nil
+	}
+	if tx := txFromContext(ctx); tx != nil {
+		tx.deferAfterActions(insert)
+		return nil
+	}
+	return insert(ctx)
+}
+
+// insertAudit inserts audit entries into the audit source through the interceptors.
+func (m *Mapper) insertAudit(ctx context.Context, mappingID string, cfg *config.Config, audit *config.AuditConfig, entries []interface{}) error {
+	source, exists := cfg.Sources[audit.Source]
+	if !exists {
+		return fmt.Errorf("audit source '%s' not found", audit.Source)
+	}
+	adp, err := m.registry.GetAdapter(ctx, source, audit.Source)
+	if err != nil {
+		return fmt.Errorf("failed to get audit adapter: %w", err)
+	}
+
+	op := &adapter.Operation{
+		Type:      adapter.OpInsert,
+		Statement: audit.Statement,
+		Bulk:      len(entries) > 1,
+		Source:    audit.Source,
+	}
+	inv := m.newInvocation(mappingID, audit.Source, op)
+	inv.Objects = entries
+	err = m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		return adp.Insert(ctx, inv.Operation, inv.Objects)
+	})
+	if err != nil {
+		return fmt.Errorf("audit insert failed: %w", err)
+	}
+	return nil
+}
+
+// auditIdentifier picks the identity fields of a data record.
+func auditIdentifier(keyFields []config.PropertyMap, data map[string]interface{}) map[string]interface{} {
+	identifier := make(map[string]interface{}, len(keyFields))
+	for _, pm := range keyFields {
+		if value, exists := data[pm.Field]; exists {
+			identifier[pm.Field] = value
+		}
+	}
+	return identifier
+}
+
+// auditChanges diffs the written data against the stored record: each written
+// field whose value differs (as compared by criteria) is listed with its before
+// and after values. Without a stored record only after values are listed.
+func auditChanges(stored, written map[string]interface{}) map[string]interface{} {
+	changes := make(map[string]interface{})
+	for field, value := range written {
+		if stored == nil {
+			changes[field] = map[string]interface{}{"after": value}
+			continue
+		}
+		if adapter.Eq(field, value).Match(stored) {
+			continue
+		}
+		changes[field] = map[string]interface{}{"before": stored[field], "after": value}
+	}
+	return changes
+}
+
+// auditEntryID returns a random entry ID.
+func auditEntryID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate audit entry ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,255 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+const auditTestConfig = `namespace: app
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+  audit:
+    adapter: audit
+    connection: "localhost"
+mappings:
+  product:
+    object: Product
+    source: primary
+    audit:
+      source: audit
+      statement: "audit/{id}.json"
+    operations:
+      fetch:
+        statement: "products/{id}.json"
+        parameters:
+          - object: ID
+            field: id
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+      insert:
+        statement: "products/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+      update:
+        statement: "products/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+      delete:
+        statement: "products/{id}.json"
+        identifier:
+          - object: ID
+            field: id
+`
+
+// auditRecorder returns an audit stub collecting the entries it receives.
+func auditRecorder(entries *[]map[string]interface{}) *stubAdapter {
+	return &stubAdapter{
+		insertFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			for _, obj := range objects {
+				*entries = append(*entries, obj.(map[string]interface{}))
+			}
+			return nil
+		},
+	}
+}
+
+func TestMapper_Audit_Insert(t *testing.T) {
+	mapper := newTestMapper(t, auditTestConfig)
+	var entries []map[string]interface{}
+	audit := auditRecorder(&entries)
+	registerStub(mapper, "primary", &stubAdapter{})
+	registerStub(mapper, "audit", audit)
+
+	ctx := WithActor(context.Background(), "alice")
+	if err := mapper.Insert(ctx, "app.product", &fallbackProduct{ID: "1", Name: "Desk"}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("audit entries = %v, want 1", entries)
+	}
+	entry := entries[0]
+	if entry[AuditMapping] != "app.product" || entry[AuditOperation] != "insert" || entry[AuditActor] != "alice" {
+		t.Errorf("entry = %v, want an insert of app.product by alice", entry)
+	}
+	if want := map[string]interface{}{"id": "1"}; !reflect.DeepEqual(entry[AuditIdentifier], want) {
+		t.Errorf("identifier = %v, want %v", entry[AuditIdentifier], want)
+	}
+	wantChanges := map[string]interface{}{
+		"id":   map[string]interface{}{"after": "1"},
+		"name": map[string]interface{}{"after": "Desk"},
+	}
+	if !reflect.DeepEqual(entry[AuditChanges], wantChanges) {
+		t.Errorf("changes = %v, want %v", entry[AuditChanges], wantChanges)
+	}
+	if _, ok := entry[AuditTimestamp].(time.Time); !ok {
+		t.Errorf("timestamp = %v, want a time", entry[AuditTimestamp])
+	}
+	if id, _ := entry[AuditID].(string); len(id) != 16 {
+		t.Errorf("id = %q, want 16 hex characters", id)
+	}
+	if op := audit.lastOp(); op.Type != adapter.OpInsert || op.Statement != "audit/{id}.json" {
+		t.Errorf("audit operation = %+v, want an insert on the audit statement", op)
+	}
+}
+
+func TestMapper_Audit_UpdateDiff(t *testing.T) {
+	mapper := newTestMapper(t, auditTestConfig)
+	var entries []map[string]interface{}
+	registerStub(mapper, "primary", &stubAdapter{
+		fetchFn: fetchReturning(map[string]interface{}{"id": "1", "name": "Desk"}),
+	})
+	registerStub(mapper, "audit", auditRecorder(&entries))
+
+	if err := mapper.Update(context.Background(), "app.product", &fallbackProduct{ID: "1", Name: "Standing desk"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("audit entries = %v, want 1", entries)
+	}
+	entry := entries[0]
+	if entry[AuditOperation] != "update" || entry[AuditActor] != "" {
+		t.Errorf("entry = %v, want an update without actor", entry)
+	}
+	wantChanges := map[string]interface{}{
+		"name": map[string]interface{}{"before": "Desk", "after": "Standing desk"},
+	}
+	if !reflect.DeepEqual(entry[AuditChanges], wantChanges) {
+		t.Errorf("changes = %v, want %v", entry[AuditChanges], wantChanges)
+	}
+}
+
+func TestMapper_Audit_Delete(t *testing.T) {
+	mapper := newTestMapper(t, auditTestConfig)
+	var entries []map[string]interface{}
+	registerStub(mapper, "primary", &stubAdapter{})
+	registerStub(mapper, "audit", auditRecorder(&entries))
+
+	if err := mapper.Delete(context.Background(), "app.product", "1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("audit entries = %v, want 1", entries)
+	}
+	entry := entries[0]
+	if entry[AuditOperation] != "delete" || entry[AuditChanges] != nil {
+		t.Errorf("entry = %v, want a delete without changes", entry)
+	}
+	if want := map[string]interface{}{"id": "1"}; !reflect.DeepEqual(entry[AuditIdentifier], want) {
+		t.Errorf("identifier = %v, want %v", entry[AuditIdentifier], want)
+	}
+}
+
+func TestMapper_Audit_SkippedOnFailure(t *testing.T) {
+	mapper := newTestMapper(t, auditTestConfig)
+	var entries []map[string]interface{}
+	registerStub(mapper, "primary", &stubAdapter{
+		insertFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			return adapter.ErrConflict
+		},
+	})
+	registerStub(mapper, "audit", auditRecorder(&entries))
+
+	if err := mapper.Insert(context.Background(), "app.product", &fallbackProduct{ID: "1"}); err == nil {
+		t.Fatal("Insert() error = nil, want the adapter error")
+	}
+	if len(entries) != 0 {
+		t.Errorf("audit entries = %v, want none for a failed write", entries)
+	}
+}
+
+func TestMapper_Audit_WriteError(t *testing.T) {
+	mapper := newTestMapper(t, auditTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{})
+	registerStub(mapper, "audit", &stubAdapter{
+		insertFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			return adapter.ErrConnection
+		},
+	})
+
+	err := mapper.Insert(context.Background(), "app.product", &fallbackProduct{ID: "1"})
+	if !errors.Is(err, adapter.ErrConnection) {
+		t.Errorf("Insert() error = %v, want the audit source error", err)
+	}
+	var auditErr *AuditError
+	if !errors.As(err, &auditErr) || auditErr.MappingID != "app.product" {
+		t.Errorf("Insert() error = %v, want an *AuditError telling the insert succeeded", err)
+	}
+}
+
+func TestMapper_Audit_UpdateBatchesBefore(t *testing.T) {
+	stored := map[string]string{"1": "Desk", "2": "Chair", "3": "Lamp"}
+	products := []*fallbackProduct{{ID: "1", Name: "Standing desk"}, {ID: "2", Name: "Stool"}, {ID: "3", Name: "Lamp"}}
+
+	tests := []struct {
+		name    string
+		native  bool
+		fetches int
+	}{
+		{"native criteria", true, 1},
+		{"fetch per record", false, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := newTestMapper(t, auditTestConfig)
+			primary := &batchStub{native: tt.native}
+			primary.fetchFn = func(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+				if op.Criteria != nil {
+					var rows []interface{}
+					for _, id := range op.Criteria.Value.([]interface{}) {
+						rows = append(rows, map[string]interface{}{"id": id, "name": stored[id.(string)]})
+					}
+					return rows, nil
+				}
+				id := params["id"].(string)
+				return []interface{}{map[string]interface{}{"id": id, "name": stored[id]}}, nil
+			}
+			mapper.RegisterAdapter("primary", func(config.Source) (adapter.Adapter, error) { return primary, nil })
+			var entries []map[string]interface{}
+			registerStub(mapper, "audit", auditRecorder(&entries))
+
+			if err := mapper.Update(context.Background(), "app.product", products); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			if got := primary.callCount("fetch"); got != tt.fetches {
+				t.Errorf("fetches = %d, want %d", got, tt.fetches)
+			}
+			if len(entries) != 3 {
+				t.Fatalf("audit entries = %v, want 3", entries)
+			}
+			wantChanges := []map[string]interface{}{
+				{"name": map[string]interface{}{"before": "Desk", "after": "Standing desk"}},
+				{"name": map[string]interface{}{"before": "Chair", "after": "Stool"}},
+				{},
+			}
+			for i, entry := range entries {
+				if !reflect.DeepEqual(entry[AuditChanges], wantChanges[i]) {
+					t.Errorf("entry %d changes = %v, want %v", i, entry[AuditChanges], wantChanges[i])
+				}
+			}
+		})
+	}
+}
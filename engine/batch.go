@@ -0,0 +1,333 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// Batch loading defaults.
+const (
+	// DefaultBatchWait is how long a BatchLoader collects keys before fetching them.
+	DefaultBatchWait = time.Millisecond
+
+	// DefaultBatchSize is the largest number of keys a BatchLoader fetches at once.
+	DefaultBatchSize = 100
+
+	// DefaultBatchParallelism bounds the concurrent single fetches of a batch.
+	DefaultBatchParallelism = 8
+)
+
+// BatchLoader coalesces lookups of one mapping by key field (dataloader style).
+// Keys requested through Load within a short window are fetched together: with one
+// fetch whose criteria select all keys when the mapping's adapter evaluates criteria
+// natively through an index, and otherwise, when the mapping's fetch takes the key
+// as a parameter, with single fetches run in parallel up to a bound. The results are
+// then handed back to each waiting caller.
+//
+// Related objects loaded with Include are batched the same way for all fetched
+// objects, so loading the customers of 500 orders takes one fetch (or parallel
+// fetches) instead of 500 sequential ones.
+type BatchLoader struct {
+	mapper    *Mapper
+	mappingID string
+	keyField  string
+	elemType  reflect.Type
+	options   *batchOptions
+
+	mu sync.Mutex
+
+	// batch collects the keys of the next fetch
+	batch *loadBatch
+}
+
+// loadBatch is a set of keys fetched together.
+type loadBatch struct {
+	ctx        context.Context
+	keys       []interface{}
+	seen       map[string]bool
+	dispatched bool
+
+	// done is closed once results or err are set
+	done    chan struct{}
+	results map[string][]reflect.Value
+	err     error
+}
+
+// BatchOption customizes a BatchLoader.
+type BatchOption func(*batchOptions)
+
+// batchOptions holds the settings collected from BatchOptions.
+type batchOptions struct {
+	wait        time.Duration
+	size        int
+	parallelism int
+}
+
+// BatchWait sets how long keys are collected before a batch is fetched.
+func BatchWait(wait time.Duration) BatchOption {
+	return func(o *batchOptions) {
+		o.wait = wait
+	}
+}
+
+// BatchSize sets the largest number of keys fetched in one batch; a full batch is
+// fetched without waiting. Zero means unbounded.
+func BatchSize(size int) BatchOption {
+	return func(o *batchOptions) {
+		o.size = size
+	}
+}
+
+// BatchParallelism bounds the single fetches run concurrently for a batch whose
+// mapping takes the key as a parameter and whose adapter lacks indexed criteria.
+func BatchParallelism(parallelism int) BatchOption {
+	return func(o *batchOptions) {
+		o.parallelism = parallelism
+	}
+}
+
+// newBatchOptions applies opts over the default batch settings.
+func newBatchOptions(opts []BatchOption) *batchOptions {
+	o := &batchOptions{
+		wait:        DefaultBatchWait,
+		size:        DefaultBatchSize,
+		parallelism: DefaultBatchParallelism,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.parallelism < 1 {
+		o.parallelism = 1
+	}
+	return o
+}
+
+// NewBatchLoader creates a loader of mappingID's objects by the object field keyField.
+// elem is a sample of the element type, as for FetchIter: pass &Customer{} to load
+// *Customer values or Customer{} for Customer values.
+func (m *Mapper) NewBatchLoader(mappingID, keyField string, elem interface{}, opts ...BatchOption) (*BatchLoader, error) {
+	elemType := reflect.TypeOf(elem)
+	if elemType == nil {
+		return nil, fmt.Errorf("elem cannot be nil")
+	}
+	if _, _, err := m.parser.GetMapping(mappingID); err != nil {
+		return nil, err
+	}
+
+	return &BatchLoader{
+		mapper:    m,
+		mappingID: mappingID,
+		keyField:  keyField,
+		elemType:  elemType,
+		options:   newBatchOptions(opts),
+	}, nil
+}
+
+// Load returns the objects whose key field equals key, as values of the loader's
+// element type; for unique keys there is at most one. The batch runs with the
+// values of the context of its first Load, but is not cancelled with it: a
+// cancelled ctx only stops this caller from waiting.
+func (l *BatchLoader) Load(ctx context.Context, key interface{}) ([]interface{}, error) {
+	k := batchKey(key)
+
+	l.mu.Lock()
+	b := l.batch
+	if b == nil {
+		b = &loadBatch{
+			ctx:  context.WithoutCancel(ctx),
+			seen: make(map[string]bool),
+			done: make(chan struct{}),
+		}
+		l.batch = b
+		time.AfterFunc(l.options.wait, func() { l.dispatch(b) })
+	}
+	if !b.seen[k] {
+		b.seen[k] = true
+		b.keys = append(b.keys, key)
+	}
+	full := l.options.size > 0 && len(b.keys) >= l.options.size
+	l.mu.Unlock()
+
+	if full {
+		go l.dispatch(b)
+	}
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	found := b.results[k]
+	objects := make([]interface{}, len(found))
+	for i, ptr := range found {
+		if l.elemType.Kind() == reflect.Ptr {
+			objects[i] = ptr.Interface()
+		} else {
+			objects[i] = ptr.Elem().Interface()
+		}
+	}
+	return objects, nil
+}
+
+// dispatch fetches a batch once, detaching it so that later keys start a new batch.
+func (l *BatchLoader) dispatch(b *loadBatch) {
+	l.mu.Lock()
+	if b.dispatched {
+		l.mu.Unlock()
+		return
+	}
+	b.dispatched = true
+	if l.batch == b {
+		l.batch = nil
+	}
+	l.mu.Unlock()
+
+	structType := l.elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	b.results, b.err = l.mapper.fetchByKeys(b.ctx, l.mappingID, l.keyField, structType, b.keys, nil, l.options.parallelism)
+	close(b.done)
+}
+
+// fetchByKeys fetches the objects of mappingID whose keyField equals one of keys,
+// grouped by key. Objects are returned as pointers to new values of structType.
+// All keys are fetched at once with criteria when the mapping's adapter evaluates them
+// natively without scanning, or when the fetch does not take keyField as a parameter.
+// Otherwise each key is fetched on its own, up to parallelism at a time (one inside
+// a transaction).
+// Relations named in include are then loaded for all objects together.
+func (m *Mapper) fetchByKeys(ctx context.Context, mappingID, keyField string, structType reflect.Type, keys []interface{}, include []string, parallelism int) (map[string][]reflect.Value, error) {
+	results := make(map[string][]reflect.Value)
+	if len(keys) == 0 {
+		return results, nil
+	}
+
+	mapping, cfg, err := m.parser.GetMapping(mappingID)
+	if err != nil {
+		return nil, err
+	}
+
+	sliceType := reflect.SliceOf(reflect.PointerTo(structType))
+	all := reflect.MakeSlice(sliceType, 0, len(keys))
+
+	if param, byParams := keyParameter(mapping, keyField); byParams && !m.nativeKeyCriteria(ctx, mappingID, cfg, mapping, param, keys) {
+		// Adapter transactions are not meant for concurrent use
+		if txFromContext(ctx) != nil {
+			parallelism = 1
+		}
+
+		found := make([]reflect.Value, len(keys))
+		errs := make([]error, len(keys))
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, parallelism)
+		for i, key := range keys {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(i int, key interface{}) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				objects := reflect.New(sliceType)
+				err := m.FetchMulti(ctx, mappingID, map[string]interface{}{param: key}, objects.Interface())
+				if err != nil && !isNotFound(err) {
+					errs[i] = err
+					return
+				}
+				found[i] = objects.Elem()
+			}(i, key)
+		}
+		wg.Wait()
+
+		for i, key := range keys {
+			if errs[i] != nil {
+				return nil, fmt.Errorf("fetch of key %v failed: %w", key, errs[i])
+			}
+			k := batchKey(key)
+			for j := 0; j < found[i].Len(); j++ {
+				results[k] = append(results[k], found[i].Index(j))
+				all = reflect.Append(all, found[i].Index(j))
+			}
+		}
+	} else {
+		objects := reflect.New(sliceType)
+		if err := m.FetchMulti(ctx, mappingID, map[string]interface{}{}, objects.Interface(), Where(adapter.In(keyField, keys...))); err != nil {
+			return nil, err
+		}
+
+		for j := 0; j < objects.Elem().Len(); j++ {
+			ptr := objects.Elem().Index(j)
+			value := ptr.Elem().FieldByName(keyField)
+			if !value.IsValid() {
+				return nil, fmt.Errorf("%s has no field '%s'", structType, keyField)
+			}
+			k := batchKey(value.Interface())
+			results[k] = append(results[k], ptr)
+			all = reflect.Append(all, ptr)
+		}
+	}
+
+	// Load nested relations for all objects at once
+	holder := reflect.New(sliceType)
+	holder.Elem().Set(all)
+	if err := m.includeRelations(ctx, mappingID, mapping, cfg, holder.Interface(), include); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// keyParameter returns the data field of the fetch parameter mapped to keyField,
+// and whether there is one.
+func keyParameter(mapping *config.Mapping, keyField string) (string, bool) {
+	if fetch, exists := mapping.Operations["fetch"]; exists {
+		for _, pm := range fetch.Parameters {
+			if pm.Object == keyField {
+				return pm.Field, true
+			}
+		}
+	}
+	return "", false
+}
+
+// nativeKeyCriteria reports whether the first source of the mapping's fetch evaluates
+// criteria selecting keys of the data field natively, without scanning every record
+// (see adapter.CriteriaScanner).
+func (m *Mapper) nativeKeyCriteria(ctx context.Context, mappingID string, cfg *config.Config, mapping *config.Mapping, field string, keys []interface{}) bool {
+	fetch, exists := mapping.Operations["fetch"]
+	if !exists {
+		return false
+	}
+	steps, err := m.sourceChain(mappingID, cfg, mapping, &fetch)
+	if err != nil || len(steps) == 0 {
+		return false
+	}
+	adp, err := m.access(ctx, steps[0].source, steps[0].sourceID, false)
+	if err != nil {
+		return false
+	}
+	criteria := adapter.In(field, keys...)
+	supporter, ok := adp.(adapter.CriteriaSupporter)
+	if !ok || !supporter.SupportsCriteria(criteria) {
+		return false
+	}
+	scanner, ok := adp.(adapter.CriteriaScanner)
+	return !ok || !scanner.ScansCriteria(criteria)
+}
+
+// batchKey normalizes a key so that equal keys of different Go types (e.g. int
+// and int64) are fetched and matched once.
+func batchKey(key interface{}) string {
+	return fmt.Sprint(key)
+}
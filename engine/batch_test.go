@@ -0,0 +1,328 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+const batchTestConfig = `namespace: shop
+version: "1.0"
+sources:
+  orders:
+    adapter: orders
+    connection: "localhost"
+  crm:
+    adapter: crm
+    connection: "localhost"
+mappings:
+  order:
+    object: Order
+    source: orders
+    relations:
+      Customer:
+        type: belongs_to
+        mapping: customer
+        local_key: CustomerID
+        foreign_key: ID
+      Lines:
+        type: has_many
+        mapping: line
+        local_key: ID
+        foreign_key: OrderID
+    operations:
+      fetch:
+        statement: "orders/*.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: CustomerID
+              field: customer_id
+  line:
+    object: Line
+    source: orders
+    operations:
+      fetch:
+        statement: "lines/*.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: OrderID
+              field: order_id
+  customer:
+    object: Customer
+    source: crm
+    operations:
+      fetch:
+        statement: "customers/{id}.json"
+        parameters:
+          - object: ID
+            field: id
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+`
+
+// batchStub is a stub adapter that records how many fetches run concurrently.
+// Unless native is unset, it evaluates in criteria on the customer id itself,
+// through an index unless scans is set.
+type batchStub struct {
+	stubAdapter
+	active, maxActive int32
+	native, scans     bool
+}
+
+func (b *batchStub) SupportsCriteria(c adapter.Criterion) bool {
+	return b.native && c.Op == adapter.OperatorIn
+}
+
+func (b *batchStub) ScansCriteria(c adapter.Criterion) bool {
+	return b.scans
+}
+
+func newBatchTestMapper(t *testing.T) (*Mapper, *stubAdapter, *batchStub) {
+	t.Helper()
+	mapper := newTestMapper(t, batchTestConfig)
+
+	orders := &stubAdapter{
+		fetchFn: func(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+			if op.Statement == "lines/*.json" {
+				return []interface{}{
+					map[string]interface{}{"id": "l1", "order_id": "o1"},
+					map[string]interface{}{"id": "l2", "order_id": "o1"},
+					map[string]interface{}{"id": "l3", "order_id": "o3"},
+				}, nil
+			}
+			var rows []interface{}
+			for i, customer := range []string{"c1", "c2", "c1", "c3", "c2"} {
+				rows = append(rows, map[string]interface{}{"id": fmt.Sprintf("o%d", i+1), "customer_id": customer})
+			}
+			return rows, nil
+		},
+	}
+	registerStub(mapper, "orders", orders)
+
+	crm := &batchStub{native: true}
+	crm.fetchFn = func(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+		active := atomic.AddInt32(&crm.active, 1)
+		defer atomic.AddInt32(&crm.active, -1)
+		for {
+			peak := atomic.LoadInt32(&crm.maxActive)
+			if active <= peak || atomic.CompareAndSwapInt32(&crm.maxActive, peak, active) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		if op.Criteria != nil {
+			var rows []interface{}
+			for _, id := range op.Criteria.Value.([]interface{}) {
+				if id != "c404" {
+					rows = append(rows, map[string]interface{}{"id": id, "name": fmt.Sprintf("Customer %s", id)})
+				}
+			}
+			return rows, nil
+		}
+
+		id := params["id"].(string)
+		if id == "c404" {
+			return nil, adapter.ErrNotFound
+		}
+		return []interface{}{map[string]interface{}{"id": id, "name": "Customer " + id}}, nil
+	}
+	mapper.RegisterAdapter("crm", func(source config.Source) (adapter.Adapter, error) {
+		return crm, nil
+	})
+
+	return mapper, orders, crm
+}
+
+type batchOrder struct {
+	ID         string
+	CustomerID string
+	Customer   *relCustomer
+	Lines      []*relLine
+}
+
+func TestMapper_FetchMulti_IncludeBatched(t *testing.T) {
+	mapper, orders, crm := newBatchTestMapper(t)
+
+	var result []batchOrder
+	if err := mapper.FetchMulti(context.Background(), "shop.order", nil, &result, Include("Customer", "Lines")); err != nil {
+		t.Fatalf("FetchMulti() error = %v", err)
+	}
+
+	// One fetch for all customers, and one fetch for all lines
+	if crm.callCount("fetch") != 1 {
+		t.Errorf("customer fetches = %d, want 1", crm.callCount("fetch"))
+	}
+	if criteria := crm.lastOp().Criteria; criteria == nil || len(criteria.Value.([]interface{})) != 3 {
+		t.Errorf("customer fetch criteria = %+v, want in over 3 distinct ids", criteria)
+	}
+	if orders.callCount("fetch") != 2 {
+		t.Errorf("order and line fetches = %d, want 2", orders.callCount("fetch"))
+	}
+
+	for _, order := range result {
+		if order.Customer == nil || order.Customer.ID != order.CustomerID {
+			t.Errorf("order %s: Customer = %+v, want %s", order.ID, order.Customer, order.CustomerID)
+		}
+	}
+	if len(result[0].Lines) != 2 || len(result[1].Lines) != 0 || len(result[2].Lines) != 1 {
+		t.Errorf("lines per order = %d, %d, %d, want 2, 0, 1", len(result[0].Lines), len(result[1].Lines), len(result[2].Lines))
+	}
+}
+
+func TestBatchLoader_Load(t *testing.T) {
+	mapper, _, crm := newBatchTestMapper(t)
+	loadCustomers(t, mapper)
+
+	if crm.callCount("fetch") != 1 {
+		t.Errorf("customer fetches = %d, want 1 (one in fetch for all keys)", crm.callCount("fetch"))
+	}
+}
+
+func TestBatchLoader_Load_ParallelFallback(t *testing.T) {
+	mapper, _, crm := newBatchTestMapper(t)
+	crm.native = false
+	loadCustomers(t, mapper)
+
+	if crm.callCount("fetch") != 5 {
+		t.Errorf("customer fetches = %d, want 5 (one per distinct key)", crm.callCount("fetch"))
+	}
+	if peak := atomic.LoadInt32(&crm.maxActive); peak > 2 {
+		t.Errorf("concurrent fetches = %d, want at most 2", peak)
+	}
+}
+
+func TestBatchLoader_Load_ScanningAdapter(t *testing.T) {
+	mapper, _, crm := newBatchTestMapper(t)
+	crm.scans = true
+	loadCustomers(t, mapper)
+
+	if crm.callCount("fetch") != 5 {
+		t.Errorf("customer fetches = %d, want 5 (keyed fetches instead of a scan)", crm.callCount("fetch"))
+	}
+}
+
+// loadCustomers loads customers concurrently through a batch loader and checks the results.
+func loadCustomers(t *testing.T, mapper *Mapper) {
+	t.Helper()
+	loader, err := mapper.NewBatchLoader("shop.customer", "ID", &relCustomer{}, BatchWait(20*time.Millisecond), BatchParallelism(2))
+	if err != nil {
+		t.Fatalf("NewBatchLoader() error = %v", err)
+	}
+
+	keys := []string{"c1", "c2", "c3", "c1", "c4", "c404"}
+	results := make([][]interface{}, len(keys))
+	errs := make([]error, len(keys))
+
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			results[i], errs[i] = loader.Load(context.Background(), key)
+		}(i, key)
+	}
+	wg.Wait()
+
+	for i, key := range keys {
+		if errs[i] != nil {
+			t.Fatalf("Load(%s) error = %v", key, errs[i])
+		}
+		if key == "c404" {
+			if len(results[i]) != 0 {
+				t.Errorf("Load(c404) = %v, want no objects", results[i])
+			}
+			continue
+		}
+		if len(results[i]) != 1 || results[i][0].(*relCustomer).ID != key {
+			t.Errorf("Load(%s) = %v", key, results[i])
+		}
+	}
+}
+
+func TestBatchLoader_Criteria(t *testing.T) {
+	mapper, orders, _ := newBatchTestMapper(t)
+
+	loader, err := mapper.NewBatchLoader("shop.line", "OrderID", relLine{}, BatchWait(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewBatchLoader() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	counts := make(map[string]int)
+	var mu sync.Mutex
+	for _, key := range []string{"o1", "o2", "o3"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			lines, err := loader.Load(context.Background(), key)
+			if err != nil {
+				t.Errorf("Load(%s) error = %v", key, err)
+				return
+			}
+			for _, line := range lines {
+				if line.(relLine).OrderID != key {
+					t.Errorf("Load(%s) returned line of %s", key, line.(relLine).OrderID)
+				}
+			}
+			mu.Lock()
+			counts[key] = len(lines)
+			mu.Unlock()
+		}(key)
+	}
+	wg.Wait()
+
+	if orders.callCount("fetch") != 1 {
+		t.Errorf("line fetches = %d, want 1", orders.callCount("fetch"))
+	}
+	if counts["o1"] != 2 || counts["o2"] != 0 || counts["o3"] != 1 {
+		t.Errorf("lines per order = %v", counts)
+	}
+}
+
+func TestBatchLoader_FullBatchAndCancel(t *testing.T) {
+	mapper, _, crm := newBatchTestMapper(t)
+
+	loader, err := mapper.NewBatchLoader("shop.customer", "ID", &relCustomer{}, BatchWait(time.Hour), BatchSize(2))
+	if err != nil {
+		t.Fatalf("NewBatchLoader() error = %v", err)
+	}
+
+	// A full batch is fetched without waiting
+	var wg sync.WaitGroup
+	for _, key := range []string{"c1", "c2"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			if _, err := loader.Load(context.Background(), key); err != nil {
+				t.Errorf("Load(%s) error = %v", key, err)
+			}
+		}(key)
+	}
+	wg.Wait()
+	if crm.callCount("fetch") != 1 {
+		t.Errorf("customer fetches = %d, want 1", crm.callCount("fetch"))
+	}
+
+	// A waiting caller stops when its context is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := loader.Load(ctx, "c3"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Load() error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// Circuit breaker defaults, used for settings a source's configuration leaves unset.
+const (
+	// DefaultFailureThreshold is the number of consecutive failures that opens a circuit.
+	DefaultFailureThreshold = 5
+
+	// DefaultOpenTimeout is how long a circuit stays open before trial calls.
+	DefaultOpenTimeout = 30 * time.Second
+
+	// DefaultHalfOpenRequests is the number of trial calls of a half-open circuit.
+	DefaultHalfOpenRequests = 1
+)
+
+// CircuitState is the state of a source's circuit breaker.
+type CircuitState string
+
+// Circuit breaker states.
+const (
+	// CircuitClosed lets all calls through.
+	CircuitClosed CircuitState = "closed"
+
+	// CircuitOpen fails calls fast with adapter.ErrCircuitOpen.
+	CircuitOpen CircuitState = "open"
+
+	// CircuitHalfOpen lets a limited number of trial calls through.
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitStatus is a snapshot of a circuit breaker, e.g. for dashboards.
+type CircuitStatus struct {
+	// State is the current state.
+	State CircuitState
+
+	// Failures is the number of consecutive failures counted while closed.
+	Failures int
+
+	// OpenedAt is when the circuit last opened (zero if it never did).
+	OpenedAt time.Time
+}
+
+// circuitBreaker tracks the failures of one source.
+type circuitBreaker struct {
+	threshold        int
+	openTimeout      time.Duration
+	halfOpenRequests int
+
+	// now returns the current time; replaced in tests
+	now func() time.Time
+
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	trials    int
+	successes int
+}
+
+// newCircuitBreaker creates a closed breaker, applying the defaults.
+// Durations are checked when the configuration is validated.
+func newCircuitBreaker(cfg *config.CircuitBreakerConfig) *circuitBreaker {
+	b := &circuitBreaker{
+		threshold:        cfg.FailureThreshold,
+		openTimeout:      DefaultOpenTimeout,
+		halfOpenRequests: cfg.HalfOpenRequests,
+		now:              time.Now,
+		state:            CircuitClosed,
+	}
+	if b.threshold == 0 {
+		b.threshold = DefaultFailureThreshold
+	}
+	if d, err := time.ParseDuration(cfg.OpenTimeout); err == nil {
+		b.openTimeout = d
+	}
+	if b.halfOpenRequests == 0 {
+		b.halfOpenRequests = DefaultHalfOpenRequests
+	}
+	return b
+}
+
+// refresh moves an open circuit whose timeout has passed to half-open.
+// The caller must hold b.mu.
+func (b *circuitBreaker) refresh() {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		b.state = CircuitHalfOpen
+		b.trials, b.successes = 0, 0
+	}
+}
+
+// open reports whether calls are refused without admitting one.
+func (b *circuitBreaker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	return b.state == CircuitOpen
+}
+
+// allow admits a call, returning adapter.ErrCircuitOpen when the circuit is open
+// or a half-open circuit has no trial calls left.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+
+	switch b.state {
+	case CircuitOpen:
+		return adapter.ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.trials >= b.halfOpenRequests {
+			return adapter.ErrCircuitOpen
+		}
+		b.trials++
+	}
+	return nil
+}
+
+// record counts the outcome of a call.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isSourceFailure(err) {
+		switch b.state {
+		case CircuitClosed:
+			b.failures = 0
+		case CircuitHalfOpen:
+			b.successes++
+			if b.successes >= b.halfOpenRequests {
+				b.state, b.failures = CircuitClosed, 0
+			}
+		}
+		return
+	}
+
+	switch b.state {
+	case CircuitClosed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.state, b.openedAt = CircuitOpen, b.now()
+		}
+	case CircuitHalfOpen:
+		b.state, b.openedAt = CircuitOpen, b.now()
+	}
+}
+
+// status returns a snapshot of the breaker.
+func (b *circuitBreaker) status() CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	return CircuitStatus{State: b.state, Failures: b.failures, OpenedAt: b.openedAt}
+}
+
+// isSourceFailure reports whether err shows the source failing. Misses, rejected
+// requests, conflicts, configuration errors and cancellations by the caller say
+// nothing about the source's health.
+func isSourceFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	switch errorCode(err) {
+	case adapter.ErrNotFound.Code, adapter.ErrValidation.Code, adapter.ErrConflict.Code,
+		adapter.ErrConfiguration.Code, adapter.ErrCircuitOpen.Code:
+		return false
+	}
+	return true
+}
+
+// withBreaker wraps call so that it fails fast while b is open and its outcome is counted.
+func withBreaker(b *circuitBreaker, call Invoker) Invoker {
+	return func(ctx context.Context, inv *Invocation) error {
+		if err := b.allow(); err != nil {
+			return err
+		}
+		err := call(ctx, inv)
+		b.record(err)
+		return err
+	}
+}
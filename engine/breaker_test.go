@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+const breakerTestConfig = `namespace: shop
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+    circuit_breaker:
+      failure_threshold: 2
+      open_timeout: 1m
+  replica:
+    adapter: replica
+    connection: "localhost"
+mappings:
+  product:
+    object: Product
+    source: primary
+    operations:
+      fetch:
+        statement: "products/{id}.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+  chained:
+    object: Product
+    operations:
+      fetch:
+        sources:
+          - name: primary
+          - name: replica
+        statement: "products/{id}.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+`
+
+func TestMapper_CircuitBreaker(t *testing.T) {
+	mapper := newTestMapper(t, breakerTestConfig)
+	primary := &stubAdapter{fetchFn: fetchFailing(adapter.ErrConnection)}
+	registerStub(mapper, "primary", primary)
+	ctx := context.Background()
+	params := map[string]interface{}{"id": "1"}
+
+	var product fallbackProduct
+	for i := 0; i < 2; i++ {
+		if err := mapper.Fetch(ctx, "shop.product", params, &product); !errors.Is(err, adapter.ErrConnection) {
+			t.Fatalf("Fetch() error = %v, want ErrConnection", err)
+		}
+	}
+
+	status, ok := mapper.Registry().CircuitStatus("primary")
+	if !ok || status.State != CircuitOpen || status.OpenedAt.IsZero() {
+		t.Fatalf("CircuitStatus() = %+v, %v, want open", status, ok)
+	}
+
+	// An open circuit fails fast
+	if err := mapper.Fetch(ctx, "shop.product", params, &product); !errors.Is(err, adapter.ErrCircuitOpen) {
+		t.Errorf("Fetch() error = %v, want ErrCircuitOpen", err)
+	}
+	if primary.callCount("fetch") != 2 {
+		t.Errorf("adapter fetches = %d, want 2", primary.callCount("fetch"))
+	}
+
+	// Once the timeout has passed, a successful trial call closes the circuit
+	breaker := mapper.registry.breaker("primary")
+	breaker.now = func() time.Time { return time.Now().Add(time.Minute) }
+	if got := mapper.Registry().CircuitStatuses()["primary"].State; got != CircuitHalfOpen {
+		t.Errorf("state = %s, want half_open", got)
+	}
+
+	primary.fetchFn = fetchReturning(map[string]interface{}{"id": "1"})
+	if err := mapper.Fetch(ctx, "shop.product", params, &product); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if status, _ := mapper.Registry().CircuitStatus("primary"); status.State != CircuitClosed || status.Failures != 0 {
+		t.Errorf("CircuitStatus() = %+v, want closed", status)
+	}
+
+	if _, ok := mapper.Registry().CircuitStatus("replica"); ok {
+		t.Error("sources without a circuit breaker should have no status")
+	}
+}
+
+func TestMapper_CircuitBreaker_HalfOpenFailure(t *testing.T) {
+	mapper := newTestMapper(t, breakerTestConfig)
+	primary := &stubAdapter{fetchFn: fetchFailing(errors.New("boom"))}
+	registerStub(mapper, "primary", primary)
+	ctx := context.Background()
+	params := map[string]interface{}{"id": "1"}
+
+	var product fallbackProduct
+	for i := 0; i < 2; i++ {
+		_ = mapper.Fetch(ctx, "shop.product", params, &product)
+	}
+
+	breaker := mapper.registry.breaker("primary")
+	later := time.Now().Add(time.Minute)
+	breaker.now = func() time.Time { return later }
+
+	// A failing trial call opens the circuit again
+	if err := mapper.Fetch(ctx, "shop.product", params, &product); err == nil || errors.Is(err, adapter.ErrCircuitOpen) {
+		t.Fatalf("Fetch() error = %v, want the trial call's error", err)
+	}
+	if status, _ := mapper.Registry().CircuitStatus("primary"); status.State != CircuitOpen || !status.OpenedAt.Equal(later) {
+		t.Errorf("CircuitStatus() = %+v, want reopened", status)
+	}
+}
+
+func TestMapper_CircuitBreaker_IgnoresRequestErrors(t *testing.T) {
+	mapper := newTestMapper(t, breakerTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{fetchFn: fetchFailing(adapter.ErrNotFound)})
+
+	var product fallbackProduct
+	for i := 0; i < 3; i++ {
+		if err := mapper.Fetch(context.Background(), "shop.product", map[string]interface{}{"id": "404"}, &product); !errors.Is(err, adapter.ErrNotFound) {
+			t.Fatalf("Fetch() error = %v, want ErrNotFound", err)
+		}
+	}
+	if status, _ := mapper.Registry().CircuitStatus("primary"); status.State != CircuitClosed {
+		t.Errorf("state = %s, want closed", status.State)
+	}
+}
+
+func TestMapper_CircuitBreaker_FallbackSkipsOpenSource(t *testing.T) {
+	mapper := newTestMapper(t, breakerTestConfig)
+	primary := &stubAdapter{fetchFn: fetchFailing(adapter.ErrConnection)}
+	registerStub(mapper, "primary", primary)
+	registerStub(mapper, "replica", &stubAdapter{fetchFn: fetchReturning(map[string]interface{}{"id": "1"})})
+	ctx := context.Background()
+	params := map[string]interface{}{"id": "1"}
+
+	var product fallbackProduct
+	for i := 0; i < 2; i++ {
+		_ = mapper.Fetch(ctx, "shop.product", params, &product)
+	}
+
+	// The chain has no on_error policy, but skips the primary while its circuit is open
+	if err := mapper.Fetch(ctx, "shop.chained", params, &product); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if product.ID != "1" || primary.callCount("fetch") != 2 {
+		t.Errorf("product = %+v, primary fetches = %d, want the replica's answer", product, primary.callCount("fetch"))
+	}
+
+	for item, err := range mapper.FetchIter(ctx, "shop.chained", nil, fallbackProduct{}) {
+		if err != nil {
+			t.Fatalf("FetchIter() error = %v", err)
+		}
+		if item.(fallbackProduct).ID != "1" {
+			t.Errorf("FetchIter() item = %+v, want the replica's answer", item)
+		}
+	}
+}
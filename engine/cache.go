@@ -0,0 +1,227 @@
+package engine
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// queryCache is the in-process cache of fetch operations configured with `cache`.
+// Each mapping has its own LRU of answers keyed by the fetch's parameters and options.
+type queryCache struct {
+	mu sync.Mutex
+
+	// mappings maps fully qualified mapping IDs to their cached answers
+	mappings map[string]*mappingCache
+
+	// now returns the current time (replaced in tests)
+	now func() time.Time
+}
+
+// mappingCache holds the cached answers of one mapping.
+type mappingCache struct {
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// generation changes on every invalidation, so that fetches started before
+	// a write do not store their answers after it
+	generation uint64
+}
+
+// cacheEntry is a cached answer: a chain result, or a not-found error when misses are cached.
+type cacheEntry struct {
+	key     string
+	answer  *chainResult
+	miss    error
+	expires time.Time
+}
+
+// newQueryCache creates an empty query cache.
+func newQueryCache() *queryCache {
+	return &queryCache{
+		mappings: make(map[string]*mappingCache),
+		now:      time.Now,
+	}
+}
+
+// mapping returns the cache of a mapping, creating it when needed. The caller must hold c.mu.
+func (c *queryCache) mapping(mappingID string) *mappingCache {
+	mc, exists := c.mappings[mappingID]
+	if !exists {
+		mc = &mappingCache{entries: make(map[string]*list.Element), lru: list.New()}
+		c.mappings[mappingID] = mc
+	}
+	return mc
+}
+
+// get returns the cached answer for key and the mapping's current generation.
+func (c *queryCache) get(mappingID, key string) (*cacheEntry, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	mc := c.mapping(mappingID)
+	elem, exists := mc.entries[key]
+	if !exists {
+		return nil, mc.generation
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		mc.lru.Remove(elem)
+		delete(mc.entries, key)
+		return nil, mc.generation
+	}
+
+	mc.lru.MoveToFront(elem)
+	return entry, mc.generation
+}
+
+// put stores an answer unless the mapping was invalidated since generation was read.
+func (c *queryCache) put(mappingID string, generation uint64, settings *config.CacheConfig, entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	mc := c.mapping(mappingID)
+	if mc.generation != generation {
+		return
+	}
+
+	if settings.TTL != "" {
+		if ttl, err := time.ParseDuration(settings.TTL); err == nil {
+			entry.expires = c.now().Add(ttl)
+		}
+	}
+
+	if elem, exists := mc.entries[entry.key]; exists {
+		elem.Value = entry
+		mc.lru.MoveToFront(elem)
+	} else {
+		mc.entries[entry.key] = mc.lru.PushFront(entry)
+	}
+
+	for settings.MaxEntries > 0 && mc.lru.Len() > settings.MaxEntries {
+		oldest := mc.lru.Back()
+		mc.lru.Remove(oldest)
+		delete(mc.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// invalidate drops every cached answer of a mapping.
+func (c *queryCache) invalidate(mappingID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	mc, exists := c.mappings[mappingID]
+	if !exists {
+		return
+	}
+	mc.entries = make(map[string]*list.Element)
+	mc.lru.Init()
+	mc.generation++
+}
+
+// invalidateCache drops the cached answers of a mapping after a write to it.
+// Inside a transaction they are dropped again when it commits, since concurrent
+// fetches may have cached the data the transaction replaces in the meantime.
+func (m *Mapper) invalidateCache(ctx context.Context, mappingID string) {
+	if tx := txFromContext(ctx); tx != nil {
+		tx.deferAfterActions(func(ctx context.Context) error {
+			m.cache.invalidate(mappingID)
+			return nil
+		})
+	}
+	m.cache.invalidate(mappingID)
+}
+
+// cachedFetch answers a fetch from the operation's query cache, walking the source
+// chain through fetch on a miss. Fetches inside a transaction bypass the cache,
+// since they must observe the transaction's writes. The cache keeps its own copy of
+// the data and hands out copies, so callers may modify the rows they receive.
+func (m *Mapper) cachedFetch(ctx context.Context, mappingID string, opConfig *config.OperationConfig, multi bool, params map[string]interface{}, options *fetchOptions, fetch func() (*chainResult, error)) (*chainResult, error) {
+	if opConfig.Cache == nil || txFromContext(ctx) != nil {
+		return fetch()
+	}
+
+	key := cacheKey(multi, params, options)
+	entry, generation := m.cache.get(mappingID, key)
+	if entry != nil {
+		return entry.answer.clone(), entry.miss
+	}
+
+	answer, err := fetch()
+	switch {
+	case err == nil:
+		m.cache.put(mappingID, generation, opConfig.Cache, &cacheEntry{key: key, answer: answer.clone()})
+	case isNotFound(err) && opConfig.Cache.CacheMisses:
+		m.cache.put(mappingID, generation, opConfig.Cache, &cacheEntry{key: key, miss: err})
+	}
+	return answer, err
+}
+
+// cacheKey identifies a fetch within its mapping by its parameters and options.
+func cacheKey(multi bool, params map[string]interface{}, options *fetchOptions) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	fmt.Fprintf(&key, "multi=%t", multi)
+	for _, name := range names {
+		fmt.Fprintf(&key, "&%s=%#v", name, params[name])
+	}
+	if options.page != nil {
+		fmt.Fprintf(&key, "|page=%+v", *options.page)
+	}
+	if options.where != nil {
+		fmt.Fprintf(&key, "|where=%#v", *options.where)
+	}
+	if options.withDeleted {
+		key.WriteString("|deleted")
+	}
+	return key.String()
+}
+
+// clone returns a deep copy of the answer's data.
+func (r *chainResult) clone() *chainResult {
+	if r == nil {
+		return nil
+	}
+	copied := *r
+	copied.data = copyData(r.data).([]interface{})
+	if r.page != nil {
+		page := *r.page
+		copied.page = &page
+	}
+	return &copied
+}
+
+// copyData deep-copies the maps and slices of an adapter result value.
+func copyData(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = copyData(item)
+		}
+		return out
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = copyData(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
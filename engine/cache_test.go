@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+const cacheTestConfig = `namespace: shop
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+mappings:
+  product:
+    object: Product
+    source: primary
+    operations:
+      fetch:
+        statement: "products/{id}.json"
+        cache:
+          ttl: 1m
+          max_entries: 2
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+      update:
+        statement: "products/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+        identifier:
+          - object: ID
+            field: id
+  lookup:
+    object: Product
+    source: primary
+    operations:
+      fetch:
+        statement: "lookup/{id}.json"
+        cache:
+          cache_misses: true
+        result:
+          properties:
+            - object: ID
+              field: id
+  price:
+    object: Price
+    source: primary
+    operations:
+      update:
+        statement: "prices/{id}.json"
+        properties:
+          - object: ID
+            field: id
+        after:
+          - action: invalidate
+            mapping: product
+`
+
+func newCacheTestMapper(t *testing.T) (*Mapper, *stubAdapter) {
+	t.Helper()
+	mapper := newTestMapper(t, cacheTestConfig)
+	primary := &stubAdapter{
+		fetchFn: func(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+			if params["id"] == "missing" {
+				return nil, adapter.ErrNotFound
+			}
+			return []interface{}{map[string]interface{}{"id": params["id"], "name": "Desk"}}, nil
+		},
+	}
+	registerStub(mapper, "primary", primary)
+	return mapper, primary
+}
+
+func fetchProduct(t *testing.T, mapper *Mapper, mappingID, id string) error {
+	t.Helper()
+	var product fallbackProduct
+	return mapper.Fetch(context.Background(), mappingID, map[string]interface{}{"id": id}, &product)
+}
+
+func TestMapper_Cache_Hits(t *testing.T) {
+	mapper, primary := newCacheTestMapper(t)
+
+	for i := 0; i < 3; i++ {
+		if err := fetchProduct(t, mapper, "shop.product", "1"); err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+	}
+	if primary.callCount("fetch") != 1 {
+		t.Errorf("adapter fetches = %d, want 1", primary.callCount("fetch"))
+	}
+
+	// Other params and other fetch kinds are cached separately
+	if err := fetchProduct(t, mapper, "shop.product", "2"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	var products []fallbackProduct
+	if err := mapper.FetchMulti(context.Background(), "shop.product", map[string]interface{}{"id": "1"}, &products); err != nil {
+		t.Fatalf("FetchMulti() error = %v", err)
+	}
+	if primary.callCount("fetch") != 3 {
+		t.Errorf("adapter fetches = %d, want 3", primary.callCount("fetch"))
+	}
+}
+
+func TestMapper_Cache_TTL(t *testing.T) {
+	mapper, primary := newCacheTestMapper(t)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mapper.cache.now = func() time.Time { return now }
+
+	if err := fetchProduct(t, mapper, "shop.product", "1"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	now = now.Add(59 * time.Second)
+	if err := fetchProduct(t, mapper, "shop.product", "1"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if primary.callCount("fetch") != 1 {
+		t.Fatalf("adapter fetches before expiry = %d, want 1", primary.callCount("fetch"))
+	}
+
+	now = now.Add(time.Second)
+	if err := fetchProduct(t, mapper, "shop.product", "1"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if primary.callCount("fetch") != 2 {
+		t.Errorf("adapter fetches after expiry = %d, want 2", primary.callCount("fetch"))
+	}
+}
+
+func TestMapper_Cache_MaxEntries(t *testing.T) {
+	mapper, primary := newCacheTestMapper(t)
+
+	// 1 and 2 fill the cache; touching 1 makes 2 the least recently used
+	for _, id := range []string{"1", "2", "1", "3", "1", "2"} {
+		if err := fetchProduct(t, mapper, "shop.product", id); err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+	}
+	if primary.callCount("fetch") != 4 {
+		t.Errorf("adapter fetches = %d, want 4 (1, 2, 3, then 2 again)", primary.callCount("fetch"))
+	}
+}
+
+func TestMapper_Cache_Misses(t *testing.T) {
+	mapper, primary := newCacheTestMapper(t)
+
+	// product does not cache misses
+	for i := 0; i < 2; i++ {
+		if err := fetchProduct(t, mapper, "shop.product", "missing"); !isNotFound(err) {
+			t.Fatalf("Fetch() error = %v, want ErrNotFound", err)
+		}
+	}
+	if primary.callCount("fetch") != 2 {
+		t.Errorf("adapter fetches = %d, want 2", primary.callCount("fetch"))
+	}
+
+	// lookup does
+	for i := 0; i < 2; i++ {
+		if err := fetchProduct(t, mapper, "shop.lookup", "missing"); !isNotFound(err) {
+			t.Fatalf("Fetch() error = %v, want ErrNotFound", err)
+		}
+	}
+	if primary.callCount("fetch") != 3 {
+		t.Errorf("adapter fetches = %d, want 3", primary.callCount("fetch"))
+	}
+}
+
+func TestMapper_Cache_InvalidatedByWrites(t *testing.T) {
+	mapper, primary := newCacheTestMapper(t)
+
+	if err := fetchProduct(t, mapper, "shop.product", "1"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if err := mapper.Update(context.Background(), "shop.product", &fallbackProduct{ID: "1", Name: "Lamp"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := fetchProduct(t, mapper, "shop.product", "1"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if primary.callCount("fetch") != 2 {
+		t.Errorf("adapter fetches = %d, want 2", primary.callCount("fetch"))
+	}
+}
+
+func TestMapper_Cache_InvalidatedByAfterAction(t *testing.T) {
+	mapper, primary := newCacheTestMapper(t)
+
+	if err := fetchProduct(t, mapper, "shop.product", "1"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if err := mapper.Update(context.Background(), "shop.price", &fallbackProduct{ID: "1"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if primary.callCount("delete") != 0 {
+		t.Error("invalidating a mapping without a source should not delete from a source")
+	}
+	if err := fetchProduct(t, mapper, "shop.product", "1"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if primary.callCount("fetch") != 2 {
+		t.Errorf("adapter fetches = %d, want 2", primary.callCount("fetch"))
+	}
+}
+
+func TestMapper_Cache_BypassedInTransaction(t *testing.T) {
+	mapper, primary := newCacheTestMapper(t)
+
+	if err := fetchProduct(t, mapper, "shop.product", "1"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	err := mapper.Transaction(context.Background(), func(tx *Tx) error {
+		var product fallbackProduct
+		return tx.Fetch(context.Background(), "shop.product", map[string]interface{}{"id": "1"}, &product)
+	})
+	if err != nil {
+		t.Fatalf("Transaction() error = %v", err)
+	}
+	if primary.callCount("fetch") != 2 {
+		t.Errorf("adapter fetches = %d, want 2", primary.callCount("fetch"))
+	}
+}
+
+func TestMapper_Cache_ReturnsCopies(t *testing.T) {
+	mapper, primary := newCacheTestMapper(t)
+	ctx := context.Background()
+	params := map[string]interface{}{"id": "1"}
+
+	var first interface{}
+	if err := mapper.Fetch(ctx, "shop.product", params, &first); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	first.(map[string]interface{})["name"] = "MUTATED"
+
+	for i := 0; i < 2; i++ {
+		var row interface{}
+		if err := mapper.Fetch(ctx, "shop.product", params, &row); err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		if name := row.(map[string]interface{})["name"]; name != "Desk" {
+			t.Fatalf("cached name = %v, want Desk", name)
+		}
+		row.(map[string]interface{})["name"] = "MUTATED"
+	}
+	if got := primary.callCount("fetch"); got != 1 {
+		t.Errorf("adapter fetches = %d, want 1", got)
+	}
+}
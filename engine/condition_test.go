@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+const conditionTestConfig = `namespace: test
+version: "1.0"
+sources:
+  db:
+    adapter: stub
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: db
+    operations:
+      update:
+        statement: "users/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+        identifier:
+          - object: ID
+            field: id
+        condition:
+          - object: Version
+            field: version
+`
+
+type versionedUser struct {
+	ID      string
+	Name    string
+	Version int
+}
+
+func TestMapper_Update_Condition(t *testing.T) {
+	mapper := newTestMapper(t, conditionTestConfig)
+	var written map[string]interface{}
+	stub := &stubAdapter{
+		updateFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			written = objects[0].(map[string]interface{})
+			return nil
+		},
+	}
+	registerStub(mapper, "stub", stub)
+
+	user := &versionedUser{ID: "1", Name: "Alice", Version: 3}
+	if err := mapper.Update(context.Background(), "test.user", user); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	op := stub.lastOp()
+	if len(op.Condition) != 1 || op.Condition[0].DataField != "version" {
+		t.Errorf("op.Condition = %+v, want version mapping", op.Condition)
+	}
+	if len(op.ConditionValues) != 1 || op.ConditionValues[0]["version"] != 3 {
+		t.Errorf("op.ConditionValues = %v, want expected version 3", op.ConditionValues)
+	}
+	if written["version"] != 4 {
+		t.Errorf("written version = %v, want 4", written["version"])
+	}
+	if user.Version != 4 {
+		t.Errorf("user.Version = %d, want 4", user.Version)
+	}
+}
+
+func TestMapper_Update_ConditionConflict(t *testing.T) {
+	mapper := newTestMapper(t, conditionTestConfig)
+	stub := &stubAdapter{
+		updateFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			return adapter.NewAdapterError(adapter.ErrConflict.Code, "version mismatch", nil)
+		},
+	}
+	registerStub(mapper, "stub", stub)
+
+	user := &versionedUser{ID: "1", Name: "Alice", Version: 3}
+	err := mapper.Update(context.Background(), "test.user", user)
+	if !errors.Is(err, adapter.ErrConflict) {
+		t.Fatalf("Update() error = %v, want ErrConflict", err)
+	}
+	if user.Version != 3 {
+		t.Errorf("user.Version = %d, want unchanged 3 after conflict", user.Version)
+	}
+}
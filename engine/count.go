@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+// Count returns the number of records a multi-result fetch of the mapping would
+// return for params, without mapping them to objects. It uses the mapping's
+// "count" operation, or else its "fetch" operation. Where filters the counted
+// records, soft-deleted records are excluded unless WithDeleted is given, and
+// ReportSource reports the counting source; other options are ignored.
+//
+// Adapters implementing adapter.Counter count natively (when the criteria, if
+// any, are native too); otherwise the records are fetched and counted. Sources of
+// a chain are tried in order while they fail, as for fetches, but an empty
+// result is an answer rather than a miss.
+func (m *Mapper) Count(ctx context.Context, mappingID string, params map[string]interface{}, opts ...FetchOption) (int, error) {
+	return m.count(ctx, adapter.OpCount, mappingID, params, newFetchOptions(opts))
+}
+
+// Exists reports whether a multi-result fetch of the mapping would return any
+// record for params, like Count but letting adapters stop at the first match.
+func (m *Mapper) Exists(ctx context.Context, mappingID string, params map[string]interface{}, opts ...FetchOption) (bool, error) {
+	n, err := m.count(ctx, adapter.OpExists, mappingID, params, newFetchOptions(opts))
+	return n > 0, err
+}
+
+// Count counts records within the transaction, like Mapper.Count.
+// Counts on transactional sources include the transaction's own writes.
+func (tx *Tx) Count(ctx context.Context, mappingID string, params map[string]interface{}, opts ...FetchOption) (int, error) {
+	return tx.mapper.Count(tx.bind(ctx), mappingID, params, opts...)
+}
+
+// Exists reports whether records exist within the transaction, like Mapper.Exists.
+func (tx *Tx) Exists(ctx context.Context, mappingID string, params map[string]interface{}, opts ...FetchOption) (bool, error) {
+	return tx.mapper.Exists(tx.bind(ctx), mappingID, params, opts...)
+}
+
+// count implements Count and Exists, walking the operation's source chain.
+func (m *Mapper) count(ctx context.Context, opType adapter.OperationType, mappingID string, params map[string]interface{}, options *fetchOptions) (int, error) {
+	mapping, cfg, err := m.parser.GetMapping(mappingID)
+	if err != nil {
+		return 0, err
+	}
+
+	opConfig, exists := mapping.Operations["count"]
+	if !exists {
+		if opConfig, exists = mapping.Operations["fetch"]; !exists {
+			return 0, fmt.Errorf("mapping '%s' does not have a 'count' or 'fetch' operation", mappingID)
+		}
+	}
+
+	steps, err := m.sourceChain(mappingID, cfg, mapping, &opConfig)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve source for %s: %w", opType, err)
+	}
+	if err := options.validate(); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := withOperationTimeout(ctx, opConfig.Timeout)
+	defer cancel()
+
+	var lastErr error
+	for i, step := range steps {
+		stepCtx, cancel := stepContext(ctx, len(steps)-i)
+		n, err := m.countFromSource(stepCtx, step, opType, params, options)
+		cancel()
+		if err == nil {
+			if options.sourceID != nil {
+				*options.sourceID = step.sourceID
+			}
+			return n, nil
+		}
+		lastErr = err
+
+		if (step.onError == PolicyNext || isCircuitOpen(err)) && i < len(steps)-1 && !isValidation(err) {
+			continue
+		}
+		return 0, err
+	}
+
+	return 0, lastErr
+}
+
+// countFromSource counts on a single source of a chain, natively when the
+// adapter is a Counter and the criteria (if any) are native, and by fetching the
+// records otherwise. A fetch that finds nothing counts 0.
+func (m *Mapper) countFromSource(ctx context.Context, step sourceStep, opType adapter.OperationType, params map[string]interface{}, options *fetchOptions) (int, error) {
+	adp, err := m.access(ctx, step.source, step.sourceID, false)
+	if err != nil {
+		return 0, err
+	}
+
+	criteria, native, err := stepCriteria(adp, step, options)
+	if err != nil {
+		return 0, err
+	}
+
+	counter, ok := adp.(adapter.Counter)
+	fetch := !ok || (criteria != nil && !native)
+
+	op := m.buildOperation(opType, step.opConfig)
+	if fetch {
+		op.Type = adapter.OpFetch
+	}
+	op.Multi = true
+	op.Source = step.sourceID
+	if native {
+		op.Criteria = criteria
+	}
+
+	inv := m.newInvocation(step.mappingID, step.sourceID, op)
+	inv.Params = params
+	inv.Idempotent = true
+	err = m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		if !fetch {
+			n, err := counter.Count(ctx, inv.Operation, inv.Params)
+			inv.Result = n
+			return err
+		}
+		data, err := adp.Fetch(ctx, inv.Operation, inv.Params)
+		inv.Result = data
+		return err
+	})
+	if isNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s failed: %w", opType, err)
+	}
+
+	if !fetch {
+		n, _ := inv.Result.(int)
+		return n, nil
+	}
+	data, _ := inv.Result.([]interface{})
+	if criteria != nil && !native {
+		if data, err = filterData(data, criteria); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// countingStub is a stub adapter that counts natively.
+type countingStub struct {
+	stubAdapter
+	count int
+}
+
+func (c *countingStub) Count(ctx context.Context, op *adapter.Operation, params map[string]interface{}) (int, error) {
+	c.record("count", op)
+	if op.Type == adapter.OpExists && c.count > 1 {
+		return 1, nil
+	}
+	return c.count, nil
+}
+
+const countTestConfig = `namespace: app
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+mappings:
+  product:
+    object: Product
+    source: primary
+    operations:
+      fetch:
+        statement: "products/*.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+`
+
+func TestMapper_Count_Native(t *testing.T) {
+	mapper := newTestMapper(t, countTestConfig)
+	stub := &countingStub{count: 3}
+	mapper.RegisterAdapter("primary", func(source config.Source) (adapter.Adapter, error) {
+		return stub, nil
+	})
+	ctx := context.Background()
+
+	n, err := mapper.Count(ctx, "app.product", nil)
+	if err != nil || n != 3 {
+		t.Fatalf("Count() = %d, %v; want 3", n, err)
+	}
+	if op := stub.lastOp(); op.Type != adapter.OpCount || !op.Multi {
+		t.Errorf("operation = %+v, want a multi count", op)
+	}
+
+	exists, err := mapper.Exists(ctx, "app.product", nil)
+	if err != nil || !exists {
+		t.Fatalf("Exists() = %v, %v; want true", exists, err)
+	}
+	if op := stub.lastOp(); op.Type != adapter.OpExists {
+		t.Errorf("operation type = %q, want exists", op.Type)
+	}
+	if stub.callCount("fetch") != 0 {
+		t.Error("native counts should not fetch")
+	}
+}
+
+func TestMapper_Count_Fallback(t *testing.T) {
+	mapper := newTestMapper(t, countTestConfig)
+	primary := &stubAdapter{
+		fetchFn: fetchReturning(
+			map[string]interface{}{"id": "1", "name": "Desk"},
+			map[string]interface{}{"id": "2", "name": "Chair"},
+			map[string]interface{}{"id": "3", "name": "Dresser"},
+		),
+	}
+	registerStub(mapper, "primary", primary)
+	ctx := context.Background()
+
+	n, err := mapper.Count(ctx, "app.product", nil)
+	if err != nil || n != 3 {
+		t.Fatalf("Count() = %d, %v; want 3", n, err)
+	}
+
+	var sourceID string
+	n, err = mapper.Count(ctx, "app.product", nil, Where(adapter.Like("Name", "D%")), ReportSource(&sourceID))
+	if err != nil || n != 2 {
+		t.Fatalf("Count() with criteria = %d, %v; want 2", n, err)
+	}
+	if sourceID != "primary" {
+		t.Errorf("source = %q, want primary", sourceID)
+	}
+
+	exists, err := mapper.Exists(ctx, "app.product", nil, Where(adapter.Eq("Name", "Lamp")))
+	if err != nil || exists {
+		t.Errorf("Exists() = %v, %v; want false", exists, err)
+	}
+	if op := primary.lastOp(); op.Type != adapter.OpFetch {
+		t.Errorf("operation type = %q, want a fetch", op.Type)
+	}
+}
+
+func TestMapper_Count_NotFound(t *testing.T) {
+	mapper := newTestMapper(t, countTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{fetchFn: fetchFailing(adapter.ErrNotFound)})
+
+	n, err := mapper.Count(context.Background(), "app.product", nil)
+	if err != nil || n != 0 {
+		t.Errorf("Count() = %d, %v; want 0", n, err)
+	}
+}
+
+func TestMapper_Count_Errors(t *testing.T) {
+	mapper := newTestMapper(t, countTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{fetchFn: fetchFailing(adapter.ErrConnection)})
+	ctx := context.Background()
+
+	if _, err := mapper.Count(ctx, "app.product", nil); err == nil {
+		t.Error("Count() error = nil, want the adapter error")
+	}
+	if _, err := mapper.Count(ctx, "app.product", nil, Where(adapter.Eq("Price", 1))); err == nil {
+		t.Error("Count() error = nil, want unmapped field error")
+	}
+	if _, err := mapper.Exists(ctx, "app.missing", nil); err == nil {
+		t.Error("Exists() error = nil, want unknown mapping error")
+	}
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// validate checks the fetch options before any source is queried.
+func (o *fetchOptions) validate() error {
+	if o.where == nil {
+		return nil
+	}
+	if err := o.where.Validate(); err != nil {
+		return adapter.NewAdapterError(adapter.ErrValidation.Code, "invalid criteria", err)
+	}
+	return nil
+}
+
+// stepCriteria translates the fetch criteria for a source step and reports
+// whether the source's adapter evaluates them natively. On mappings with soft
+// delete, criteria excluding deleted records are added unless WithDeleted is set.
+// Criteria only narrow the records a fetch returns; adapters still resolve the
+// statement from the fetch parameters alone.
+func stepCriteria(adp dataAccess, step sourceStep, options *fetchOptions) (*adapter.Criterion, bool, error) {
+	var criteria *adapter.Criterion
+	if options.where != nil {
+		translated, err := translateCriterion(*options.where, criteriaFields(step))
+		if err != nil {
+			return nil, false, adapter.NewAdapterError(adapter.ErrValidation.Code, "invalid criteria", err)
+		}
+		criteria = &translated
+	}
+
+	if step.softDelete != nil && !options.withDeleted {
+		active := activeCriterion(step.softDelete)
+		if criteria != nil {
+			active = adapter.And(*criteria, active)
+		}
+		criteria = &active
+	}
+
+	if criteria == nil {
+		return nil, false, nil
+	}
+	supporter, ok := adp.(adapter.CriteriaSupporter)
+	return criteria, ok && supporter.SupportsCriteria(*criteria), nil
+}
+
+// activeCriterion matches the records a soft delete has not marked, by data field.
+func activeCriterion(softDelete *config.SoftDeleteConfig) adapter.Criterion {
+	if softDelete.Mode == config.SoftDeleteFlag {
+		return adapter.Or(adapter.IsNull(softDelete.Field), adapter.Ne(softDelete.Field, true))
+	}
+	return adapter.IsNull(softDelete.Field)
+}
+
+// criteriaFields maps object field names to data fields for a step, from its
+// result properties and parameters.
+func criteriaFields(step sourceStep) map[string]string {
+	fields := make(map[string]string)
+	for _, pm := range step.opConfig.Parameters {
+		fields[pm.Object] = pm.Field
+	}
+	if step.result != nil {
+		for _, pm := range step.result.Properties {
+			fields[pm.Object] = pm.Field
+		}
+	}
+	return fields
+}
+
+// translateCriterion rewrites object field names in c to data field names.
+func translateCriterion(c adapter.Criterion, fields map[string]string) (adapter.Criterion, error) {
+	translated := c
+	if c.Field != "" {
+		field, exists := fields[c.Field]
+		if !exists {
+			return c, fmt.Errorf("field '%s' is not mapped", c.Field)
+		}
+		translated.Field = field
+	}
+
+	if len(c.Criteria) > 0 {
+		translated.Criteria = make([]adapter.Criterion, len(c.Criteria))
+		for i, sub := range c.Criteria {
+			var err error
+			if translated.Criteria[i], err = translateCriterion(sub, fields); err != nil {
+				return c, err
+			}
+		}
+	}
+
+	return translated, nil
+}
+
+// filterData keeps the results matching criteria.
+func filterData(data []interface{}, criteria *adapter.Criterion) ([]interface{}, error) {
+	filtered := make([]interface{}, 0, len(data))
+	for i, item := range data {
+		dataMap, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot filter result %d: expected map[string]interface{}, got %T", i, item)
+		}
+		if criteria.Match(dataMap) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, nil
+}
+
+// filterCursor skips the results of a cursor that do not match criteria.
+type filterCursor struct {
+	adapter.Cursor
+	criteria *adapter.Criterion
+	err      error
+}
+
+// Next advances to the next matching result.
+func (c *filterCursor) Next(ctx context.Context) bool {
+	for c.Cursor.Next(ctx) {
+		dataMap, ok := c.Cursor.Value().(map[string]interface{})
+		if !ok {
+			c.err = fmt.Errorf("cannot filter result: expected map[string]interface{}, got %T", c.Cursor.Value())
+			return false
+		}
+		if c.criteria.Match(dataMap) {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the error that stopped iteration.
+func (c *filterCursor) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.Cursor.Err()
+}
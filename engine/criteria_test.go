@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// criteriaStub is a stub adapter that claims native support for eq criteria.
+type criteriaStub struct {
+	stubAdapter
+}
+
+func (c *criteriaStub) SupportsCriteria(criterion adapter.Criterion) bool {
+	return criterion.Op == adapter.OperatorEq
+}
+
+var criteriaRows = []map[string]interface{}{
+	{"id": "1", "name": "Alice"},
+	{"id": "2", "name": "Bob"},
+	{"id": "3", "name": "Alina"},
+	{"id": "4", "name": "Carol"},
+}
+
+func TestMapper_FetchMulti_WhereInMemory(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	stub := &stubAdapter{fetchFn: fetchReturning(criteriaRows...)}
+	registerStub(mapper, "primary", stub)
+	ctx := context.Background()
+
+	var products []fallbackProduct
+	err := mapper.FetchMulti(ctx, "shop.replicated", nil, &products,
+		Where(adapter.Like("Name", "Al%")), Where(adapter.Ne("ID", "3")))
+	if err != nil {
+		t.Fatalf("FetchMulti() error = %v", err)
+	}
+	if len(products) != 1 || products[0].Name != "Alice" {
+		t.Errorf("products = %v, want only Alice", products)
+	}
+	if stub.lastOp().Criteria != nil {
+		t.Error("criteria passed to an adapter without native support")
+	}
+
+	// Paging applies after filtering
+	info, err := mapper.FetchPage(ctx, "shop.replicated", nil, adapter.Pagination{Limit: 1, WithTotal: true}, &products,
+		Where(adapter.In("ID", "2", "3", "4")))
+	if err != nil {
+		t.Fatalf("FetchPage() error = %v", err)
+	}
+	if len(products) != 1 || products[0].ID != "2" || info.Total != 3 || info.NextCursor == "" {
+		t.Errorf("page = %v, info = %+v; want Bob of 3", products, info)
+	}
+
+	// Single fetch returns the first match
+	var product fallbackProduct
+	if err := mapper.Fetch(ctx, "shop.replicated", nil, &product, Where(adapter.Eq("Name", "Carol"))); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if product.ID != "4" {
+		t.Errorf("product = %+v, want Carol", product)
+	}
+}
+
+func TestMapper_FetchMulti_WhereNative(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	stub := &criteriaStub{stubAdapter{fetchFn: fetchReturning(criteriaRows...)}}
+	mapper.RegisterAdapter("primary", func(config.Source) (adapter.Adapter, error) { return stub, nil })
+
+	var products []fallbackProduct
+	if err := mapper.FetchMulti(context.Background(), "shop.replicated", nil, &products, Where(adapter.Eq("Name", "Bob"))); err != nil {
+		t.Fatalf("FetchMulti() error = %v", err)
+	}
+
+	criteria := stub.lastOp().Criteria
+	if criteria == nil || criteria.Field != "name" || criteria.Value != "Bob" {
+		t.Fatalf("op.Criteria = %+v, want translated eq on name", criteria)
+	}
+	// The stub ignores the criteria, so the engine must not filter again
+	if len(products) != len(criteriaRows) {
+		t.Errorf("products = %d, want the adapter's %d results unfiltered", len(products), len(criteriaRows))
+	}
+
+	// Unsupported operators are evaluated in memory
+	if err := mapper.FetchMulti(context.Background(), "shop.replicated", nil, &products, Where(adapter.Gt("ID", "2"))); err != nil {
+		t.Fatalf("FetchMulti() error = %v", err)
+	}
+	if stub.lastOp().Criteria != nil || len(products) != 2 {
+		t.Errorf("op.Criteria = %+v, products = %v; want in-memory filtering", stub.lastOp().Criteria, products)
+	}
+}
+
+func TestMapper_FetchIter_Where(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{fetchFn: fetchReturning(criteriaRows...)})
+
+	var names []string
+	for item, err := range mapper.FetchIter(context.Background(), "shop.replicated", nil, &fallbackProduct{}, Where(adapter.Like("Name", "%o%"))) {
+		if err != nil {
+			t.Fatalf("FetchIter() error = %v", err)
+		}
+		names = append(names, item.(*fallbackProduct).Name)
+	}
+	if len(names) != 2 || names[0] != "Bob" || names[1] != "Carol" {
+		t.Errorf("names = %v, want Bob, Carol", names)
+	}
+}
+
+func TestMapper_FetchMulti_WhereInvalid(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	stub := &stubAdapter{fetchFn: fetchReturning(criteriaRows...)}
+	registerStub(mapper, "primary", stub)
+
+	tests := []struct {
+		name      string
+		criterion adapter.Criterion
+	}{
+		{"unmapped field", adapter.Eq("Price", 1)},
+		{"malformed", adapter.Criterion{Op: "between", Field: "ID"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var products []fallbackProduct
+			err := mapper.FetchMulti(context.Background(), "shop.replicated", nil, &products, Where(tt.criterion))
+			if !errors.Is(err, adapter.ErrValidation) {
+				t.Errorf("FetchMulti() error = %v, want ErrValidation", err)
+			}
+		})
+	}
+}
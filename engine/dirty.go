@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"reflect"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// partialObjects reduces the update data of each object that has a snapshot to
+// its identifier and condition fields plus the fields changed since the snapshot,
+// and marks op as a partial update restricted to the properties still sent.
+// Objects without a snapshot are sent in full. Objects without changes are left
+// out, unless the operation has condition fields (their versions still advance,
+// and the result stays aligned with the objects). It returns the data to send.
+func partialObjects(op *adapter.Operation, mapping *config.Mapping, opConfig *config.OperationConfig, dataObjects []interface{}, snapshots []map[string]interface{}) []interface{} {
+	keys := make(map[string]bool)
+	for _, mappings := range [][]config.PropertyMap{opConfig.Identifier, identityFields(mapping), opConfig.Condition} {
+		for _, pm := range mappings {
+			keys[pm.Field] = true
+		}
+	}
+
+	sent := make([]interface{}, 0, len(dataObjects))
+	fields := make(map[string]bool)
+	for i, obj := range dataObjects {
+		data := obj.(map[string]interface{})
+		if i >= len(snapshots) || snapshots[i] == nil {
+			for field := range data {
+				fields[field] = true
+			}
+			sent = append(sent, data)
+			continue
+		}
+
+		reduced := make(map[string]interface{})
+		changed := false
+		for field, value := range data {
+			if !keys[field] {
+				if stored, exists := snapshots[i][field]; exists && reflect.DeepEqual(stored, value) {
+					continue
+				}
+				changed = true
+			}
+			reduced[field] = value
+			fields[field] = true
+		}
+		op.Partial = true
+		if changed || len(opConfig.Condition) > 0 {
+			sent = append(sent, reduced)
+		}
+	}
+
+	if op.Partial {
+		properties := make([]adapter.PropertyMapping, 0, len(op.Properties))
+		for _, pm := range op.Properties {
+			if fields[pm.DataField] {
+				properties = append(properties, pm)
+			}
+		}
+		op.Properties = properties
+	}
+	return sent
+}
+
+// snapshot records the update data of a session instance, for dirty tracking.
+// Instances of mappings without an update operation are not tracked. The caller
+// must hold s.mu.
+func (s *Session) snapshot(key string, mapping *config.Mapping, obj interface{}) {
+	opConfig, exists := mapping.Operations["update"]
+	if !exists {
+		return
+	}
+	data, err := s.mapper.propMap.MapFromObject(obj, opConfig.Properties)
+	if err != nil {
+		delete(s.snapshots, key)
+		return
+	}
+	s.snapshots[key] = data
+}
+
+// snapshotsOf returns the snapshots of objects about to be updated, aligned with
+// them; objects the session has no snapshot for get nil.
+func (s *Session) snapshotsOf(mappingID string, mapping *config.Mapping, objectSlice []interface{}) []map[string]interface{} {
+	keyFields := identityFields(mapping)
+	snapshots := make([]map[string]interface{}, len(objectSlice))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, obj := range objectSlice {
+		data, err := s.mapper.propMap.MapFromObject(obj, keyFields)
+		if err != nil {
+			continue
+		}
+		if key, ok := identityKey(mappingID, keyFields, data); ok {
+			snapshots[i] = s.snapshots[key]
+		}
+	}
+	return snapshots
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+const dirtyTestConfig = `namespace: app
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: primary
+    operations:
+      fetch:
+        statement: "users/{id}.json"
+        parameters:
+          - object: ID
+            field: id
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+            - object: Email
+              field: email
+      update:
+        statement: "users/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+          - object: Email
+            field: email
+        identifier:
+          - object: ID
+            field: id
+`
+
+type dirtyUser struct {
+	ID    string
+	Name  string
+	Email string
+}
+
+func newDirtyTestMapper(t *testing.T) (*Mapper, *stubAdapter, *[]interface{}) {
+	t.Helper()
+	mapper := newTestMapper(t, dirtyTestConfig)
+	var updated []interface{}
+	primary := &stubAdapter{
+		fetchFn: fetchReturning(map[string]interface{}{"id": "1", "name": "Ada", "email": "ada@example.com"}),
+		updateFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			updated = objects
+			return nil
+		},
+	}
+	registerStub(mapper, "primary", primary)
+	return mapper, primary, &updated
+}
+
+func TestSession_Update_SendsChangedFields(t *testing.T) {
+	mapper, primary, updated := newDirtyTestMapper(t)
+	session := mapper.Session(context.Background())
+
+	var user *dirtyUser
+	if err := session.Fetch("app.user", map[string]interface{}{"id": "1"}, &user); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	user.Email = "ada@example.org"
+	if err := session.Update("app.user", user); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	op := primary.lastOp()
+	if !op.Partial {
+		t.Error("Operation.Partial = false, want a partial update")
+	}
+	want := []interface{}{map[string]interface{}{"id": "1", "email": "ada@example.org"}}
+	if !reflect.DeepEqual(*updated, want) {
+		t.Errorf("updated = %v, want %v", *updated, want)
+	}
+	var fields []string
+	for _, pm := range op.Properties {
+		fields = append(fields, pm.DataField)
+	}
+	if !reflect.DeepEqual(fields, []string{"id", "email"}) {
+		t.Errorf("operation properties = %v, want [id email]", fields)
+	}
+
+	// The update refreshes the snapshot
+	if err := session.Update("app.user", user); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if primary.callCount("update") != 1 {
+		t.Errorf("adapter updates = %d, want 1: unchanged objects are not sent", primary.callCount("update"))
+	}
+}
+
+func TestSession_Update_UntrackedObjectsInFull(t *testing.T) {
+	mapper, primary, updated := newDirtyTestMapper(t)
+	session := mapper.Session(context.Background())
+
+	user := &dirtyUser{ID: "2", Name: "Grace", Email: "grace@example.com"}
+	if err := session.Update("app.user", user); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if primary.lastOp().Partial {
+		t.Error("Operation.Partial = true, want a full update without snapshot")
+	}
+	want := []interface{}{map[string]interface{}{"id": "2", "name": "Grace", "email": "grace@example.com"}}
+	if !reflect.DeepEqual(*updated, want) {
+		t.Errorf("updated = %v, want %v", *updated, want)
+	}
+}
+
+func TestMapper_Update_AlwaysFull(t *testing.T) {
+	mapper, primary, updated := newDirtyTestMapper(t)
+	ctx := context.Background()
+
+	var user dirtyUser
+	if err := mapper.Fetch(ctx, "app.user", map[string]interface{}{"id": "1"}, &user); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	user.Name = "Ada L."
+	if err := mapper.Update(ctx, "app.user", &user); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if primary.lastOp().Partial || len((*updated)[0].(map[string]interface{})) != 3 {
+		t.Errorf("updated = %v, want the full object", *updated)
+	}
+}
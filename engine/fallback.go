@@ -0,0 +1,242 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// Source chain policies for on_miss and on_error.
+const (
+	// PolicyNext hands the request over to the next source in the chain.
+	PolicyNext = "next"
+)
+
+// sourceStep is one candidate source in a fetch's fallback chain.
+type sourceStep struct {
+	mappingID string
+	sourceID  string
+	source    config.Source
+	opConfig  *config.OperationConfig
+	result    *config.ResultConfig
+	onMiss    string
+	onError   string
+
+	// softDelete is the mapping's soft-delete setting, if any
+	softDelete *config.SoftDeleteConfig
+}
+
+// chainResult is the outcome of walking a source chain.
+type chainResult struct {
+	data     []interface{}
+	page     *adapter.PageInfo
+	sourceID string
+	opConfig *config.OperationConfig
+}
+
+// sourceChain flattens an operation into the ordered list of sources a fetch walks:
+// the operation's source (or its `sources` chain), followed by the sources of its
+// fallback operation. A single configured source hands over to the fallback on
+// both misses and errors, since configuring a fallback expresses that intent.
+// Fallback steps without a result configuration use the operation's.
+func (m *Mapper) sourceChain(mappingID string, cfg *config.Config, mapping *config.Mapping, opConfig *config.OperationConfig) ([]sourceStep, error) {
+	steps, err := m.chainSteps(cfg, mapping, opConfig)
+	if err != nil {
+		return nil, err
+	}
+	for i := range steps {
+		steps[i].mappingID = mappingID
+		steps[i].result = resultFor(steps[i].opConfig, opConfig)
+		steps[i].softDelete = mapping.SoftDelete
+	}
+	return steps, nil
+}
+
+// chainSteps builds the steps of sourceChain for an operation and its fallbacks.
+func (m *Mapper) chainSteps(cfg *config.Config, mapping *config.Mapping, opConfig *config.OperationConfig) ([]sourceStep, error) {
+	var refs []config.SourceRef
+
+	switch {
+	case opConfig.Source != "":
+		refs = []config.SourceRef{{Name: opConfig.Source, OnMiss: PolicyNext, OnError: PolicyNext}}
+	case len(opConfig.Sources) > 0:
+		refs = opConfig.Sources
+	case mapping.Source != "":
+		refs = []config.SourceRef{{Name: mapping.Source, OnMiss: PolicyNext, OnError: PolicyNext}}
+	default:
+		return nil, fmt.Errorf("no source configured for operation")
+	}
+
+	steps := make([]sourceStep, 0, len(refs))
+	for _, ref := range refs {
+		source, exists := cfg.Sources[ref.Name]
+		if !exists {
+			return nil, fmt.Errorf("source '%s' not found", ref.Name)
+		}
+		steps = append(steps, sourceStep{
+			sourceID: ref.Name,
+			source:   source,
+			opConfig: opConfig,
+			onMiss:   ref.OnMiss,
+			onError:  ref.OnError,
+		})
+	}
+
+	if opConfig.Fallback != nil {
+		fallbackSteps, err := m.chainSteps(cfg, mapping, opConfig.Fallback)
+		if err != nil {
+			return nil, fmt.Errorf("fallback: %w", err)
+		}
+		steps = append(steps, fallbackSteps...)
+	}
+
+	return steps, nil
+}
+
+// fetchFromChain walks the source chain of a fetch operation until a source answers.
+// A source that misses (ErrNotFound, or no rows for a single fetch) hands over to the
+// next source when its on_miss is "next"; a source that fails does so when its
+// on_error is "next", and a source whose circuit breaker is open always does.
+// Otherwise the miss or error is returned as-is. Validation errors describe the
+// request rather than the source and are never handed over. The operation's
+// timeout is the budget of the whole chain, split across the sources still to be
+// tried. Operations with a query cache answer from it when they can.
+func (m *Mapper) fetchFromChain(ctx context.Context, mappingID string, cfg *config.Config, mapping *config.Mapping, opConfig *config.OperationConfig, multi bool, params map[string]interface{}, options *fetchOptions) (*chainResult, error) {
+	steps, err := m.sourceChain(mappingID, cfg, mapping, opConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve source for fetch: %w", err)
+	}
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	return m.cachedFetch(ctx, mappingID, opConfig, multi, params, options, func() (*chainResult, error) {
+		ctx, cancel := withOperationTimeout(ctx, opConfig.Timeout)
+		defer cancel()
+		return m.walkChain(ctx, steps, multi, params, options)
+	})
+}
+
+// walkChain fetches from the steps of a source chain in order until one answers.
+func (m *Mapper) walkChain(ctx context.Context, steps []sourceStep, multi bool, params map[string]interface{}, options *fetchOptions) (*chainResult, error) {
+	var lastErr error
+	for i, step := range steps {
+		last := i == len(steps)-1
+
+		stepCtx, cancel := stepContext(ctx, len(steps)-i)
+		data, page, err := m.fetchFromSource(stepCtx, step, multi, params, options)
+		cancel()
+		if err == nil {
+			return &chainResult{data: data, page: page, sourceID: step.sourceID, opConfig: step.opConfig}, nil
+		}
+		lastErr = err
+
+		if isNotFound(err) {
+			if step.onMiss == PolicyNext && !last {
+				continue
+			}
+			return nil, err
+		}
+
+		// An open circuit never reached its source, so the next one is always tried
+		if (step.onError == PolicyNext || isCircuitOpen(err)) && !last && !isValidation(err) {
+			continue
+		}
+		return nil, err
+	}
+
+	return nil, lastErr
+}
+
+// fetchFromSource runs a fetch against a single source of a chain.
+// Criteria are passed to adapters that support them and applied in memory otherwise.
+// A paged multi-result fetch uses the adapter's Paginator when it has one and the
+// criteria (if any) are native, and is paged in memory otherwise.
+func (m *Mapper) fetchFromSource(ctx context.Context, step sourceStep, multi bool, params map[string]interface{}, options *fetchOptions) ([]interface{}, *adapter.PageInfo, error) {
+	adp, err := m.access(ctx, step.source, step.sourceID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	op := m.buildOperation(adapter.OpFetch, step.opConfig)
+	op.Multi = multi
+	op.Source = step.sourceID
+
+	criteria, native, err := stepCriteria(adp, step, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if native {
+		op.Criteria = criteria
+	}
+
+	inv := m.newInvocation(step.mappingID, step.sourceID, op)
+	inv.Params = params
+
+	if paginator, ok := adp.(adapter.Paginator); ok && multi && options.page != nil && (criteria == nil || native) {
+		op.Page = options.page
+		err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+			data, page, err := paginator.FetchPage(ctx, inv.Operation, inv.Params)
+			inv.Result, inv.PageInfo = data, page
+			return err
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetch failed: %w", err)
+		}
+		data, _ := inv.Result.([]interface{})
+		return data, inv.PageInfo, nil
+	}
+
+	err = m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		data, err := adp.Fetch(ctx, inv.Operation, inv.Params)
+		inv.Result = data
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch failed: %w", err)
+	}
+	data, _ := inv.Result.([]interface{})
+
+	if criteria != nil && !native {
+		if data, err = filterData(data, criteria); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if !multi && len(data) == 0 {
+		return nil, nil, adapter.ErrNotFound
+	}
+
+	if multi && options.page != nil {
+		return pageInMemory(data, options.page)
+	}
+
+	return data, nil, nil
+}
+
+// isNotFound reports whether err signals a missing object.
+func isNotFound(err error) bool {
+	return errorCode(err) == adapter.ErrNotFound.Code
+}
+
+// isValidation reports whether err rejects the request itself.
+func isValidation(err error) bool {
+	return errorCode(err) == adapter.ErrValidation.Code
+}
+
+// isCircuitOpen reports whether err was refused by an open circuit breaker.
+func isCircuitOpen(err error) bool {
+	return errorCode(err) == adapter.ErrCircuitOpen.Code
+}
+
+// errorCode returns the code of the first AdapterError in err's chain, or "".
+func errorCode(err error) string {
+	var adapterErr *adapter.AdapterError
+	if errors.As(err, &adapterErr) {
+		return adapterErr.Code
+	}
+	return ""
+}
@@ -0,0 +1,232 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+const fallbackTestConfig = `namespace: shop
+version: "1.0"
+sources:
+  cache:
+    adapter: cache
+    connection: "memory"
+  primary:
+    adapter: primary
+    connection: "localhost"
+  backup:
+    adapter: backup
+    connection: "localhost"
+mappings:
+  product:
+    object: Product
+    operations:
+      fetch:
+        sources:
+          - name: cache
+            on_miss: next
+            on_error: next
+          - name: primary
+        statement: "products/{id}.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+  strict:
+    object: Product
+    operations:
+      fetch:
+        sources:
+          - name: cache
+          - name: primary
+        statement: "products/{id}.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+  replicated:
+    object: Product
+    source: primary
+    operations:
+      fetch:
+        statement: "SELECT * FROM products WHERE id = {id}"
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+        fallback:
+          source: backup
+          statement: "SELECT * FROM products_archive WHERE id = {id}"
+`
+
+type fallbackProduct struct {
+	ID   string
+	Name string
+}
+
+func fetchReturning(rows ...map[string]interface{}) func(context.Context, *adapter.Operation, map[string]interface{}) ([]interface{}, error) {
+	return func(context.Context, *adapter.Operation, map[string]interface{}) ([]interface{}, error) {
+		results := make([]interface{}, len(rows))
+		for i, row := range rows {
+			results[i] = row
+		}
+		return results, nil
+	}
+}
+
+func fetchFailing(err error) func(context.Context, *adapter.Operation, map[string]interface{}) ([]interface{}, error) {
+	return func(context.Context, *adapter.Operation, map[string]interface{}) ([]interface{}, error) {
+		return nil, err
+	}
+}
+
+func TestMapper_Fetch_FallbackChain(t *testing.T) {
+	tests := []struct {
+		name       string
+		cacheFetch func(context.Context, *adapter.Operation, map[string]interface{}) ([]interface{}, error)
+		wantSource string
+		wantName   string
+	}{
+		{
+			name:       "cache hit",
+			cacheFetch: fetchReturning(map[string]interface{}{"id": "1", "name": "cached"}),
+			wantSource: "cache",
+			wantName:   "cached",
+		},
+		{
+			name:       "cache miss via ErrNotFound",
+			cacheFetch: fetchFailing(adapter.ErrNotFound),
+			wantSource: "primary",
+			wantName:   "fresh",
+		},
+		{
+			name:       "cache miss via empty result",
+			cacheFetch: fetchReturning(),
+			wantSource: "primary",
+			wantName:   "fresh",
+		},
+		{
+			name:       "cache error",
+			cacheFetch: fetchFailing(adapter.ErrConnection),
+			wantSource: "primary",
+			wantName:   "fresh",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := newTestMapper(t, fallbackTestConfig)
+			cache := &stubAdapter{fetchFn: tt.cacheFetch}
+			primary := &stubAdapter{fetchFn: fetchReturning(map[string]interface{}{"id": "1", "name": "fresh"})}
+			registerStub(mapper, "cache", cache)
+			registerStub(mapper, "primary", primary)
+
+			var product fallbackProduct
+			var sourceID string
+			err := mapper.Fetch(context.Background(), "shop.product", map[string]interface{}{"id": "1"}, &product, ReportSource(&sourceID))
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if sourceID != tt.wantSource {
+				t.Errorf("sourceID = %q, want %q", sourceID, tt.wantSource)
+			}
+			if product.Name != tt.wantName {
+				t.Errorf("Name = %q, want %q", product.Name, tt.wantName)
+			}
+			if op := cache.lastOp(); op == nil || op.Source != "cache" {
+				t.Errorf("cache operation source = %+v, want cache", op)
+			}
+		})
+	}
+}
+
+func TestMapper_Fetch_FallbackChain_StopsWithoutPolicy(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	cache := &stubAdapter{fetchFn: fetchFailing(adapter.ErrNotFound)}
+	primary := &stubAdapter{fetchFn: fetchReturning(map[string]interface{}{"id": "1"})}
+	registerStub(mapper, "cache", cache)
+	registerStub(mapper, "primary", primary)
+
+	var product fallbackProduct
+	err := mapper.Fetch(context.Background(), "shop.strict", map[string]interface{}{"id": "1"}, &product)
+	if !errors.Is(err, adapter.ErrNotFound) {
+		t.Errorf("Fetch() error = %v, want ErrNotFound", err)
+	}
+	if primary.callCount("fetch") != 0 {
+		t.Error("primary should not be consulted without on_miss: next")
+	}
+
+	cache.fetchFn = fetchFailing(adapter.ErrConnection)
+	err = mapper.Fetch(context.Background(), "shop.strict", map[string]interface{}{"id": "1"}, &product)
+	if !errors.Is(err, adapter.ErrConnection) {
+		t.Errorf("Fetch() error = %v, want ErrConnection", err)
+	}
+	if primary.callCount("fetch") != 0 {
+		t.Error("primary should not be consulted without on_error: next")
+	}
+}
+
+func TestMapper_Fetch_FallbackOperation(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	primary := &stubAdapter{fetchFn: fetchFailing(adapter.ErrConnection)}
+	backup := &stubAdapter{fetchFn: fetchReturning(map[string]interface{}{"id": "9", "name": "archived"})}
+	registerStub(mapper, "primary", primary)
+	registerStub(mapper, "backup", backup)
+
+	var product fallbackProduct
+	var sourceID string
+	err := mapper.Fetch(context.Background(), "shop.replicated", map[string]interface{}{"id": "9"}, &product, ReportSource(&sourceID))
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if sourceID != "backup" {
+		t.Errorf("sourceID = %q, want backup", sourceID)
+	}
+	if product.Name != "archived" {
+		t.Errorf("Name = %q, want archived", product.Name)
+	}
+
+	if op := primary.lastOp(); op.Fallback == nil || op.Fallback.Source != "backup" {
+		t.Errorf("primary operation Fallback = %+v, want backup operation", op.Fallback)
+	}
+	if op := backup.lastOp(); op.Statement != "SELECT * FROM products_archive WHERE id = {id}" {
+		t.Errorf("backup statement = %q", op.Statement)
+	}
+}
+
+func TestMapper_FetchMulti_FallbackChain(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	cache := &stubAdapter{fetchFn: fetchFailing(adapter.ErrNotFound)}
+	primary := &stubAdapter{fetchFn: fetchReturning(
+		map[string]interface{}{"id": "1"},
+		map[string]interface{}{"id": "2"},
+	)}
+	registerStub(mapper, "cache", cache)
+	registerStub(mapper, "primary", primary)
+
+	var products []map[string]interface{}
+	var sourceID string
+	if err := mapper.FetchMulti(context.Background(), "shop.product", nil, &products, ReportSource(&sourceID)); err != nil {
+		t.Fatalf("FetchMulti() error = %v", err)
+	}
+	if sourceID != "primary" || len(products) != 2 {
+		t.Errorf("sourceID = %q, len = %d; want primary, 2", sourceID, len(products))
+	}
+
+	// An empty list is an answer, not a miss
+	cache.fetchFn = fetchReturning()
+	products = nil
+	if err := mapper.FetchMulti(context.Background(), "shop.product", nil, &products, ReportSource(&sourceID)); err != nil {
+		t.Fatalf("FetchMulti() error = %v", err)
+	}
+	if sourceID != "cache" {
+		t.Errorf("sourceID = %q, want cache", sourceID)
+	}
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+	"github.com/toutaio/toutago-datamapper/filesystem"
+)
+
+// generatingStub is a stub adapter that reports generated values on insert.
+type generatingStub struct {
+	stubAdapter
+	next int
+}
+
+func (g *generatingStub) InsertGenerated(ctx context.Context, op *adapter.Operation, objects []interface{}) ([]map[string]interface{}, error) {
+	g.record("insert", op)
+	generated := make([]map[string]interface{}, len(objects))
+	for i := range objects {
+		g.next++
+		generated[i] = map[string]interface{}{
+			"id":         int64(g.next),
+			"created_at": "2026-01-02T03:04:05Z",
+			"slug":       "generated",
+		}
+	}
+	return generated, nil
+}
+
+const generatedTestConfig = `namespace: test
+version: "1.0"
+sources:
+  db:
+    adapter: gen
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: db
+    operations:
+      insert:
+        statement: "INSERT INTO users (name) VALUES ({name})"
+        properties:
+          - object: Name
+            field: name
+          - object: Slug
+            field: slug
+            generated: true
+        generated:
+          - object: ID
+            field: id
+          - object: CreatedAt
+            field: created_at
+            type: timestamp
+`
+
+type generatedUser struct {
+	ID        int
+	Name      string
+	Slug      string
+	CreatedAt time.Time
+}
+
+func TestMapper_Insert_WritesGeneratedValues(t *testing.T) {
+	mapper := newTestMapper(t, generatedTestConfig)
+	stub := &generatingStub{}
+	mapper.RegisterAdapter("gen", func(source config.Source) (adapter.Adapter, error) {
+		return stub, nil
+	})
+	ctx := context.Background()
+
+	user := &generatedUser{Name: "Alice"}
+	if err := mapper.Insert(ctx, "test.user", user); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if user.ID != 1 || user.Slug != "generated" {
+		t.Errorf("user = %+v, want generated ID and Slug", user)
+	}
+	if !user.CreatedAt.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("CreatedAt = %v", user.CreatedAt)
+	}
+	if op := stub.lastOp(); len(op.Generated) != 2 {
+		t.Errorf("op.Generated = %+v, want 2 mappings", op.Generated)
+	}
+
+	// Slices of structs receive values element by element
+	users := []generatedUser{{Name: "Bob"}, {Name: "Carol"}}
+	if err := mapper.Insert(ctx, "test.user", users); err != nil {
+		t.Fatalf("Insert(slice) error = %v", err)
+	}
+	if users[0].ID != 2 || users[1].ID != 3 {
+		t.Errorf("users = %+v, want IDs 2 and 3", users)
+	}
+
+	userPtrs := []*generatedUser{{Name: "Dave"}}
+	if err := mapper.Insert(ctx, "test.user", userPtrs); err != nil {
+		t.Fatalf("Insert(pointer slice) error = %v", err)
+	}
+	if userPtrs[0].ID != 4 {
+		t.Errorf("userPtrs[0].ID = %d, want 4", userPtrs[0].ID)
+	}
+
+	// Objects passed by value cannot receive generated values
+	byValue := generatedUser{Name: "Eve"}
+	if err := mapper.Insert(ctx, "test.user", byValue); err != nil {
+		t.Fatalf("Insert(value) error = %v", err)
+	}
+	if byValue.ID != 0 {
+		t.Errorf("byValue.ID = %d, want 0", byValue.ID)
+	}
+}
+
+func TestMapper_Insert_GeneratedIntegerID(t *testing.T) {
+	tmpDir := t.TempDir()
+	configContent := fmt.Sprintf(`namespace: test
+version: "1.0"
+sources:
+  files:
+    adapter: filesystem
+    connection: %q
+mappings:
+  user:
+    object: User
+    source: files
+    operations:
+      insert:
+        statement: "users/{id}.json"
+        properties:
+          - object: Name
+            field: name
+          - object: Token
+            field: token
+            generated: true
+        generated:
+          - object: ID
+            field: id
+          - object: CreatedAt
+            field: created_at
+            type: timestamp
+`, tmpDir)
+	mapper := newTestMapper(t, configContent)
+	mapper.RegisterAdapter("filesystem", func(source config.Source) (adapter.Adapter, error) {
+		return filesystem.NewFilesystemAdapter(source.Connection)
+	})
+
+	type user struct {
+		ID        int64
+		Name      string
+		Token     string
+		CreatedAt time.Time
+	}
+
+	ctx := context.Background()
+	users := []*user{{Name: "First"}, {Name: "Second"}}
+	if err := mapper.Insert(ctx, "test.user", users); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	if users[0].ID == 0 || users[1].ID == 0 || users[0].ID == users[1].ID {
+		t.Errorf("generated IDs = %d, %d, want distinct non-zero", users[0].ID, users[1].ID)
+	}
+	if users[0].ID >= 1<<53 {
+		t.Errorf("generated ID %d is not exactly representable as a JSON number", users[0].ID)
+	}
+	if len(users[0].Token) != 32 {
+		t.Errorf("generated Token = %q, want 32 hex chars", users[0].Token)
+	}
+	if users[0].CreatedAt.IsZero() {
+		t.Error("generated CreatedAt should be set")
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "users", fmt.Sprintf("%d.json", users[0].ID)))
+	if err != nil {
+		t.Fatalf("file for generated id not written: %v", err)
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("stored document: %v", err)
+	}
+	if id, _ := stored["id"].(float64); int64(id) != users[0].ID {
+		t.Errorf("stored id = %v, want %d", stored["id"], users[0].ID)
+	}
+}
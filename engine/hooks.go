@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// BeforeInserter is implemented by objects that prepare themselves before Insert
+// maps them to data. An error aborts the insert before the adapter is called.
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+// AfterInserter is implemented by objects notified once Insert has stored them and
+// written generated values back. An error is returned to the caller, but the insert
+// has already been applied.
+type AfterInserter interface {
+	AfterInsert(ctx context.Context) error
+}
+
+// BeforeUpdater is implemented by objects that prepare themselves before Update
+// maps them to data. An error aborts the update before the adapter is called.
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// AfterUpdater is implemented by objects notified once Update has stored them.
+// An error is returned to the caller, but the update has already been applied.
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context) error
+}
+
+// BeforeDeleter is implemented by identifier objects checked before Delete.
+// An error aborts the delete before the adapter is called.
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// AfterDeleter is implemented by identifier objects notified once Delete has
+// removed them. An error is returned to the caller, but the delete has already
+// been applied.
+type AfterDeleter interface {
+	AfterDelete(ctx context.Context) error
+}
+
+// AfterFetcher is implemented by objects that complete themselves once a fetch
+// has mapped them (and loaded their included relations), e.g. to compute derived
+// fields. An error fails the fetch.
+type AfterFetcher interface {
+	AfterFetch(ctx context.Context) error
+}
+
+// Lifecycle hook names, as reported in hook errors.
+const (
+	hookBeforeInsert = "BeforeInsert"
+	hookAfterInsert  = "AfterInsert"
+	hookBeforeUpdate = "BeforeUpdate"
+	hookAfterUpdate  = "AfterUpdate"
+	hookBeforeDelete = "BeforeDelete"
+	hookAfterDelete  = "AfterDelete"
+	hookAfterFetch   = "AfterFetch"
+)
+
+// runHooks calls a lifecycle hook on each object implementing it, stopping at the
+// first error. Hooks with pointer receivers are only found on objects passed by pointer.
+func runHooks(ctx context.Context, hook string, objects []interface{}) error {
+	for i, obj := range objects {
+		var err error
+		switch hook {
+		case hookBeforeInsert:
+			if h, ok := obj.(BeforeInserter); ok {
+				err = h.BeforeInsert(ctx)
+			}
+		case hookAfterInsert:
+			if h, ok := obj.(AfterInserter); ok {
+				err = h.AfterInsert(ctx)
+			}
+		case hookBeforeUpdate:
+			if h, ok := obj.(BeforeUpdater); ok {
+				err = h.BeforeUpdate(ctx)
+			}
+		case hookAfterUpdate:
+			if h, ok := obj.(AfterUpdater); ok {
+				err = h.AfterUpdate(ctx)
+			}
+		case hookBeforeDelete:
+			if h, ok := obj.(BeforeDeleter); ok {
+				err = h.BeforeDelete(ctx)
+			}
+		case hookAfterDelete:
+			if h, ok := obj.(AfterDeleter); ok {
+				err = h.AfterDelete(ctx)
+			}
+		case hookAfterFetch:
+			if h, ok := obj.(AfterFetcher); ok {
+				err = h.AfterFetch(ctx)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s hook failed for object %d: %w", hook, i, err)
+		}
+	}
+	return nil
+}
+
+// fetchedObjects returns the objects fetched into a pointer to a slice, as pointers
+// where the elements are addressable structs, so that pointer-receiver hooks apply.
+func fetchedObjects(results interface{}) []interface{} {
+	slice := reflect.ValueOf(results)
+	if slice.Kind() != reflect.Ptr || slice.IsNil() || slice.Elem().Kind() != reflect.Slice {
+		return nil
+	}
+	slice = slice.Elem()
+
+	objects := make([]interface{}, slice.Len())
+	for i := range objects {
+		elem := slice.Index(i)
+		if elem.Kind() != reflect.Ptr && elem.Kind() != reflect.Interface && elem.CanAddr() {
+			elem = elem.Addr()
+		}
+		objects[i] = elem.Interface()
+	}
+	return objects
+}
@@ -0,0 +1,213 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+const hooksTestConfig = `namespace: app
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: primary
+    operations:
+      fetch:
+        statement: "users/{id}.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Email
+              field: email
+      insert:
+        statement: "users/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Email
+            field: email
+      update:
+        statement: "users/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Email
+            field: email
+      delete:
+        statement: "users/{id}.json"
+        identifier:
+          - object: ID
+            field: id
+`
+
+var errHookRejected = errors.New("rejected")
+
+type hookUser struct {
+	ID     string
+	Email  string
+	Domain string
+
+	calls []string
+}
+
+func (u *hookUser) BeforeInsert(ctx context.Context) error {
+	u.calls = append(u.calls, "BeforeInsert")
+	if u.Email == "" {
+		return errHookRejected
+	}
+	u.Email = strings.ToLower(u.Email)
+	return nil
+}
+
+func (u *hookUser) AfterInsert(ctx context.Context) error {
+	u.calls = append(u.calls, "AfterInsert")
+	return nil
+}
+
+func (u *hookUser) BeforeUpdate(ctx context.Context) error {
+	u.calls = append(u.calls, "BeforeUpdate")
+	if u.Email == "" {
+		return errHookRejected
+	}
+	return nil
+}
+
+func (u *hookUser) AfterUpdate(ctx context.Context) error {
+	u.calls = append(u.calls, "AfterUpdate")
+	return nil
+}
+
+func (u *hookUser) BeforeDelete(ctx context.Context) error {
+	u.calls = append(u.calls, "BeforeDelete")
+	if u.ID == "admin" {
+		return errHookRejected
+	}
+	return nil
+}
+
+func (u *hookUser) AfterDelete(ctx context.Context) error {
+	u.calls = append(u.calls, "AfterDelete")
+	return nil
+}
+
+func (u *hookUser) AfterFetch(ctx context.Context) error {
+	if _, domain, ok := strings.Cut(u.Email, "@"); ok {
+		u.Domain = domain
+		return nil
+	}
+	return errHookRejected
+}
+
+func newHooksTestMapper(t *testing.T) (*Mapper, *stubAdapter) {
+	t.Helper()
+	mapper := newTestMapper(t, hooksTestConfig)
+	primary := &stubAdapter{fetchFn: fetchReturning(
+		map[string]interface{}{"id": "1", "email": "ada@example.com"},
+		map[string]interface{}{"id": "2", "email": "bob@example.org"},
+	)}
+	registerStub(mapper, "primary", primary)
+	return mapper, primary
+}
+
+func TestMapper_Hooks_Insert(t *testing.T) {
+	mapper, primary := newHooksTestMapper(t)
+	var stored []interface{}
+	primary.insertFn = func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+		stored = objects
+		return nil
+	}
+
+	user := &hookUser{ID: "1", Email: "Ada@Example.COM"}
+	if err := mapper.Insert(context.Background(), "app.user", user); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	if got := stored[0].(map[string]interface{})["email"]; got != "ada@example.com" {
+		t.Errorf("stored email = %v, want the normalized email", got)
+	}
+	if strings.Join(user.calls, ",") != "BeforeInsert,AfterInsert" {
+		t.Errorf("hook calls = %v", user.calls)
+	}
+}
+
+func TestMapper_Hooks_AbortBeforeAdapter(t *testing.T) {
+	mapper, primary := newHooksTestMapper(t)
+	ctx := context.Background()
+
+	if err := mapper.Insert(ctx, "app.user", []*hookUser{{ID: "1", Email: "a@b.c"}, {ID: "2"}}); !errors.Is(err, errHookRejected) {
+		t.Errorf("Insert() error = %v, want the hook error", err)
+	}
+	if err := mapper.Update(ctx, "app.user", &hookUser{ID: "1"}); !errors.Is(err, errHookRejected) {
+		t.Errorf("Update() error = %v, want the hook error", err)
+	}
+	if err := mapper.Delete(ctx, "app.user", &hookUser{ID: "admin"}); !errors.Is(err, errHookRejected) {
+		t.Errorf("Delete() error = %v, want the hook error", err)
+	}
+
+	for _, call := range []string{"insert", "update", "delete"} {
+		if primary.callCount(call) != 0 {
+			t.Errorf("adapter %s should not be called when a hook fails", call)
+		}
+	}
+}
+
+func TestMapper_Hooks_UpdateDelete(t *testing.T) {
+	mapper, _ := newHooksTestMapper(t)
+	ctx := context.Background()
+
+	user := &hookUser{ID: "1", Email: "ada@example.com"}
+	if err := mapper.Update(ctx, "app.user", user); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := mapper.Delete(ctx, "app.user", user); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if strings.Join(user.calls, ",") != "BeforeUpdate,AfterUpdate,BeforeDelete,AfterDelete" {
+		t.Errorf("hook calls = %v", user.calls)
+	}
+}
+
+func TestMapper_Hooks_AfterFetch(t *testing.T) {
+	mapper, primary := newHooksTestMapper(t)
+	ctx := context.Background()
+
+	var user hookUser
+	if err := mapper.Fetch(ctx, "app.user", map[string]interface{}{"id": "1"}, &user); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if user.Domain != "example.com" {
+		t.Errorf("Domain = %q, want example.com", user.Domain)
+	}
+
+	var users []hookUser
+	if err := mapper.FetchMulti(ctx, "app.user", nil, &users); err != nil {
+		t.Fatalf("FetchMulti() error = %v", err)
+	}
+	if len(users) != 2 || users[0].Domain != "example.com" || users[1].Domain != "example.org" {
+		t.Errorf("users = %+v, want domains set", users)
+	}
+
+	for item, err := range mapper.FetchIter(ctx, "app.user", nil, &hookUser{}) {
+		if err != nil {
+			t.Fatalf("FetchIter() error = %v", err)
+		}
+		if item.(*hookUser).Domain == "" {
+			t.Errorf("FetchIter() item %+v has no domain", item)
+		}
+	}
+
+	// A failing hook fails the fetch
+	primary.fetchFn = fetchReturning(map[string]interface{}{"id": "3", "email": "invalid"})
+	if err := mapper.Fetch(ctx, "app.user", map[string]interface{}{"id": "3"}, &user); !errors.Is(err, errHookRejected) {
+		t.Errorf("Fetch() error = %v, want the hook error", err)
+	}
+}
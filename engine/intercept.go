@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+// Invocation describes one call from the mapper to an adapter.
+// Interceptors may change its inputs before calling next, and its Result after.
+type Invocation struct {
+	// MappingID is the fully qualified mapping ID ("namespace.mapping").
+	MappingID string
+
+	// Type is the kind of call: fetch, insert, update, delete or action.
+	Type adapter.OperationType
+
+	// SourceID is the source the call goes to.
+	SourceID string
+
+	// Statement is the operation or action statement with credentials removed,
+	// suitable for logging.
+	Statement string
+
+	// Operation is passed to the adapter for fetch, insert, update and delete calls.
+	Operation *adapter.Operation
+
+	// Action is passed to the adapter for action calls.
+	Action *adapter.Action
+
+	// Params holds the parameters of fetch and action calls.
+	Params map[string]interface{}
+
+	// Objects holds the data maps of insert and update calls and the
+	// identifiers of delete calls.
+	Objects []interface{}
+
+	// Idempotent reports a call that may safely be repeated: every fetch, and
+	// writes and actions whose configuration is marked idempotent. Only idempotent
+	// calls are retried by the source's retry policy.
+	Idempotent bool
+
+	// Streaming reports a fetch made for FetchIter, whose Result is an adapter.Cursor.
+	Streaming bool
+
+	// Result is set once the call returns: the []interface{} results of a fetch
+	// (an adapter.Cursor when Streaming), the generated values of an insert as
+	// []map[string]interface{} (possibly nil), or the value returned by an action.
+	// Updates and deletes have no result.
+	Result interface{}
+
+	// PageInfo is set by paged fetches (Operation.Page set) that the adapter paged natively.
+	PageInfo *adapter.PageInfo
+}
+
+// Invoker performs an adapter call described by an Invocation.
+type Invoker func(ctx context.Context, inv *Invocation) error
+
+// Interceptor wraps every adapter call made by the mapper. It calls next to
+// continue the call and may inspect or change inv before and after it, or return
+// without calling next to short-circuit the call, setting inv.Result itself.
+//
+//	mapper.Use(func(ctx context.Context, inv *engine.Invocation, next engine.Invoker) error {
+//		start := time.Now()
+//		err := next(ctx, inv)
+//		log.Printf("%s %s on %s took %s: %v", inv.Type, inv.MappingID, inv.SourceID, time.Since(start), err)
+//		return err
+//	})
+type Interceptor func(ctx context.Context, inv *Invocation, next Invoker) error
+
+// Use registers interceptors around adapter calls. Interceptors run in the order
+// they were registered, the first one outermost. They also wrap the adapter calls
+// made by after-action handlers.
+func (m *Mapper) Use(interceptors ...Interceptor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.interceptors = append(m.interceptors, interceptors...)
+}
+
+// invoke runs call through the registered interceptors. The call itself is guarded
+// by the source's circuit breaker and retried according to its retry policy, so
+// interceptors see a single call. Unless the operation or action sets its own
+// timeout, the source's timeout bounds the whole call, except for streaming
+// fetches whose cursor outlives it.
+func (m *Mapper) invoke(ctx context.Context, inv *Invocation, call Invoker) error {
+	m.mu.RLock()
+	interceptors := m.interceptors
+	m.mu.RUnlock()
+
+	if source, exists := m.invocationSource(inv); exists && !inv.Streaming && !hasOperationTimeout(ctx) {
+		var cancel context.CancelFunc
+		ctx, cancel = withTimeout(ctx, source.Timeout)
+		defer cancel()
+	}
+
+	if breaker := m.registry.breaker(inv.SourceID); breaker != nil {
+		call = withBreaker(breaker, call)
+	}
+	if policy := m.retryPolicyFor(ctx, inv); policy != nil {
+		call = withRetry(policy, call)
+	}
+
+	next := call
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := interceptors[i], next
+		next = func(ctx context.Context, inv *Invocation) error {
+			return interceptor(ctx, inv, inner)
+		}
+	}
+	return next(ctx, inv)
+}
+
+// newInvocation describes a call of op on a source.
+func (m *Mapper) newInvocation(mappingID, sourceID string, op *adapter.Operation) *Invocation {
+	return &Invocation{
+		MappingID:  mappingID,
+		Type:       op.Type,
+		SourceID:   sourceID,
+		Statement:  m.parser.Sanitize(op.Statement),
+		Operation:  op,
+		Idempotent: op.Type == adapter.OpFetch,
+	}
+}
+
+// newActionInvocation describes a call of action on a source.
+func (m *Mapper) newActionInvocation(mappingID, sourceID string, action *adapter.Action, params map[string]interface{}) *Invocation {
+	return &Invocation{
+		MappingID: mappingID,
+		Type:      adapter.OpAction,
+		SourceID:  sourceID,
+		Statement: m.parser.Sanitize(action.Statement),
+		Action:    action,
+		Params:    params,
+	}
+}
+
+// interceptedAdapter routes the data calls of an adapter through the mapper's
+// interceptors. It is handed to after-action handlers.
+type interceptedAdapter struct {
+	adapter.Adapter
+	mapper    *Mapper
+	mappingID string
+	sourceID  string
+}
+
+// Fetch retrieves objects through the interceptors.
+func (a *interceptedAdapter) Fetch(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+	inv := a.mapper.newInvocation(a.mappingID, a.sourceID, op)
+	inv.Params = params
+	err := a.mapper.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		data, err := a.Adapter.Fetch(ctx, inv.Operation, inv.Params)
+		inv.Result = data
+		return err
+	})
+	data, _ := inv.Result.([]interface{})
+	return data, err
+}
+
+// Insert creates objects through the interceptors.
+func (a *interceptedAdapter) Insert(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	inv := a.mapper.newInvocation(a.mappingID, a.sourceID, op)
+	inv.Objects = objects
+	return a.mapper.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		return a.Adapter.Insert(ctx, inv.Operation, inv.Objects)
+	})
+}
+
+// Update modifies objects through the interceptors.
+func (a *interceptedAdapter) Update(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	inv := a.mapper.newInvocation(a.mappingID, a.sourceID, op)
+	inv.Objects = objects
+	return a.mapper.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		return a.Adapter.Update(ctx, inv.Operation, inv.Objects)
+	})
+}
+
+// Delete removes objects through the interceptors.
+func (a *interceptedAdapter) Delete(ctx context.Context, op *adapter.Operation, identifiers []interface{}) error {
+	inv := a.mapper.newInvocation(a.mappingID, a.sourceID, op)
+	inv.Objects = identifiers
+	return a.mapper.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		return a.Adapter.Delete(ctx, inv.Operation, inv.Objects)
+	})
+}
+
+// Execute runs an action through the interceptors.
+func (a *interceptedAdapter) Execute(ctx context.Context, action *adapter.Action, params map[string]interface{}) (interface{}, error) {
+	inv := a.mapper.newActionInvocation(a.mappingID, a.sourceID, action, params)
+	err := a.mapper.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		result, err := a.Adapter.Execute(ctx, inv.Action, inv.Params)
+		inv.Result = result
+		return err
+	})
+	return inv.Result, err
+}
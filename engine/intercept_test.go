@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+func TestMapper_Use_Order(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{fetchFn: fetchReturning(map[string]interface{}{"id": "1", "name": "Desk"})})
+
+	var calls []string
+	trace := func(name string) Interceptor {
+		return func(ctx context.Context, inv *Invocation, next Invoker) error {
+			calls = append(calls, name+" before")
+			err := next(ctx, inv)
+			calls = append(calls, name+" after")
+			return err
+		}
+	}
+	mapper.Use(trace("outer"), trace("inner"))
+
+	var product fallbackProduct
+	if err := mapper.Fetch(context.Background(), "shop.replicated", map[string]interface{}{"id": "1"}, &product); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	want := []string{"outer before", "inner before", "inner after", "outer after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestMapper_Use_Invocation(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{fetchFn: fetchReturning(map[string]interface{}{"id": "1", "name": "Desk"})})
+
+	var seen Invocation
+	mapper.Use(func(ctx context.Context, inv *Invocation, next Invoker) error {
+		err := next(ctx, inv)
+		seen = *inv
+		return err
+	})
+
+	var product fallbackProduct
+	if err := mapper.Fetch(context.Background(), "shop.replicated", map[string]interface{}{"id": "1"}, &product); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	if seen.MappingID != "shop.replicated" || seen.SourceID != "primary" || seen.Type != adapter.OpFetch {
+		t.Errorf("invocation = %s %s on %s, want fetch shop.replicated on primary", seen.Type, seen.MappingID, seen.SourceID)
+	}
+	if seen.Statement != "SELECT * FROM products WHERE id = {id}" {
+		t.Errorf("Statement = %q", seen.Statement)
+	}
+	if seen.Params["id"] != "1" {
+		t.Errorf("Params = %v, want id=1", seen.Params)
+	}
+	if data, ok := seen.Result.([]interface{}); !ok || len(data) != 1 {
+		t.Errorf("Result = %v, want the fetched row", seen.Result)
+	}
+}
+
+func TestMapper_Use_ShortCircuit(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	primary := &stubAdapter{fetchFn: fetchFailing(adapter.ErrConnection)}
+	registerStub(mapper, "primary", primary)
+
+	mapper.Use(func(ctx context.Context, inv *Invocation, next Invoker) error {
+		inv.Result = []interface{}{map[string]interface{}{"id": "1", "name": "Cached"}}
+		return nil
+	})
+
+	var product fallbackProduct
+	if err := mapper.Fetch(context.Background(), "shop.replicated", map[string]interface{}{"id": "1"}, &product); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if product.Name != "Cached" {
+		t.Errorf("Name = %q, want Cached", product.Name)
+	}
+	if primary.callCount("fetch") != 0 {
+		t.Error("adapter should not be called when an interceptor short-circuits")
+	}
+}
+
+func TestMapper_Use_ModifiesParams(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	var got map[string]interface{}
+	registerStub(mapper, "primary", &stubAdapter{
+		fetchFn: func(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+			got = params
+			return []interface{}{map[string]interface{}{"id": params["id"]}}, nil
+		},
+	})
+
+	mapper.Use(func(ctx context.Context, inv *Invocation, next Invoker) error {
+		inv.Params = map[string]interface{}{"id": "2"}
+		return next(ctx, inv)
+	})
+
+	var product fallbackProduct
+	if err := mapper.Fetch(context.Background(), "shop.replicated", map[string]interface{}{"id": "1"}, &product); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if got["id"] != "2" || product.ID != "2" {
+		t.Errorf("adapter params = %v, product = %+v, want id=2", got, product)
+	}
+}
+
+func TestMapper_Use_Errors(t *testing.T) {
+	mapper := newTestMapper(t, afterTestConfig)
+	primary := &stubAdapter{}
+	registerStub(mapper, "primary", primary)
+	registerStub(mapper, "cache", &stubAdapter{})
+
+	denied := errors.New("denied")
+	mapper.Use(func(ctx context.Context, inv *Invocation, next Invoker) error {
+		if inv.Type == adapter.OpDelete {
+			return denied
+		}
+		return next(ctx, inv)
+	})
+
+	err := mapper.Delete(context.Background(), "shop.product", "1")
+	if !errors.Is(err, denied) {
+		t.Fatalf("Delete() error = %v, want the interceptor error", err)
+	}
+	if primary.callCount("delete") != 0 {
+		t.Error("adapter should not be called when an interceptor fails")
+	}
+}
+
+func TestMapper_Use_AfterActions(t *testing.T) {
+	mapper := newTestMapper(t, afterTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{})
+	registerStub(mapper, "cache", &stubAdapter{
+		deleteFn: func(ctx context.Context, op *adapter.Operation, identifiers []interface{}) error {
+			return adapter.ErrNotFound
+		},
+	})
+
+	var seen []string
+	mapper.Use(func(ctx context.Context, inv *Invocation, next Invoker) error {
+		seen = append(seen, string(inv.Type)+" "+inv.SourceID+" "+inv.MappingID)
+		return next(ctx, inv)
+	})
+
+	if err := mapper.Update(context.Background(), "shop.product", &afterProduct{ID: "1", Name: "Desk"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	want := []string{"update primary shop.product", "delete cache shop.product"}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("intercepted = %v, want %v", seen, want)
+	}
+}
+
+func TestMapper_Use_SanitizesStatement(t *testing.T) {
+	mapper := newTestMapper(t, `namespace: shop
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+mappings:
+  product:
+    object: Product
+    source: primary
+    operations:
+      fetch:
+        statement: "https://api.example.com/products/{id}?key=abc123"
+        result:
+          properties:
+            - object: ID
+              field: id
+`)
+	registerStub(mapper, "primary", &stubAdapter{fetchFn: fetchReturning(map[string]interface{}{"id": "1"})})
+
+	var statement string
+	mapper.Use(func(ctx context.Context, inv *Invocation, next Invoker) error {
+		statement = inv.Statement
+		if inv.Operation.Statement != "https://api.example.com/products/{id}?key=abc123" {
+			t.Errorf("operation statement should be left intact, got %q", inv.Operation.Statement)
+		}
+		return next(ctx, inv)
+	})
+
+	var product fallbackProduct
+	if err := mapper.Fetch(context.Background(), "shop.product", map[string]interface{}{"id": "1"}, &product); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if statement != "https://api.example.com/products/{id}?key=***" {
+		t.Errorf("Statement = %q, want the key removed", statement)
+	}
+}
@@ -3,6 +3,9 @@ package engine
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/toutaio/toutago-datamapper/adapter"
 	"github.com/toutaio/toutago-datamapper/config"
@@ -14,6 +17,20 @@ type Mapper struct {
 	parser   *config.Parser
 	registry *AdapterRegistry
 	propMap  *PropertyMapper
+
+	// afterActions maps after-action types to their handlers
+	afterActions  map[string]AfterActionHandler
+	afterPolicy   AfterActionPolicy
+	afterReporter AfterActionErrorReporter
+
+	// interceptors wrap every adapter call
+	interceptors []Interceptor
+
+	// cache holds the answers of fetch operations configured with a query cache
+	cache *queryCache
+
+	// mu protects the mapper's handler registries and policies
+	mu sync.RWMutex
 }
 
 // NewMapper creates a new mapper instance by loading configuration from a file.
@@ -27,11 +44,7 @@ func NewMapper(configPath string) (*Mapper, error) {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	return &Mapper{
-		parser:   parser,
-		registry: NewAdapterRegistry(),
-		propMap:  NewPropertyMapper(),
-	}, nil
+	return newMapper(parser), nil
 }
 
 // NewMapperWithParser creates a mapper with an existing parser.
@@ -41,11 +54,25 @@ func NewMapperWithParser(parser *config.Parser) (*Mapper, error) {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	return &Mapper{
+	return newMapper(parser), nil
+}
+
+// newMapper creates a mapper for a validated parser with the built-in handlers installed.
+func newMapper(parser *config.Parser) *Mapper {
+	m := &Mapper{
 		parser:   parser,
 		registry: NewAdapterRegistry(),
 		propMap:  NewPropertyMapper(),
-	}, nil
+		cache:    newQueryCache(),
+	}
+	m.registerBuiltinAfterActions()
+	return m
+}
+
+// Registry returns the adapter registry of the mapper, e.g. to report the
+// circuit breaker states of its sources.
+func (m *Mapper) Registry() *AdapterRegistry {
+	return m.registry
 }
 
 // RegisterAdapter registers an adapter factory for a specific adapter type.
@@ -56,7 +83,11 @@ func (m *Mapper) RegisterAdapter(adapterType string, factory AdapterFactory) {
 // Fetch retrieves a single object using the specified mapping.
 // params should contain the parameter values for the query.
 // result must be a pointer to a struct where the data will be mapped.
-func (m *Mapper) Fetch(ctx context.Context, mappingID string, params map[string]interface{}, result interface{}) error {
+// When the operation defines a fallback chain, sources are tried in order
+// according to their on_miss and on_error settings.
+func (m *Mapper) Fetch(ctx context.Context, mappingID string, params map[string]interface{}, result interface{}, opts ...FetchOption) error {
+	options := newFetchOptions(opts)
+
 	mapping, cfg, err := m.parser.GetMapping(mappingID)
 	if err != nil {
 		return err
@@ -67,94 +98,87 @@ func (m *Mapper) Fetch(ctx context.Context, mappingID string, params map[string]
 		return fmt.Errorf("mapping '%s' does not have a 'fetch' operation", mappingID)
 	}
 
-	// Resolve source
-	source, sourceID, err := m.resolveSource(cfg, mapping, &opConfig)
-	if err != nil {
-		return fmt.Errorf("failed to resolve source for fetch: %w", err)
-	}
-
-	// Get adapter
-	adp, err := m.registry.GetAdapter(ctx, source, sourceID)
+	// Execute fetch against the source chain
+	answer, err := m.fetchFromChain(ctx, mappingID, cfg, mapping, &opConfig, false, params, options)
 	if err != nil {
-		return fmt.Errorf("failed to get adapter: %w", err)
-	}
-
-	// Build operation
-	op := m.buildOperation(adapter.OpFetch, &opConfig)
-	op.Multi = false
-
-	// Execute fetch
-	results, err := adp.Fetch(ctx, op, params)
-	if err != nil {
-		return fmt.Errorf("fetch failed: %w", err)
+		return err
 	}
-
-	if len(results) == 0 {
-		return adapter.ErrNotFound
+	if options.sourceID != nil {
+		*options.sourceID = answer.sourceID
 	}
 
 	// Map result to object
-	if opConfig.Result != nil {
-		dataMap, ok := results[0].(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("expected map[string]interface{}, got %T", results[0])
-		}
-
-		if err := m.propMap.MapToObject(dataMap, result, opConfig.Result.Properties); err != nil {
+	if resultConfig := resultFor(answer.opConfig, &opConfig); resultConfig != nil {
+		if err := m.propMap.MapToValue(answer.data[0], result, resultConfig.Properties); err != nil {
 			return fmt.Errorf("failed to map result: %w", err)
 		}
 	}
 
-	return nil
+	// Load included relations
+	if err := m.includeRelations(ctx, mappingID, mapping, cfg, result, options.include); err != nil {
+		return err
+	}
+
+	return runHooks(ctx, hookAfterFetch, []interface{}{result})
 }
 
 // FetchMulti retrieves multiple objects using the specified mapping.
-// results must be a pointer to a slice of structs.
-func (m *Mapper) FetchMulti(ctx context.Context, mappingID string, params map[string]interface{}, results interface{}) error {
+// results must be a pointer to a slice of structs, struct pointers, maps or
+// DataUnmarshaler implementations. Each element is mapped through
+// PropertyMapper.MapToObject; a failing element is reported as an *ElementError.
+// Only ErrNotFound counts as a miss in a fallback chain; an empty list is an answer.
+func (m *Mapper) FetchMulti(ctx context.Context, mappingID string, params map[string]interface{}, results interface{}, opts ...FetchOption) error {
+	_, err := m.fetchMulti(ctx, mappingID, params, results, newFetchOptions(opts))
+	return err
+}
+
+// fetchMulti runs a multi-result fetch and maps the results into results.
+func (m *Mapper) fetchMulti(ctx context.Context, mappingID string, params map[string]interface{}, results interface{}, options *fetchOptions) (*chainResult, error) {
 	mapping, cfg, err := m.parser.GetMapping(mappingID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	opConfig, exists := mapping.Operations["fetch"]
 	if !exists {
-		return fmt.Errorf("mapping '%s' does not have a 'fetch' operation", mappingID)
+		return nil, fmt.Errorf("mapping '%s' does not have a 'fetch' operation", mappingID)
 	}
 
-	// Resolve source
-	source, sourceID, err := m.resolveSource(cfg, mapping, &opConfig)
+	// Execute fetch against the source chain
+	answer, err := m.fetchFromChain(ctx, mappingID, cfg, mapping, &opConfig, true, params, options)
 	if err != nil {
-		return fmt.Errorf("failed to resolve source for fetch: %w", err)
+		return nil, err
 	}
-
-	// Get adapter
-	adp, err := m.registry.GetAdapter(ctx, source, sourceID)
-	if err != nil {
-		return fmt.Errorf("failed to get adapter: %w", err)
+	if options.sourceID != nil {
+		*options.sourceID = answer.sourceID
 	}
 
-	// Build operation
-	op := m.buildOperation(adapter.OpFetch, &opConfig)
-	op.Multi = true
+	// Map results to objects
+	if resultConfig := resultFor(answer.opConfig, &opConfig); resultConfig != nil {
+		if err := m.propMap.MapToSlice(answer.data, results, resultConfig.Properties); err != nil {
+			return nil, fmt.Errorf("failed to map results: %w", err)
+		}
+	}
 
-	// Execute fetch
-	data, err := adp.Fetch(ctx, op, params)
-	if err != nil {
-		return fmt.Errorf("fetch failed: %w", err)
+	// Load included relations
+	if err := m.includeRelations(ctx, mappingID, mapping, cfg, results, options.include); err != nil {
+		return nil, err
 	}
 
-	// Map results to objects
-	if opConfig.Result != nil && len(data) > 0 {
-		if err := m.mapSliceResults(data, results, opConfig.Result.Properties); err != nil {
-			return fmt.Errorf("failed to map results: %w", err)
-		}
+	if err := runHooks(ctx, hookAfterFetch, fetchedObjects(results)); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return answer, nil
 }
 
 // Insert creates new objects in the data source.
 // objects can be a single object or a slice of objects.
+// When the adapter reports generated values (see adapter.GeneratedInserter), they are
+// written back into the objects through the generated property mappings; pass pointers
+// or a slice of structs to receive them.
+// Objects are first checked against the validation rules of their properties; the
+// first invalid object fails the insert with a *ValidationError before anything is written.
 func (m *Mapper) Insert(ctx context.Context, mappingID string, objects interface{}) error {
 	mapping, cfg, err := m.parser.GetMapping(mappingID)
 	if err != nil {
@@ -172,10 +196,10 @@ func (m *Mapper) Insert(ctx context.Context, mappingID string, objects interface
 		return fmt.Errorf("failed to resolve source for insert: %w", err)
 	}
 
-	// Get adapter
-	adp, err := m.registry.GetAdapter(ctx, source, sourceID)
+	// Get adapter, or the transaction begun on it
+	adp, err := m.access(ctx, source, sourceID, true)
 	if err != nil {
-		return fmt.Errorf("failed to get adapter: %w", err)
+		return err
 	}
 
 	// Build operation
@@ -187,6 +211,15 @@ func (m *Mapper) Insert(ctx context.Context, mappingID string, objects interface
 		return fmt.Errorf("failed to convert objects: %w", err)
 	}
 
+	if len(objectSlice) > 0 {
+		hintGenerated(op, objectSlice[0])
+	}
+
+	// Run BeforeInsert hooks
+	if err := runHooks(ctx, hookBeforeInsert, objectSlice); err != nil {
+		return err
+	}
+
 	// Map objects to data
 	dataObjects := make([]interface{}, len(objectSlice))
 	for i, obj := range objectSlice {
@@ -197,13 +230,56 @@ func (m *Mapper) Insert(ctx context.Context, mappingID string, objects interface
 		dataObjects[i] = data
 	}
 
-	// Execute insert
-	if err := adp.Insert(ctx, op, dataObjects); err != nil {
+	// Enforce validation rules
+	if err := validateObjects(dataObjects, opConfig.Properties); err != nil {
+		return err
+	}
+
+	// Execute insert, collecting generated values when the adapter reports them
+	inv := m.newInvocation(mappingID, sourceID, op)
+	inv.Objects = dataObjects
+	inv.Idempotent = opConfig.Idempotent
+	callCtx, cancel := withOperationTimeout(ctx, opConfig.Timeout)
+	err = m.invoke(callCtx, inv, func(ctx context.Context, inv *Invocation) error {
+		if inserter, ok := adp.(adapter.GeneratedInserter); ok {
+			generated, err := inserter.InsertGenerated(ctx, inv.Operation, inv.Objects)
+			inv.Result = generated
+			return err
+		}
+		return adp.Insert(ctx, inv.Operation, inv.Objects)
+	})
+	cancel()
+	if err != nil {
 		return fmt.Errorf("insert failed: %w", err)
 	}
+	if generated, ok := inv.Result.([]map[string]interface{}); ok {
+		if err := m.writeGenerated(objectSlice, generated, &opConfig); err != nil {
+			return fmt.Errorf("failed to write generated values: %w", err)
+		}
+		for i, values := range generated {
+			if i < len(dataObjects) {
+				for field, value := range values {
+					dataObjects[i].(map[string]interface{})[field] = value
+				}
+			}
+		}
+	}
+
+	// Drop cached fetches of the mapping
+	m.invalidateCache(ctx, mappingID)
+
+	// Record the audit trail
+	if err := m.writeAudit(ctx, mappingID, cfg, mapping, adapter.OpInsert, nil, dataMaps(dataObjects)); err != nil {
+		return err
+	}
+
+	// Run AfterInsert hooks
+	if err := runHooks(ctx, hookAfterInsert, objectSlice); err != nil {
+		return err
+	}
 
 	// Execute after actions
-	if err := m.executeAfterActions(ctx, cfg, opConfig.After, nil); err != nil {
+	if err := m.executeAfterActions(ctx, mappingID, cfg, &opConfig, dataMaps(dataObjects)); err != nil {
 		return fmt.Errorf("after actions failed: %w", err)
 	}
 
@@ -211,7 +287,19 @@ func (m *Mapper) Insert(ctx context.Context, mappingID string, objects interface
 }
 
 // Update modifies existing objects in the data source.
+// When the operation defines condition fields (optimistic locking), the adapter receives
+// the values the objects were loaded with and rejects stale objects with
+// adapter.ErrConflict. On success numeric condition fields are incremented in the
+// caller's objects; pass pointers or a slice of structs to receive them.
+// As for Insert, the first object breaking a validation rule fails the update with a
+// *ValidationError before anything is written.
 func (m *Mapper) Update(ctx context.Context, mappingID string, objects interface{}) error {
+	return m.update(ctx, mappingID, objects, nil)
+}
+
+// update implements Update. Objects with a snapshot (aligned with the objects,
+// nil for none) are sent as partial updates of their changed fields.
+func (m *Mapper) update(ctx context.Context, mappingID string, objects interface{}, snapshots []map[string]interface{}) error {
 	mapping, cfg, err := m.parser.GetMapping(mappingID)
 	if err != nil {
 		return err
@@ -228,10 +316,10 @@ func (m *Mapper) Update(ctx context.Context, mappingID string, objects interface
 		return fmt.Errorf("failed to resolve source for update: %w", err)
 	}
 
-	// Get adapter
-	adp, err := m.registry.GetAdapter(ctx, source, sourceID)
+	// Get adapter, or the transaction begun on it
+	adp, err := m.access(ctx, source, sourceID, true)
 	if err != nil {
-		return fmt.Errorf("failed to get adapter: %w", err)
+		return err
 	}
 
 	// Build operation
@@ -243,6 +331,11 @@ func (m *Mapper) Update(ctx context.Context, mappingID string, objects interface
 		return fmt.Errorf("failed to convert objects: %w", err)
 	}
 
+	// Run BeforeUpdate hooks
+	if err := runHooks(ctx, hookBeforeUpdate, objectSlice); err != nil {
+		return err
+	}
+
 	// Map objects to data
 	dataObjects := make([]interface{}, len(objectSlice))
 	for i, obj := range objectSlice {
@@ -253,13 +346,66 @@ func (m *Mapper) Update(ctx context.Context, mappingID string, objects interface
 		dataObjects[i] = data
 	}
 
-	// Execute update
-	if err := adp.Update(ctx, op, dataObjects); err != nil {
-		return fmt.Errorf("update failed: %w", err)
+	// Enforce validation rules
+	if err := validateObjects(dataObjects, opConfig.Properties); err != nil {
+		return err
+	}
+
+	// Keep the changed fields of objects with a snapshot (dirty tracking)
+	if snapshots != nil {
+		dataObjects = partialObjects(op, mapping, &opConfig, dataObjects, snapshots)
+	}
+
+	// Read the stored records for the audit trail
+	before, err := m.auditBefore(ctx, mappingID, cfg, mapping, dataObjects)
+	if err != nil {
+		return err
+	}
+
+	// Attach expected condition values and store the next versions (optimistic locking)
+	var nextValues []map[string]interface{}
+	if len(opConfig.Condition) > 0 {
+		op.ConditionValues, nextValues, err = m.conditionValues(objectSlice, dataObjects, &opConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Execute update, unless no object has changes
+	if len(dataObjects) > 0 {
+		inv := m.newInvocation(mappingID, sourceID, op)
+		inv.Objects = dataObjects
+		inv.Idempotent = opConfig.Idempotent
+		callCtx, cancel := withOperationTimeout(ctx, opConfig.Timeout)
+		err = m.invoke(callCtx, inv, func(ctx context.Context, inv *Invocation) error {
+			return adp.Update(ctx, inv.Operation, inv.Objects)
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("update failed: %w", err)
+		}
+	}
+
+	// Advance the caller's version fields
+	if err := m.writeConditionValues(objectSlice, nextValues, &opConfig); err != nil {
+		return fmt.Errorf("failed to write condition values: %w", err)
+	}
+
+	// Drop cached fetches of the mapping
+	m.invalidateCache(ctx, mappingID)
+
+	// Record the audit trail
+	if err := m.writeAudit(ctx, mappingID, cfg, mapping, adapter.OpUpdate, before, dataMaps(dataObjects)); err != nil {
+		return err
+	}
+
+	// Run AfterUpdate hooks
+	if err := runHooks(ctx, hookAfterUpdate, objectSlice); err != nil {
+		return err
 	}
 
 	// Execute after actions
-	if err := m.executeAfterActions(ctx, cfg, opConfig.After, nil); err != nil {
+	if err := m.executeAfterActions(ctx, mappingID, cfg, &opConfig, dataMaps(dataObjects)); err != nil {
 		return fmt.Errorf("after actions failed: %w", err)
 	}
 
@@ -267,7 +413,11 @@ func (m *Mapper) Update(ctx context.Context, mappingID string, objects interface
 }
 
 // Delete removes objects from the data source.
-func (m *Mapper) Delete(ctx context.Context, mappingID string, identifiers interface{}) error {
+// On mappings with soft delete, the records are marked through an update of the
+// soft-delete field instead, unless the Purge option is given.
+func (m *Mapper) Delete(ctx context.Context, mappingID string, identifiers interface{}, opts ...DeleteOption) error {
+	options := newDeleteOptions(opts)
+
 	mapping, cfg, err := m.parser.GetMapping(mappingID)
 	if err != nil {
 		return err
@@ -284,10 +434,10 @@ func (m *Mapper) Delete(ctx context.Context, mappingID string, identifiers inter
 		return fmt.Errorf("failed to resolve source for delete: %w", err)
 	}
 
-	// Get adapter
-	adp, err := m.registry.GetAdapter(ctx, source, sourceID)
+	// Get adapter, or the transaction begun on it
+	adp, err := m.access(ctx, source, sourceID, true)
 	if err != nil {
-		return fmt.Errorf("failed to get adapter: %w", err)
+		return err
 	}
 
 	// Build operation
@@ -299,24 +449,123 @@ func (m *Mapper) Delete(ctx context.Context, mappingID string, identifiers inter
 		return fmt.Errorf("failed to convert identifiers: %w", err)
 	}
 
-	// Execute delete
-	if err := adp.Delete(ctx, op, idSlice); err != nil {
+	// Run BeforeDelete hooks
+	if err := runHooks(ctx, hookBeforeDelete, idSlice); err != nil {
+		return err
+	}
+
+	// Execute delete, or mark the records when the mapping soft-deletes them
+	inv := m.newInvocation(mappingID, sourceID, op)
+	inv.Objects = idSlice
+	call := func(ctx context.Context, inv *Invocation) error {
+		return adp.Delete(ctx, inv.Operation, inv.Objects)
+	}
+	if mapping.SoftDelete != nil && !options.purge {
+		inv = m.newInvocation(mappingID, sourceID, softDeleteOperation(op, mapping.SoftDelete))
+		if inv.Objects, err = m.softDeleteObjects(&opConfig, mapping.SoftDelete, idSlice); err != nil {
+			return err
+		}
+		call = func(ctx context.Context, inv *Invocation) error {
+			return adp.Update(ctx, inv.Operation, inv.Objects)
+		}
+	}
+	inv.Idempotent = opConfig.Idempotent
+	callCtx, cancel := withOperationTimeout(ctx, opConfig.Timeout)
+	err = m.invoke(callCtx, inv, call)
+	cancel()
+	if err != nil {
 		return fmt.Errorf("delete failed: %w", err)
 	}
 
+	// Drop cached fetches of the mapping
+	m.invalidateCache(ctx, mappingID)
+
+	// Record the audit trail (soft deletes included)
+	if err := m.writeAudit(ctx, mappingID, cfg, mapping, adapter.OpDelete, nil, m.identifierMaps(&opConfig, idSlice)); err != nil {
+		return err
+	}
+
+	// Run AfterDelete hooks
+	if err := runHooks(ctx, hookAfterDelete, idSlice); err != nil {
+		return err
+	}
+
 	// Execute after actions
-	if err := m.executeAfterActions(ctx, cfg, opConfig.After, nil); err != nil {
+	if err := m.executeAfterActions(ctx, mappingID, cfg, &opConfig, m.identifierMaps(&opConfig, idSlice)); err != nil {
 		return fmt.Errorf("after actions failed: %w", err)
 	}
 
 	return nil
 }
 
-// Execute runs a custom action.
+// Execute runs a custom action addressed as "namespace.mappingID.action".
+// params are passed to the adapter unchanged. If result is non-nil, the value returned
+// by the adapter is mapped into it according to the action's result configuration:
+// result may point to a struct, a slice of structs, a map or a scalar.
 func (m *Mapper) Execute(ctx context.Context, actionID string, params map[string]interface{}, result interface{}) error {
-	// TODO: Implement Execute properly
-	// For now, return not implemented error
-	return fmt.Errorf("Execute not yet implemented")
+	mappingID, actionName, err := splitActionID(actionID)
+	if err != nil {
+		return err
+	}
+
+	mapping, cfg, err := m.parser.GetMapping(mappingID)
+	if err != nil {
+		return err
+	}
+
+	actionConfig, exists := mapping.Actions[actionName]
+	if !exists {
+		return fmt.Errorf("mapping '%s' does not have an action '%s'", mappingID, actionName)
+	}
+
+	// Resolve source
+	source, sourceID, err := m.resolveActionSource(cfg, mapping, &actionConfig)
+	if err != nil {
+		return fmt.Errorf("failed to resolve source for action: %w", err)
+	}
+
+	// Get adapter, and inside a transaction the adapter transaction when it runs actions
+	adp, err := m.registry.GetAdapter(ctx, source, sourceID)
+	if err != nil {
+		return fmt.Errorf("failed to get adapter: %w", err)
+	}
+	execute := adp.Execute
+	if tx := txFromContext(ctx); tx != nil {
+		access, err := tx.access(ctx, adp, sourceID, false)
+		if err != nil {
+			return err
+		}
+		if executor, ok := access.(adapter.ActionExecutor); ok {
+			execute = executor.Execute
+		}
+	}
+
+	// Build and execute action
+	action := m.buildAction(actionName, &actionConfig)
+
+	inv := m.newActionInvocation(mappingID, sourceID, action, params)
+	inv.Idempotent = actionConfig.Idempotent
+	callCtx, cancel := withOperationTimeout(ctx, actionConfig.Timeout)
+	err = m.invoke(callCtx, inv, func(ctx context.Context, inv *Invocation) error {
+		value, err := execute(ctx, inv.Action, inv.Params)
+		inv.Result = value
+		return err
+	})
+	cancel()
+	if err != nil {
+		return fmt.Errorf("execute failed: %w", err)
+	}
+	value := inv.Result
+
+	if result == nil {
+		return nil
+	}
+
+	if err := m.mapActionResult(value, result, actionConfig.Result); err != nil {
+		return fmt.Errorf("failed to map action result: %w", err)
+	}
+
+	return nil
 }
 
 // Close closes all adapter instances and releases resources.
@@ -324,7 +573,23 @@ func (m *Mapper) Close() error {
 	return m.registry.Close()
 }
 
-// resolveSource determines which source to use for an operation (CQRS support).
+// access returns the adapter for a source, or the transaction begun on it when ctx
+// carries a Tx. write reports whether the caller is going to modify data.
+func (m *Mapper) access(ctx context.Context, source config.Source, sourceID string, write bool) (dataAccess, error) {
+	adp, err := m.registry.GetAdapter(ctx, source, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adapter: %w", err)
+	}
+
+	if tx := txFromContext(ctx); tx != nil {
+		return tx.access(ctx, adp, sourceID, write)
+	}
+	return adp, nil
+}
+
+// resolveSource determines which source to use for a write operation (CQRS support).
+// Writes always go to the operation's own source or the first source of its chain;
+// fetches walk the whole chain through fetchFromChain.
 func (m *Mapper) resolveSource(cfg *config.Config, mapping *config.Mapping, opConfig *config.OperationConfig) (config.Source, string, error) {
 	// Operation-specific source takes precedence
 	if opConfig.Source != "" {
@@ -337,8 +602,6 @@ func (m *Mapper) resolveSource(cfg *config.Config, mapping *config.Mapping, opCo
 
 	// Fallback chain (CQRS)
 	if len(opConfig.Sources) > 0 {
-		// For now, use the first source
-		// TODO: Implement fallback logic with on_miss and on_error
 		sourceRef := opConfig.Sources[0]
 		source, exists := cfg.Sources[sourceRef.Name]
 		if !exists {
@@ -359,12 +622,30 @@ func (m *Mapper) resolveSource(cfg *config.Config, mapping *config.Mapping, opCo
 	return config.Source{}, "", fmt.Errorf("no source configured for operation")
 }
 
+// resolveActionSource determines which source a custom action runs on.
+func (m *Mapper) resolveActionSource(cfg *config.Config, mapping *config.Mapping, actionConfig *config.ActionConfig) (config.Source, string, error) {
+	sourceID := actionConfig.Source
+	if sourceID == "" {
+		sourceID = mapping.Source
+	}
+	if sourceID == "" {
+		return config.Source{}, "", fmt.Errorf("no source configured for action")
+	}
+
+	source, exists := cfg.Sources[sourceID]
+	if !exists {
+		return config.Source{}, "", fmt.Errorf("source '%s' not found", sourceID)
+	}
+	return source, sourceID, nil
+}
+
 // buildOperation constructs an adapter.Operation from config.OperationConfig.
 func (m *Mapper) buildOperation(opType adapter.OperationType, opConfig *config.OperationConfig) *adapter.Operation {
 	op := &adapter.Operation{
 		Type:      opType,
 		Statement: opConfig.Statement,
 		Bulk:      opConfig.Bulk,
+		Source:    opConfig.Source,
 	}
 
 	// Convert property mappings
@@ -399,20 +680,272 @@ func (m *Mapper) buildOperation(opType adapter.OperationType, opConfig *config.O
 		}
 	}
 
+	// Convert condition mappings
+	op.Condition = make([]adapter.PropertyMapping, len(opConfig.Condition))
+	for i, pm := range opConfig.Condition {
+		op.Condition[i] = adapter.PropertyMapping{
+			ObjectField: pm.Object,
+			DataField:   pm.Field,
+			Type:        pm.Type,
+		}
+	}
+
+	// Convert fallback operation (CQRS)
+	if opConfig.Fallback != nil {
+		op.Fallback = m.buildOperation(opType, opConfig.Fallback)
+	}
+
 	return op
 }
 
-// executeAfterActions executes after-action hooks (cache invalidation, etc.).
-func (m *Mapper) executeAfterActions(ctx context.Context, cfg *config.Config, actions []config.AfterActionConfig, data map[string]interface{}) error {
-	// TODO: Implement after action execution
-	_ = ctx
-	_ = cfg
-	_ = actions
-	_ = data
+// writeGenerated writes values generated during insert back into the inserted objects.
+// Objects passed by value cannot receive them and are skipped.
+func (m *Mapper) writeGenerated(objects []interface{}, generated []map[string]interface{}, opConfig *config.OperationConfig) error {
+	mappings := generatedMappings(opConfig)
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	for i, values := range generated {
+		if i >= len(objects) || len(values) == 0 {
+			continue
+		}
+
+		target := reflect.ValueOf(objects[i])
+		if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+			continue
+		}
+
+		if err := m.propMap.MapToObject(values, objects[i], mappings); err != nil {
+			return fmt.Errorf("object %d: %w", i, err)
+		}
+	}
+
 	return nil
 }
 
+// conditionValues extracts the expected condition values of each object and stores the
+// next value of each condition field in its data map. Numeric fields are incremented;
+// other types are compared only.
+func (m *Mapper) conditionValues(objects []interface{}, dataObjects []interface{}, opConfig *config.OperationConfig) ([]map[string]interface{}, []map[string]interface{}, error) {
+	expected := make([]map[string]interface{}, len(objects))
+	next := make([]map[string]interface{}, len(objects))
+
+	for i, obj := range objects {
+		current, err := m.propMap.MapFromObject(obj, opConfig.Condition)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to map condition of object %d: %w", i, err)
+		}
+		expected[i] = current
+
+		next[i] = make(map[string]interface{}, len(current))
+		for field, value := range current {
+			if incremented, ok := incrementValue(value); ok {
+				value = incremented
+			}
+			next[i][field] = value
+			dataObjects[i].(map[string]interface{})[field] = value
+		}
+	}
+
+	return expected, next, nil
+}
+
+// writeConditionValues writes the next condition values back into updated objects.
+// Objects passed by value cannot receive them and are skipped.
+func (m *Mapper) writeConditionValues(objects []interface{}, next []map[string]interface{}, opConfig *config.OperationConfig) error {
+	for i, values := range next {
+		target := reflect.ValueOf(objects[i])
+		if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		if err := m.propMap.MapToObject(values, objects[i], opConfig.Condition); err != nil {
+			return fmt.Errorf("object %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// incrementValue returns value + 1 for numeric values.
+func incrementValue(value interface{}) (interface{}, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.ValueOf(rv.Int() + 1).Convert(rv.Type()).Interface(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.ValueOf(rv.Uint() + 1).Convert(rv.Type()).Interface(), true
+	case reflect.Float32, reflect.Float64:
+		return reflect.ValueOf(rv.Float() + 1).Convert(rv.Type()).Interface(), true
+	default:
+		return value, false
+	}
+}
+
+// generatedMappings returns the mappings of all auto-generated fields of an operation:
+// the generated list plus properties flagged as generated.
+func generatedMappings(opConfig *config.OperationConfig) []config.PropertyMap {
+	mappings := append([]config.PropertyMap{}, opConfig.Generated...)
+	for _, pm := range opConfig.Properties {
+		if pm.Generated {
+			mappings = append(mappings, pm)
+		}
+	}
+	return mappings
+}
+
+// hintGenerated sets the integer type hint on generated mappings of op that have no
+// hint and whose field in sample is an integer, so adapters generate numbers for them.
+func hintGenerated(op *adapter.Operation, sample interface{}) {
+	value := reflect.ValueOf(sample)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	hint := func(mappings []adapter.PropertyMapping) {
+		for i := range mappings {
+			pm := &mappings[i]
+			if !pm.Generated || pm.Type != "" {
+				continue
+			}
+			field, ok := value.Type().FieldByName(pm.ObjectField)
+			if !ok {
+				continue
+			}
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			switch fieldType.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				pm.Type = adapter.TypeInteger
+			}
+		}
+	}
+	hint(op.Generated)
+	hint(op.Properties)
+}
+
+// resultFor returns the result configuration for data answered by step,
+// falling back to the primary operation's result when the step has none.
+func resultFor(step, primary *config.OperationConfig) *config.ResultConfig {
+	if step != nil && step.Result != nil {
+		return step.Result
+	}
+	return primary.Result
+}
+
+// buildAction constructs an adapter.Action from config.ActionConfig.
+func (m *Mapper) buildAction(name string, actionConfig *config.ActionConfig) *adapter.Action {
+	action := &adapter.Action{
+		Name:       name,
+		Statement:  actionConfig.Statement,
+		Parameters: toAdapterMappings(actionConfig.Parameters),
+	}
+
+	if actionConfig.Result != nil {
+		action.Result = &adapter.ResultMapping{
+			Type:       actionConfig.Result.Type,
+			Multi:      actionConfig.Result.Multi,
+			Properties: toAdapterMappings(actionConfig.Result.Properties),
+		}
+	}
+
+	return action
+}
+
+// toAdapterMappings converts config property maps to adapter property mappings.
+func toAdapterMappings(mappings []config.PropertyMap) []adapter.PropertyMapping {
+	result := make([]adapter.PropertyMapping, len(mappings))
+	for i, pm := range mappings {
+		result[i] = adapter.PropertyMapping{
+			ObjectField: pm.Object,
+			DataField:   pm.Field,
+			Type:        pm.Type,
+			Generated:   pm.Generated,
+		}
+	}
+	return result
+}
+
+// mapActionResult maps the value returned by an action into result.
+// Multi-result actions fill a slice; single-result actions take the first
+// element when the adapter returns a list.
+func (m *Mapper) mapActionResult(value interface{}, result interface{}, resultConfig *config.ResultConfig) error {
+	var properties []config.PropertyMap
+	multi := false
+	if resultConfig != nil {
+		properties = resultConfig.Properties
+		multi = resultConfig.Multi
+	}
+
+	items, isList := toList(value)
+	if multi || (isList && isSlicePointer(result)) {
+		if !isList && value != nil {
+			items = []interface{}{value}
+		}
+		return m.propMap.MapToSlice(items, result, properties)
+	}
+
+	if isList {
+		if len(items) == 0 {
+			return adapter.ErrNotFound
+		}
+		value = items[0]
+	}
+
+	return m.propMap.MapToValue(value, result, properties)
+}
+
+// splitActionID splits "namespace.mappingID.action" into the mapping ID and action name.
+func splitActionID(actionID string) (string, string, error) {
+	idx := strings.LastIndex(actionID, ".")
+	if idx <= 0 || idx == len(actionID)-1 || strings.Count(actionID, ".") != 2 {
+		return "", "", fmt.Errorf("invalid action ID '%s': must be in format 'namespace.mappingID.action'", actionID)
+	}
+	return actionID[:idx], actionID[idx+1:], nil
+}
+
+// toList converts a slice value of any element type to []interface{}.
+// The second return value is false when value is not a slice.
+func toList(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case []interface{}:
+		return v, true
+	case []map[string]interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = item
+		}
+		return result, true
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+
+	result := make([]interface{}, rv.Len())
+	for i := range result {
+		result[i] = rv.Index(i).Interface()
+	}
+	return result, true
+}
+
+// isSlicePointer reports whether target is a pointer to a slice.
+func isSlicePointer(target interface{}) bool {
+	rv := reflect.ValueOf(target)
+	return rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Slice
+}
+
 // toSlice converts a single object or slice to []interface{}.
+// Elements of struct slices are returned as pointers into the slice, so values
+// written back to them (generated fields, versions) are visible to the caller.
 func (m *Mapper) toSlice(objects interface{}) ([]interface{}, error) {
 	if objects == nil {
 		return nil, fmt.Errorf("objects cannot be nil")
@@ -428,31 +961,21 @@ func (m *Mapper) toSlice(objects interface{}) ([]interface{}, error) {
 			result[i] = item
 		}
 		return result, nil
-	default:
+	}
+
+	rv := reflect.ValueOf(objects)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
 		// Single object - wrap in slice
 		return []interface{}{objects}, nil
 	}
-}
 
-// mapSliceResults maps a slice of data maps to a slice of objects using reflection.
-func (m *Mapper) mapSliceResults(data []interface{}, results interface{}, _ []config.PropertyMap) error {
-	// This is a simplified implementation that works with []interface{} of maps
-	// A more complete implementation would use reflection to populate any slice type
-
-	switch v := results.(type) {
-	case *[]map[string]interface{}:
-		// Direct mapping to map slice
-		mapped := make([]map[string]interface{}, len(data))
-		for i, item := range data {
-			if dataMap, ok := item.(map[string]interface{}); ok {
-				mapped[i] = dataMap
-			}
+	result := make([]interface{}, rv.Len())
+	for i := range result {
+		elem := rv.Index(i)
+		if elem.Kind() == reflect.Struct {
+			elem = elem.Addr()
 		}
-		*v = mapped
-		return nil
-	default:
-		// For struct slices, we'd need more complex reflection
-		// For now, return an error suggesting to use []map[string]interface{}
-		return fmt.Errorf("results must be *[]map[string]interface{} for now (full reflection support coming soon)")
+		result[i] = elem.Interface()
 	}
+	return result, nil
 }
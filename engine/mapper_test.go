@@ -2,8 +2,11 @@ package engine
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/toutaio/toutago-datamapper/adapter"
@@ -12,7 +15,9 @@ import (
 
 // mockAdapter for testing
 type mockAdapter struct {
-	fetchResults []map[string]interface{}
+	fetchResults  []map[string]interface{}
+	executeResult interface{}
+	lastAction    *adapter.Action
 }
 
 func (m *mockAdapter) Fetch(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
@@ -36,7 +41,8 @@ func (m *mockAdapter) Delete(ctx context.Context, op *adapter.Operation, identif
 }
 
 func (m *mockAdapter) Execute(ctx context.Context, action *adapter.Action, params map[string]interface{}) (interface{}, error) {
-	return nil, nil
+	m.lastAction = action
+	return m.executeResult, nil
 }
 
 func (m *mockAdapter) Connect(ctx context.Context, config map[string]interface{}) error {
@@ -476,10 +482,10 @@ mappings:
 		return &mockAdapter{}, nil
 	})
 
+	// "custom" is an operation, not an action
 	err = mapper.Execute(context.Background(), "test.user.custom", nil, nil)
 	if err == nil {
-		// Execute is not fully implemented yet, so we expect an error
-		t.Log("Execute() returned nil, implementation may be complete")
+		t.Error("Execute() should error for an undefined action")
 	}
 }
 
@@ -795,3 +801,418 @@ mappings:
 		t.Error("Fetch() should error when no fetch operation defined, got nil")
 	}
 }
+
+// stubAdapter is a configurable adapter for engine tests.
+// Nil functions behave as successful no-ops.
+type stubAdapter struct {
+	name      string
+	fetchFn   func(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error)
+	insertFn  func(ctx context.Context, op *adapter.Operation, objects []interface{}) error
+	updateFn  func(ctx context.Context, op *adapter.Operation, objects []interface{}) error
+	deleteFn  func(ctx context.Context, op *adapter.Operation, identifiers []interface{}) error
+	executeFn func(ctx context.Context, action *adapter.Action, params map[string]interface{}) (interface{}, error)
+
+	mu    sync.Mutex
+	calls []string
+	ops   []*adapter.Operation
+}
+
+func (s *stubAdapter) record(call string, op *adapter.Operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+	s.ops = append(s.ops, op)
+}
+
+// callCount returns how many times the named method was called.
+func (s *stubAdapter) callCount(call string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, c := range s.calls {
+		if c == call {
+			n++
+		}
+	}
+	return n
+}
+
+// lastOp returns the operation passed to the most recent call.
+func (s *stubAdapter) lastOp() *adapter.Operation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.ops) == 0 {
+		return nil
+	}
+	return s.ops[len(s.ops)-1]
+}
+
+func (s *stubAdapter) Fetch(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+	s.record("fetch", op)
+	if s.fetchFn == nil {
+		return nil, nil
+	}
+	return s.fetchFn(ctx, op, params)
+}
+
+func (s *stubAdapter) Insert(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	s.record("insert", op)
+	if s.insertFn == nil {
+		return nil
+	}
+	return s.insertFn(ctx, op, objects)
+}
+
+func (s *stubAdapter) Update(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	s.record("update", op)
+	if s.updateFn == nil {
+		return nil
+	}
+	return s.updateFn(ctx, op, objects)
+}
+
+func (s *stubAdapter) Delete(ctx context.Context, op *adapter.Operation, identifiers []interface{}) error {
+	s.record("delete", op)
+	if s.deleteFn == nil {
+		return nil
+	}
+	return s.deleteFn(ctx, op, identifiers)
+}
+
+func (s *stubAdapter) Execute(ctx context.Context, action *adapter.Action, params map[string]interface{}) (interface{}, error) {
+	s.record("execute", nil)
+	if s.executeFn == nil {
+		return nil, nil
+	}
+	return s.executeFn(ctx, action, params)
+}
+
+func (s *stubAdapter) Connect(ctx context.Context, config map[string]interface{}) error {
+	return nil
+}
+
+func (s *stubAdapter) Close() error {
+	return nil
+}
+
+func (s *stubAdapter) Name() string {
+	return s.name
+}
+
+// registerStub registers stub as the adapter for adapterType.
+func registerStub(mapper *Mapper, adapterType string, stub *stubAdapter) {
+	mapper.RegisterAdapter(adapterType, func(source config.Source) (adapter.Adapter, error) {
+		return stub, nil
+	})
+}
+
+// newTestMapper writes configContent to a temp file and creates a mapper from it.
+func newTestMapper(t *testing.T, configContent string) *Mapper {
+	t.Helper()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	mapper, err := NewMapper(configFile)
+	if err != nil {
+		t.Fatalf("NewMapper() error = %v", err)
+	}
+	t.Cleanup(func() { mapper.Close() })
+
+	return mapper
+}
+
+const executeTestConfig = `namespace: test
+version: "1.0"
+sources:
+  db:
+    adapter: mock
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: db
+    actions:
+      by-email:
+        statement: "SELECT * FROM users WHERE email = {email}"
+        parameters:
+          - object: Email
+            field: email
+        result:
+          type: User
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+      list:
+        statement: "SELECT * FROM users"
+        result:
+          type: User
+          multi: true
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+      count:
+        statement: "SELECT COUNT(*) AS total FROM users"
+        result:
+          properties:
+            - field: total
+      summary:
+        statement: "CALL user_summary()"
+`
+
+func TestMapper_Execute_StructResult(t *testing.T) {
+	mapper := newTestMapper(t, executeTestConfig)
+	mock := &mockAdapter{
+		executeResult: []interface{}{
+			map[string]interface{}{"id": "1", "name": "Alice"},
+		},
+	}
+	mapper.RegisterAdapter("mock", func(source config.Source) (adapter.Adapter, error) {
+		return mock, nil
+	})
+
+	type User struct {
+		ID   string
+		Name string
+	}
+
+	var user User
+	err := mapper.Execute(context.Background(), "test.user.by-email", map[string]interface{}{"email": "a@example.com"}, &user)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if user.ID != "1" || user.Name != "Alice" {
+		t.Errorf("user = %+v, want ID=1 Name=Alice", user)
+	}
+	if mock.lastAction == nil || mock.lastAction.Name != "by-email" {
+		t.Fatalf("lastAction = %+v, want name by-email", mock.lastAction)
+	}
+	if len(mock.lastAction.Parameters) != 1 || mock.lastAction.Parameters[0].DataField != "email" {
+		t.Errorf("Parameters = %+v, want email mapping", mock.lastAction.Parameters)
+	}
+	if mock.lastAction.Result == nil || len(mock.lastAction.Result.Properties) != 2 {
+		t.Errorf("Result = %+v, want 2 properties", mock.lastAction.Result)
+	}
+}
+
+func TestMapper_Execute_MultiResult(t *testing.T) {
+	mapper := newTestMapper(t, executeTestConfig)
+	mapper.RegisterAdapter("mock", func(source config.Source) (adapter.Adapter, error) {
+		return &mockAdapter{
+			executeResult: []map[string]interface{}{
+				{"id": "1", "name": "Alice"},
+				{"id": "2", "name": "Bob"},
+			},
+		}, nil
+	})
+
+	type User struct {
+		ID   string
+		Name string
+	}
+
+	var users []User
+	if err := mapper.Execute(context.Background(), "test.user.list", nil, &users); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(users) != 2 || users[1].Name != "Bob" {
+		t.Errorf("users = %+v, want Alice and Bob", users)
+	}
+
+	var userPtrs []*User
+	if err := mapper.Execute(context.Background(), "test.user.list", nil, &userPtrs); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(userPtrs) != 2 || userPtrs[0].ID != "1" {
+		t.Errorf("userPtrs = %+v, want two users", userPtrs)
+	}
+}
+
+func TestMapper_Execute_ScalarAndMapResult(t *testing.T) {
+	mapper := newTestMapper(t, executeTestConfig)
+	mapper.RegisterAdapter("mock", func(source config.Source) (adapter.Adapter, error) {
+		return &mockAdapter{
+			executeResult: map[string]interface{}{"total": 42},
+		}, nil
+	})
+
+	var total int64
+	if err := mapper.Execute(context.Background(), "test.user.count", nil, &total); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if total != 42 {
+		t.Errorf("total = %d, want 42", total)
+	}
+
+	var summary map[string]interface{}
+	if err := mapper.Execute(context.Background(), "test.user.summary", nil, &summary); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if summary["total"] != 42 {
+		t.Errorf("summary = %v, want total=42", summary)
+	}
+
+	// A nil result discards the returned value
+	if err := mapper.Execute(context.Background(), "test.user.summary", nil, nil); err != nil {
+		t.Errorf("Execute(nil result) error = %v", err)
+	}
+}
+
+func TestMapper_Execute_Errors(t *testing.T) {
+	mapper := newTestMapper(t, executeTestConfig)
+	mapper.RegisterAdapter("mock", func(source config.Source) (adapter.Adapter, error) {
+		return &mockAdapter{executeResult: []interface{}{}}, nil
+	})
+
+	type User struct {
+		ID string
+	}
+
+	tests := []struct {
+		name     string
+		actionID string
+	}{
+		{"missing action segment", "test.user"},
+		{"too many segments", "test.user.list.extra"},
+		{"unknown namespace", "other.user.list"},
+		{"unknown action", "test.user.missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user User
+			if err := mapper.Execute(context.Background(), tt.actionID, nil, &user); err == nil {
+				t.Errorf("Execute(%q) should error", tt.actionID)
+			}
+		})
+	}
+
+	var user User
+	err := mapper.Execute(context.Background(), "test.user.by-email", nil, &user)
+	if !errors.Is(err, adapter.ErrNotFound) {
+		t.Errorf("Execute() with empty list error = %v, want ErrNotFound", err)
+	}
+}
+
+const fetchMultiTestConfig = `namespace: test
+version: "1.0"
+sources:
+  db:
+    adapter: stub
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: db
+    operations:
+      fetch:
+        statement: "SELECT * FROM users"
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+`
+
+type multiUser struct {
+	ID   int
+	Name string
+}
+
+// taggedUser maps itself from data instead of using property mappings.
+type taggedUser struct {
+	Label string
+}
+
+func (u *taggedUser) UnmarshalData(data map[string]interface{}) error {
+	if data["name"] == nil {
+		return errors.New("missing name")
+	}
+	u.Label = fmt.Sprintf("%v#%v", data["name"], data["id"])
+	return nil
+}
+
+func TestMapper_FetchMulti_TypedSlices(t *testing.T) {
+	mapper := newTestMapper(t, fetchMultiTestConfig)
+	registerStub(mapper, "stub", &stubAdapter{fetchFn: fetchReturning(
+		map[string]interface{}{"id": 1, "name": "Alice"},
+		map[string]interface{}{"id": 2, "name": "Bob"},
+	)})
+	ctx := context.Background()
+
+	var users []multiUser
+	if err := mapper.FetchMulti(ctx, "test.user", nil, &users); err != nil {
+		t.Fatalf("FetchMulti([]User) error = %v", err)
+	}
+	if len(users) != 2 || users[0].ID != 1 || users[1].Name != "Bob" {
+		t.Errorf("users = %+v", users)
+	}
+
+	var userPtrs []*multiUser
+	if err := mapper.FetchMulti(ctx, "test.user", nil, &userPtrs); err != nil {
+		t.Fatalf("FetchMulti([]*User) error = %v", err)
+	}
+	if len(userPtrs) != 2 || userPtrs[1].ID != 2 {
+		t.Errorf("userPtrs = %+v", userPtrs)
+	}
+
+	var tagged []taggedUser
+	if err := mapper.FetchMulti(ctx, "test.user", nil, &tagged); err != nil {
+		t.Fatalf("FetchMulti([]taggedUser) error = %v", err)
+	}
+	if len(tagged) != 2 || tagged[0].Label != "Alice#1" {
+		t.Errorf("tagged = %+v", tagged)
+	}
+
+	var taggedPtrs []*taggedUser
+	if err := mapper.FetchMulti(ctx, "test.user", nil, &taggedPtrs); err != nil {
+		t.Fatalf("FetchMulti([]*taggedUser) error = %v", err)
+	}
+	if len(taggedPtrs) != 2 || taggedPtrs[1].Label != "Bob#2" {
+		t.Errorf("taggedPtrs = %+v", taggedPtrs)
+	}
+}
+
+func TestMapper_FetchMulti_ElementError(t *testing.T) {
+	mapper := newTestMapper(t, fetchMultiTestConfig)
+	registerStub(mapper, "stub", &stubAdapter{fetchFn: fetchReturning(
+		map[string]interface{}{"id": 1, "name": "Alice"},
+		map[string]interface{}{"id": 2},
+		map[string]interface{}{"id": "three", "name": "Carol"},
+	)})
+	ctx := context.Background()
+
+	var tagged []taggedUser
+	err := mapper.FetchMulti(ctx, "test.user", nil, &tagged)
+	var elemErr *ElementError
+	if !errors.As(err, &elemErr) || elemErr.Index != 1 {
+		t.Errorf("FetchMulti() error = %v, want ElementError at index 1", err)
+	}
+
+	var users []multiUser
+	err = mapper.FetchMulti(ctx, "test.user", nil, &users)
+	if !errors.As(err, &elemErr) || elemErr.Index != 2 {
+		t.Errorf("FetchMulti() error = %v, want ElementError at index 2", err)
+	}
+}
+
+func TestMapper_FetchMulti_EmptyResetsSlice(t *testing.T) {
+	mapper := newTestMapper(t, fetchMultiTestConfig)
+	registerStub(mapper, "stub", &stubAdapter{fetchFn: fetchReturning()})
+
+	users := []multiUser{{ID: 9}}
+	if err := mapper.FetchMulti(context.Background(), "test.user", nil, &users); err != nil {
+		t.Fatalf("FetchMulti() error = %v", err)
+	}
+	if len(users) != 0 {
+		t.Errorf("users = %+v, want empty", users)
+	}
+}
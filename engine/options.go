@@ -0,0 +1,129 @@
+package engine
+
+import "github.com/toutaio/toutago-datamapper/adapter"
+
+// FetchOption customizes a single Fetch or FetchMulti call.
+type FetchOption func(*fetchOptions)
+
+// fetchOptions holds the settings collected from FetchOptions.
+type fetchOptions struct {
+	// sourceID receives the ID of the source that answered the fetch
+	sourceID *string
+
+	// page limits a multi-result fetch to one page (set by FetchPage)
+	page *adapter.Pagination
+
+	// where filters the results, using object field names
+	where *adapter.Criterion
+
+	// include lists the relations to load, as dotted paths
+	include []string
+
+	// withDeleted includes soft-deleted records
+	withDeleted bool
+}
+
+// ReportSource stores the ID of the source that finally answered the fetch into sourceID.
+// This is useful with CQRS fallback chains to tell cache hits from primary reads.
+func ReportSource(sourceID *string) FetchOption {
+	return func(o *fetchOptions) {
+		o.sourceID = sourceID
+	}
+}
+
+// Where filters fetch results by criteria built from object field names, e.g.
+// Where(adapter.And(adapter.Gt("Age", 18), adapter.Like("Name", "A%"))).
+// Field names are translated to data fields through the fetch's result properties
+// and parameters. Adapters that support the criteria natively receive them in
+// Operation.Criteria; otherwise the results are filtered in memory.
+// Repeated Where options are combined with and.
+func Where(criterion adapter.Criterion) FetchOption {
+	return func(o *fetchOptions) {
+		if o.where == nil {
+			o.where = &criterion
+			return
+		}
+		combined := adapter.And(*o.where, criterion)
+		o.where = &combined
+	}
+}
+
+// Include eagerly loads the named relations of the fetched objects into their
+// struct fields. Nested relations are named by dotted paths: Include("Lines.Product")
+// loads each order's lines and each line's product. Related objects are fetched
+// through their own mappings, so they may live on other sources.
+func Include(relations ...string) FetchOption {
+	return func(o *fetchOptions) {
+		o.include = append(o.include, relations...)
+	}
+}
+
+// WithDeleted includes soft-deleted records in the results of a fetch on a
+// mapping with soft delete. Other mappings are not affected.
+func WithDeleted() FetchOption {
+	return func(o *fetchOptions) {
+		o.withDeleted = true
+	}
+}
+
+// newFetchOptions applies opts over the default fetch settings.
+func newFetchOptions(opts []FetchOption) *fetchOptions {
+	o := &fetchOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// DeleteOption customizes a single Delete call.
+type DeleteOption func(*deleteOptions)
+
+// deleteOptions holds the settings collected from DeleteOptions.
+type deleteOptions struct {
+	// purge removes soft-deleted records for real
+	purge bool
+}
+
+// Purge makes Delete remove the records of a mapping with soft delete for real
+// instead of marking them.
+func Purge() DeleteOption {
+	return func(o *deleteOptions) {
+		o.purge = true
+	}
+}
+
+// newDeleteOptions applies opts over the default delete settings.
+func newDeleteOptions(opts []DeleteOption) *deleteOptions {
+	o := &deleteOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// TxOption customizes a Mapper.Transaction call.
+type TxOption func(*txOptions)
+
+// txOptions holds the settings collected from TxOptions.
+type txOptions struct {
+	// allowNonTransactional lets writes reach adapters without transaction support
+	allowNonTransactional bool
+}
+
+// AllowNonTransactional lets a transaction write to sources whose adapters do not
+// implement adapter.Transactional. Such writes are applied immediately (best effort)
+// and are not rolled back when the transaction fails.
+func AllowNonTransactional() TxOption {
+	return func(o *txOptions) {
+		o.allowNonTransactional = true
+	}
+}
+
+// newTxOptions applies opts over the default transaction settings.
+func newTxOptions(opts []TxOption) *txOptions {
+	o := &txOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+// offsetCursorPrefix marks cursors issued by in-memory paging.
+const offsetCursorPrefix = "offset:"
+
+// FetchPage retrieves one page of a multi-result fetch into results, like FetchMulti,
+// and returns the cursor for the next page and, when requested, the total count.
+//
+//	page := adapter.Pagination{Limit: 50}
+//	for {
+//		info, err := mapper.FetchPage(ctx, "app.user", nil, page, &users)
+//		...
+//		if info.NextCursor == "" {
+//			break
+//		}
+//		page.Cursor = info.NextCursor
+//	}
+//
+// Adapters implementing adapter.Paginator page natively; for others all results
+// are fetched and the page is cut in memory.
+func (m *Mapper) FetchPage(ctx context.Context, mappingID string, params map[string]interface{}, page adapter.Pagination, results interface{}, opts ...FetchOption) (*adapter.PageInfo, error) {
+	if page.Limit < 0 || page.Offset < 0 {
+		return nil, adapter.NewAdapterError(adapter.ErrValidation.Code, "page limit and offset must not be negative", nil)
+	}
+
+	options := newFetchOptions(opts)
+	options.page = &page
+
+	answer, err := m.fetchMulti(ctx, mappingID, params, results, options)
+	if err != nil {
+		return nil, err
+	}
+	if answer.page == nil {
+		return &adapter.PageInfo{Total: -1}, nil
+	}
+	return answer.page, nil
+}
+
+// pageInMemory cuts a page out of fully fetched results.
+// Its cursors encode the offset of the next page.
+func pageInMemory(data []interface{}, page *adapter.Pagination) ([]interface{}, *adapter.PageInfo, error) {
+	start := page.Offset
+	if page.Cursor != "" {
+		offset, err := decodeOffsetCursor(page.Cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		start = offset
+	}
+	if start > len(data) {
+		start = len(data)
+	}
+
+	end := len(data)
+	if page.Limit > 0 && start+page.Limit < end {
+		end = start + page.Limit
+	}
+
+	info := &adapter.PageInfo{Total: -1}
+	if page.WithTotal {
+		info.Total = len(data)
+	}
+	if end < len(data) {
+		info.NextCursor = encodeOffsetCursor(end)
+	}
+
+	return data[start:end], info, nil
+}
+
+// encodeOffsetCursor returns an opaque cursor for an offset.
+func encodeOffsetCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(offsetCursorPrefix + strconv.Itoa(offset)))
+}
+
+// decodeOffsetCursor returns the offset encoded in a cursor from encodeOffsetCursor.
+func decodeOffsetCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil && strings.HasPrefix(string(raw), offsetCursorPrefix) {
+		offset, convErr := strconv.Atoi(strings.TrimPrefix(string(raw), offsetCursorPrefix))
+		if convErr == nil && offset >= 0 {
+			return offset, nil
+		}
+	}
+	return 0, adapter.NewAdapterError(adapter.ErrValidation.Code, fmt.Sprintf("invalid page cursor '%s'", cursor), nil)
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// pagingStub is a stub adapter that pages natively.
+type pagingStub struct {
+	stubAdapter
+	lastPage *adapter.Pagination
+}
+
+func (p *pagingStub) FetchPage(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, *adapter.PageInfo, error) {
+	p.record("fetch_page", op)
+	p.lastPage = op.Page
+	return []interface{}{map[string]interface{}{"id": "native"}}, &adapter.PageInfo{NextCursor: "next", Total: 99}, nil
+}
+
+func TestMapper_FetchPage_InMemory(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	rows := fetchReturning(
+		map[string]interface{}{"id": "1"},
+		map[string]interface{}{"id": "2"},
+		map[string]interface{}{"id": "3"},
+		map[string]interface{}{"id": "4"},
+		map[string]interface{}{"id": "5"},
+	)
+	registerStub(mapper, "primary", &stubAdapter{fetchFn: rows})
+	registerStub(mapper, "backup", &stubAdapter{fetchFn: rows})
+	ctx := context.Background()
+
+	var ids []string
+	page := adapter.Pagination{Limit: 2, WithTotal: true}
+	for pages := 0; pages < 5; pages++ {
+		var products []fallbackProduct
+		info, err := mapper.FetchPage(ctx, "shop.replicated", nil, page, &products)
+		if err != nil {
+			t.Fatalf("FetchPage() error = %v", err)
+		}
+		if info.Total != 5 {
+			t.Errorf("Total = %d, want 5", info.Total)
+		}
+		for _, p := range products {
+			ids = append(ids, p.ID)
+		}
+		if info.NextCursor == "" {
+			break
+		}
+		page.Cursor = info.NextCursor
+	}
+
+	if len(ids) != 5 || ids[0] != "1" || ids[4] != "5" {
+		t.Errorf("ids = %v, want 1..5 across pages", ids)
+	}
+
+	var products []fallbackProduct
+	info, err := mapper.FetchPage(ctx, "shop.replicated", nil, adapter.Pagination{Offset: 3}, &products)
+	if err != nil {
+		t.Fatalf("FetchPage(offset) error = %v", err)
+	}
+	if len(products) != 2 || products[0].ID != "4" || info.NextCursor != "" || info.Total != -1 {
+		t.Errorf("offset page = %v, info = %+v; want 4, 5 without cursor or total", products, info)
+	}
+
+	_, err = mapper.FetchPage(ctx, "shop.replicated", nil, adapter.Pagination{Cursor: "garbage"}, &products)
+	if !errors.Is(err, adapter.ErrValidation) {
+		t.Errorf("FetchPage(invalid cursor) error = %v, want ErrValidation", err)
+	}
+}
+
+func TestMapper_FetchPage_Native(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	stub := &pagingStub{}
+	mapper.RegisterAdapter("primary", func(config.Source) (adapter.Adapter, error) { return stub, nil })
+
+	var products []fallbackProduct
+	info, err := mapper.FetchPage(context.Background(), "shop.replicated", nil, adapter.Pagination{Limit: 10, Cursor: "abc"}, &products)
+	if err != nil {
+		t.Fatalf("FetchPage() error = %v", err)
+	}
+
+	if stub.callCount("fetch") != 0 || stub.lastPage == nil || stub.lastPage.Cursor != "abc" {
+		t.Errorf("native paginator not used: fetch calls = %d, page = %+v", stub.callCount("fetch"), stub.lastPage)
+	}
+	if len(products) != 1 || products[0].ID != "native" || info.NextCursor != "next" || info.Total != 99 {
+		t.Errorf("products = %v, info = %+v", products, info)
+	}
+}
@@ -13,6 +13,31 @@ import (
 // PropertyMapper handles mapping between data fields and object properties using reflection.
 type PropertyMapper struct{}
 
+// DataUnmarshaler is implemented by types that map themselves from a data map.
+// When a result target implements it, it is used instead of the property mappings.
+type DataUnmarshaler interface {
+	UnmarshalData(data map[string]interface{}) error
+}
+
+// ElementError reports which element of a multi-result failed to map.
+type ElementError struct {
+	// Index is the position of the failing element in the result list.
+	Index int
+
+	// Err is the mapping error for that element.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ElementError) Error() string {
+	return fmt.Sprintf("element %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the underlying mapping error.
+func (e *ElementError) Unwrap() error {
+	return e.Err
+}
+
 // NewPropertyMapper creates a new property mapper.
 func NewPropertyMapper() *PropertyMapper {
 	return &PropertyMapper{}
@@ -61,6 +86,190 @@ func (pm *PropertyMapper) MapToObject(data map[string]interface{}, target interf
 	return nil
 }
 
+// MapToValue maps a single result value into target, which must be a non-nil pointer.
+// Targets implementing DataUnmarshaler map themselves, as do json.Unmarshaler targets
+// when no property mappings are given. Otherwise structs are filled through MapToObject,
+// maps receive the data fields as-is, interfaces receive the raw value and any other
+// kind is treated as a scalar. A scalar target takes the first mapped field when the
+// value is a data map.
+func (pm *PropertyMapper) MapToValue(value interface{}, target interface{}, mappings []config.PropertyMap) error {
+	if target == nil {
+		return fmt.Errorf("target cannot be nil")
+	}
+
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Ptr || targetValue.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+
+	return pm.setResult(targetValue.Elem(), value, mappings)
+}
+
+// MapToSlice maps a list of result values into target, which must be a pointer to a slice.
+// Each element is mapped with MapToValue semantics, so slices of structs, struct
+// pointers, maps, scalars and custom unmarshalers are supported. A failing element
+// is reported as an *ElementError.
+func (pm *PropertyMapper) MapToSlice(data []interface{}, target interface{}, mappings []config.PropertyMap) error {
+	if target == nil {
+		return fmt.Errorf("target cannot be nil")
+	}
+
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Ptr || targetValue.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+
+	sliceValue := targetValue.Elem()
+	if sliceValue.Kind() != reflect.Slice {
+		return fmt.Errorf("target must be a pointer to slice, got pointer to %s", sliceValue.Kind())
+	}
+
+	out := reflect.MakeSlice(sliceValue.Type(), len(data), len(data))
+	for i, item := range data {
+		if err := pm.setResult(out.Index(i), item, mappings); err != nil {
+			return &ElementError{Index: i, Err: err}
+		}
+	}
+
+	sliceValue.Set(out)
+	return nil
+}
+
+// setResult maps a result value into an addressable destination.
+func (pm *PropertyMapper) setResult(dest reflect.Value, value interface{}, mappings []config.PropertyMap) error {
+	timeType := reflect.TypeOf(time.Time{})
+
+	if dataMap, ok := value.(map[string]interface{}); ok {
+		if handled, err := pm.unmarshalResult(dest, dataMap, mappings); handled {
+			return err
+		}
+	}
+
+	switch {
+	case dest.Kind() == reflect.Interface:
+		if value == nil {
+			dest.Set(reflect.Zero(dest.Type()))
+			return nil
+		}
+		valueReflect := reflect.ValueOf(value)
+		if !valueReflect.Type().AssignableTo(dest.Type()) {
+			return fmt.Errorf("cannot assign %s to %s", valueReflect.Type(), dest.Type())
+		}
+		dest.Set(valueReflect)
+		return nil
+
+	case dest.Kind() == reflect.Struct && dest.Type() != timeType:
+		dataMap, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected map[string]interface{}, got %T", value)
+		}
+		return pm.MapToObject(dataMap, dest.Addr().Interface(), mappings)
+
+	case dest.Kind() == reflect.Ptr && dest.Type().Elem().Kind() == reflect.Struct && dest.Type().Elem() != timeType:
+		if value == nil {
+			dest.Set(reflect.Zero(dest.Type()))
+			return nil
+		}
+		elem := reflect.New(dest.Type().Elem())
+		if err := pm.setResult(elem.Elem(), value, mappings); err != nil {
+			return err
+		}
+		dest.Set(elem)
+		return nil
+
+	case dest.Kind() == reflect.Map:
+		dataMap, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected map[string]interface{}, got %T", value)
+		}
+		if dest.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("map target must have string keys, got %s", dest.Type())
+		}
+		out := reflect.MakeMapWithSize(dest.Type(), len(dataMap))
+		for key, item := range dataMap {
+			elem := reflect.New(dest.Type().Elem()).Elem()
+			if item != nil {
+				if err := pm.setDirect(elem, item); err != nil {
+					return fmt.Errorf("failed to set key '%s': %w", key, err)
+				}
+			}
+			out.SetMapIndex(reflect.ValueOf(key).Convert(dest.Type().Key()), elem)
+		}
+		dest.Set(out)
+		return nil
+
+	default:
+		typeHint := ""
+		if len(mappings) > 0 {
+			typeHint = mappings[0].Type
+		}
+		if dataMap, ok := value.(map[string]interface{}); ok {
+			scalar, err := scalarField(dataMap, mappings)
+			if err != nil {
+				return err
+			}
+			value = scalar
+		}
+		return pm.setValue(dest, value, typeHint)
+	}
+}
+
+// unmarshalResult maps dataMap into dest through a custom unmarshaler, if dest has one.
+// It reports whether a custom unmarshaler was used.
+func (pm *PropertyMapper) unmarshalResult(dest reflect.Value, dataMap map[string]interface{}, mappings []config.PropertyMap) (bool, error) {
+	dataUnmarshalerType := reflect.TypeOf((*DataUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType := reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+	// Pointer destinations get a fresh value that unmarshals itself
+	target := dest
+	if dest.Kind() == reflect.Ptr {
+		target = reflect.New(dest.Type().Elem())
+	} else if dest.CanAddr() {
+		target = dest.Addr()
+	}
+
+	switch {
+	case target.Type().Implements(dataUnmarshalerType):
+		if err := target.Interface().(DataUnmarshaler).UnmarshalData(dataMap); err != nil {
+			return true, err
+		}
+	case len(mappings) == 0 && target.Type().Implements(jsonUnmarshalerType):
+		data, err := json.Marshal(dataMap)
+		if err != nil {
+			return true, fmt.Errorf("failed to marshal to JSON: %w", err)
+		}
+		if err := target.Interface().(json.Unmarshaler).UnmarshalJSON(data); err != nil {
+			return true, fmt.Errorf("failed to unmarshal JSON: %w", err)
+		}
+	default:
+		return false, nil
+	}
+
+	if dest.Kind() == reflect.Ptr {
+		dest.Set(target)
+	}
+	return true, nil
+}
+
+// scalarField picks the value a scalar target should receive from a data map.
+func scalarField(data map[string]interface{}, mappings []config.PropertyMap) (interface{}, error) {
+	if len(mappings) > 0 {
+		value, exists := data[mappings[0].Field]
+		if !exists {
+			return nil, fmt.Errorf("field '%s' not found in result", mappings[0].Field)
+		}
+		return value, nil
+	}
+
+	if len(data) == 1 {
+		for _, value := range data {
+			return value, nil
+		}
+	}
+
+	return nil, fmt.Errorf("cannot map %d fields into a scalar without a property mapping", len(data))
+}
+
 // MapFromObject extracts data fields from object properties.
 // obj can be a struct or a pointer to a struct.
 func (pm *PropertyMapper) MapFromObject(obj interface{}, mappings []config.PropertyMap) (map[string]interface{}, error) {
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
@@ -585,3 +586,99 @@ func TestPropertyMapper_GetValue_InvalidField(t *testing.T) {
 		t.Errorf("Expected nil data for error case, got %v", data)
 	}
 }
+
+func TestPropertyMapper_MapToValue(t *testing.T) {
+	pm := NewPropertyMapper()
+	mappings := []config.PropertyMap{
+		{Object: "UserID", Field: "user_id"},
+		{Object: "Bio", Field: "bio"},
+	}
+	data := map[string]interface{}{"user_id": 7, "bio": "hello"}
+
+	var profile TestProfile
+	if err := pm.MapToValue(data, &profile, mappings); err != nil {
+		t.Fatalf("MapToValue(struct) error = %v", err)
+	}
+	if profile.UserID != 7 || profile.Bio != "hello" {
+		t.Errorf("profile = %+v", profile)
+	}
+
+	var profilePtr *TestProfile
+	if err := pm.MapToValue(data, &profilePtr, mappings); err != nil {
+		t.Fatalf("MapToValue(pointer) error = %v", err)
+	}
+	if profilePtr == nil || profilePtr.Bio != "hello" {
+		t.Errorf("profilePtr = %+v", profilePtr)
+	}
+
+	var raw map[string]interface{}
+	if err := pm.MapToValue(data, &raw, mappings); err != nil {
+		t.Fatalf("MapToValue(map) error = %v", err)
+	}
+	if raw["bio"] != "hello" {
+		t.Errorf("raw = %v", raw)
+	}
+
+	var id int64
+	if err := pm.MapToValue(data, &id, mappings); err != nil {
+		t.Fatalf("MapToValue(scalar) error = %v", err)
+	}
+	if id != 7 {
+		t.Errorf("id = %d, want 7", id)
+	}
+
+	var anything interface{}
+	if err := pm.MapToValue(data, &anything, nil); err != nil {
+		t.Fatalf("MapToValue(interface) error = %v", err)
+	}
+	if _, ok := anything.(map[string]interface{}); !ok {
+		t.Errorf("anything = %T, want map", anything)
+	}
+}
+
+func TestPropertyMapper_MapToValue_Errors(t *testing.T) {
+	pm := NewPropertyMapper()
+
+	var profile TestProfile
+	if err := pm.MapToValue(map[string]interface{}{}, profile, nil); err == nil {
+		t.Error("Expected error for non-pointer target")
+	}
+	if err := pm.MapToValue("text", &profile, nil); err == nil {
+		t.Error("Expected error mapping a scalar into a struct")
+	}
+
+	var n int
+	if err := pm.MapToValue(map[string]interface{}{"a": 1, "b": 2}, &n, nil); err == nil {
+		t.Error("Expected error mapping a multi-field map into a scalar")
+	}
+}
+
+func TestPropertyMapper_MapToSlice(t *testing.T) {
+	pm := NewPropertyMapper()
+	mappings := []config.PropertyMap{
+		{Object: "UserID", Field: "user_id"},
+	}
+	data := []interface{}{
+		map[string]interface{}{"user_id": 1},
+		map[string]interface{}{"user_id": 2},
+	}
+
+	var profiles []TestProfile
+	if err := pm.MapToSlice(data, &profiles, mappings); err != nil {
+		t.Fatalf("MapToSlice() error = %v", err)
+	}
+	if len(profiles) != 2 || profiles[1].UserID != 2 {
+		t.Errorf("profiles = %+v", profiles)
+	}
+
+	var notSlice TestProfile
+	if err := pm.MapToSlice(data, &notSlice, mappings); err == nil {
+		t.Error("Expected error for non-slice target")
+	}
+
+	bad := []interface{}{map[string]interface{}{"user_id": 1}, "oops"}
+	err := pm.MapToSlice(bad, &profiles, mappings)
+	if err == nil || !strings.Contains(err.Error(), "element 1") {
+		t.Errorf("MapToSlice() error = %v, want element 1 failure", err)
+	}
+}
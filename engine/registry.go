@@ -21,7 +21,10 @@ type AdapterRegistry struct {
 	// instances maps source identifiers to active adapter instances
 	instances map[string]adapter.Adapter
 
-	// mu protects concurrent access to instances
+	// breakers maps source identifiers to the circuit breakers of sources that configure one
+	breakers map[string]*circuitBreaker
+
+	// mu protects concurrent access to instances and breakers
 	mu sync.RWMutex
 }
 
@@ -30,6 +33,7 @@ func NewAdapterRegistry() *AdapterRegistry {
 	return &AdapterRegistry{
 		factories: make(map[string]AdapterFactory),
 		instances: make(map[string]adapter.Adapter),
+		breakers:  make(map[string]*circuitBreaker),
 	}
 }
 
@@ -43,7 +47,14 @@ func (ar *AdapterRegistry) Register(adapterType string, factory AdapterFactory)
 
 // GetAdapter returns an adapter instance for the given source.
 // If an instance already exists, it is reused. Otherwise, a new one is created.
+// When the source configures a circuit breaker and its circuit is open, GetAdapter
+// fails fast with adapter.ErrCircuitOpen; failures to connect count against the circuit.
 func (ar *AdapterRegistry) GetAdapter(ctx context.Context, source config.Source, sourceID string) (adapter.Adapter, error) {
+	breaker := ar.breakerFor(source, sourceID)
+	if breaker != nil && breaker.open() {
+		return nil, fmt.Errorf("source '%s': %w", sourceID, adapter.ErrCircuitOpen)
+	}
+
 	// Check if instance already exists
 	ar.mu.RLock()
 	if instance, exists := ar.instances[sourceID]; exists {
@@ -75,6 +86,9 @@ func (ar *AdapterRegistry) GetAdapter(ctx context.Context, source config.Source,
 
 	// Connect to data source
 	if err := instance.Connect(ctx, source.Options); err != nil {
+		if breaker != nil {
+			breaker.record(err)
+		}
 		return nil, fmt.Errorf("failed to connect adapter '%s': %w", source.Adapter, err)
 	}
 
@@ -149,3 +163,56 @@ func (ar *AdapterRegistry) ListInstances() []string {
 	}
 	return ids
 }
+
+// CircuitStatus returns the state of a source's circuit breaker, and whether the
+// source has one. Breakers are created with the source's first adapter instance.
+func (ar *AdapterRegistry) CircuitStatus(sourceID string) (CircuitStatus, bool) {
+	breaker := ar.breaker(sourceID)
+	if breaker == nil {
+		return CircuitStatus{}, false
+	}
+	return breaker.status(), true
+}
+
+// CircuitStatuses returns the states of all circuit breakers by source ID.
+func (ar *AdapterRegistry) CircuitStatuses() map[string]CircuitStatus {
+	ar.mu.RLock()
+	breakers := make(map[string]*circuitBreaker, len(ar.breakers))
+	for id, breaker := range ar.breakers {
+		breakers[id] = breaker
+	}
+	ar.mu.RUnlock()
+
+	statuses := make(map[string]CircuitStatus, len(breakers))
+	for id, breaker := range breakers {
+		statuses[id] = breaker.status()
+	}
+	return statuses
+}
+
+// breaker returns the circuit breaker of a source, or nil.
+func (ar *AdapterRegistry) breaker(sourceID string) *circuitBreaker {
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+	return ar.breakers[sourceID]
+}
+
+// breakerFor returns the circuit breaker of a source, creating it on first use,
+// or nil when the source does not configure one.
+func (ar *AdapterRegistry) breakerFor(source config.Source, sourceID string) *circuitBreaker {
+	if source.CircuitBreaker == nil {
+		return nil
+	}
+	if breaker := ar.breaker(sourceID); breaker != nil {
+		return breaker
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	if breaker, exists := ar.breakers[sourceID]; exists {
+		return breaker
+	}
+	breaker := newCircuitBreaker(source.CircuitBreaker)
+	ar.breakers[sourceID] = breaker
+	return breaker
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// includeRelations loads the included relations of the objects fetched into target,
+// a pointer to a struct or to a slice of structs or struct pointers. Each relation is
+// fetched for all objects at once (see fetchByKeys).
+func (m *Mapper) includeRelations(ctx context.Context, mappingID string, mapping *config.Mapping, cfg *config.Config, target interface{}, include []string) error {
+	if len(include) == 0 {
+		return nil
+	}
+
+	parents, err := relationParents(reflect.ValueOf(target))
+	if err != nil {
+		return err
+	}
+
+	names, nested := splitIncludes(include)
+	for _, name := range names {
+		relation, exists := mapping.Relations[name]
+		if !exists {
+			return fmt.Errorf("mapping '%s' does not have a relation '%s'", mappingID, name)
+		}
+		if err := m.loadRelation(ctx, cfg, name, relation, parents, nested[name]); err != nil {
+			return fmt.Errorf("failed to load relation '%s': %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// loadRelation fills one relation field of parents, addressable structs of one type.
+func (m *Mapper) loadRelation(ctx context.Context, cfg *config.Config, name string, relation config.RelationConfig, parents []reflect.Value, include []string) error {
+	if len(parents) == 0 {
+		return nil
+	}
+	parentType := parents[0].Type()
+
+	keyField, ok := parentType.FieldByName(relation.LocalKey)
+	if !ok {
+		return fmt.Errorf("%s has no field '%s'", parentType, relation.LocalKey)
+	}
+
+	fieldName := relation.Object
+	if fieldName == "" {
+		fieldName = name
+	}
+	field, ok := parentType.FieldByName(fieldName)
+	if !ok || !field.IsExported() {
+		return fmt.Errorf("%s has no settable field '%s'", parentType, fieldName)
+	}
+
+	// Find the struct type of the related objects
+	structType := field.Type
+	if relation.Type == config.RelationHasMany {
+		if structType.Kind() != reflect.Slice {
+			return fmt.Errorf("field '%s' must be a slice for a has_many relation, got %s", fieldName, field.Type)
+		}
+		structType = structType.Elem()
+	}
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("field '%s' must hold structs or struct pointers for a %s relation, got %s", fieldName, relation.Type, field.Type)
+	}
+
+	// Collect the distinct keys; unset keys relate to nothing
+	var keys []interface{}
+	seen := make(map[string]bool)
+	for _, parent := range parents {
+		key := parent.FieldByIndex(keyField.Index)
+		if key.IsZero() {
+			continue
+		}
+		if k := batchKey(key.Interface()); !seen[k] {
+			seen[k] = true
+			keys = append(keys, key.Interface())
+		}
+	}
+
+	relatedID := config.QualifyMappingID(cfg.Namespace, relation.Mapping)
+	related, err := m.fetchByKeys(ctx, relatedID, relation.ForeignKey, structType, keys, include, DefaultBatchParallelism)
+	if err != nil {
+		return err
+	}
+
+	// Fan the related objects out to their parents
+	for _, parent := range parents {
+		target := parent.FieldByIndex(field.Index)
+		target.Set(reflect.Zero(field.Type))
+
+		key := parent.FieldByIndex(keyField.Index)
+		if key.IsZero() {
+			continue
+		}
+		matches := related[batchKey(key.Interface())]
+
+		if relation.Type == config.RelationHasMany {
+			values := reflect.MakeSlice(field.Type, 0, len(matches))
+			for _, ptr := range matches {
+				values = reflect.Append(values, relatedValue(ptr, field.Type.Elem()))
+			}
+			target.Set(values)
+			continue
+		}
+		if len(matches) > 0 {
+			target.Set(relatedValue(matches[0], field.Type))
+		}
+	}
+
+	return nil
+}
+
+// relatedValue converts a pointer to a related object to t, the pointer or struct
+// type of a relation field.
+func relatedValue(ptr reflect.Value, t reflect.Type) reflect.Value {
+	if t.Kind() == reflect.Ptr {
+		return ptr
+	}
+	return ptr.Elem()
+}
+
+// relationParents returns the addressable structs fetched into target.
+func relationParents(target reflect.Value) ([]reflect.Value, error) {
+	for target.Kind() == reflect.Ptr {
+		if target.IsNil() {
+			return nil, nil
+		}
+		target = target.Elem()
+	}
+
+	switch target.Kind() {
+	case reflect.Struct:
+		return []reflect.Value{target}, nil
+	case reflect.Slice:
+		parents := make([]reflect.Value, 0, target.Len())
+		for i := 0; i < target.Len(); i++ {
+			elem := target.Index(i)
+			if elem.Kind() == reflect.Ptr {
+				if elem.IsNil() {
+					continue
+				}
+				elem = elem.Elem()
+			}
+			if elem.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("cannot include relations into %s elements", target.Type().Elem())
+			}
+			parents = append(parents, elem)
+		}
+		return parents, nil
+	default:
+		return nil, fmt.Errorf("cannot include relations into %s", target.Type())
+	}
+}
+
+// splitIncludes groups dotted include paths by their first relation, keeping the
+// order in which relations are first named.
+func splitIncludes(include []string) ([]string, map[string][]string) {
+	var names []string
+	nested := make(map[string][]string)
+	for _, path := range include {
+		name, rest, hasRest := strings.Cut(path, ".")
+		if _, seen := nested[name]; !seen {
+			names = append(names, name)
+			nested[name] = nil
+		}
+		if hasRest {
+			nested[name] = append(nested[name], rest)
+		}
+	}
+	return names, nested
+}
@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+const relationTestConfig = `namespace: shop
+version: "1.0"
+sources:
+  orders:
+    adapter: orders
+    connection: "localhost"
+  lines:
+    adapter: lines
+    connection: "localhost"
+  crm:
+    adapter: crm
+    connection: "localhost"
+mappings:
+  order:
+    object: Order
+    source: orders
+    relations:
+      Lines:
+        type: has_many
+        mapping: line
+        local_key: ID
+        foreign_key: OrderID
+      Customer:
+        type: belongs_to
+        mapping: customer
+        local_key: CustomerID
+        foreign_key: ID
+    operations:
+      fetch:
+        statement: "orders/{id}.json"
+        parameters:
+          - object: ID
+            field: id
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: CustomerID
+              field: customer_id
+  line:
+    object: Line
+    source: lines
+    relations:
+      Product:
+        type: belongs_to
+        mapping: shop.product
+        local_key: ProductID
+        foreign_key: ID
+    operations:
+      fetch:
+        statement: "lines/*.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: OrderID
+              field: order_id
+            - object: ProductID
+              field: product_id
+  customer:
+    object: Customer
+    source: crm
+    operations:
+      fetch:
+        statement: "customers/{id}.json"
+        parameters:
+          - object: ID
+            field: id
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+  product:
+    object: Product
+    source: crm
+    operations:
+      fetch:
+        statement: "products/{id}.json"
+        parameters:
+          - object: ID
+            field: id
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+`
+
+type relProduct struct {
+	ID   string
+	Name string
+}
+
+type relLine struct {
+	ID        string
+	OrderID   string
+	ProductID string
+	Product   relProduct
+}
+
+type relCustomer struct {
+	ID   string
+	Name string
+}
+
+type relOrder struct {
+	ID         string
+	CustomerID string
+	Lines      []relLine
+	Customer   *relCustomer
+}
+
+func newRelationTestMapper(t *testing.T) (*Mapper, *stubAdapter) {
+	t.Helper()
+	mapper := newTestMapper(t, relationTestConfig)
+
+	orders := map[string]map[string]interface{}{
+		"o1": {"id": "o1", "customer_id": "c1"},
+		"o2": {"id": "o2", "customer_id": "c404"},
+	}
+	registerStub(mapper, "orders", &stubAdapter{
+		fetchFn: func(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+			if order, exists := orders[params["id"].(string)]; exists {
+				return []interface{}{order}, nil
+			}
+			return nil, adapter.ErrNotFound
+		},
+	})
+
+	lines := &stubAdapter{fetchFn: fetchReturning(
+		map[string]interface{}{"id": "l1", "order_id": "o1", "product_id": "p1"},
+		map[string]interface{}{"id": "l2", "order_id": "o1", "product_id": "p2"},
+		map[string]interface{}{"id": "l3", "order_id": "o2", "product_id": "p1"},
+	)}
+	registerStub(mapper, "lines", lines)
+
+	records := map[string]map[string]interface{}{
+		"customers/c1.json": {"id": "c1", "name": "Ada"},
+		"products/p1.json":  {"id": "p1", "name": "Desk"},
+		"products/p2.json":  {"id": "p2", "name": "Lamp"},
+	}
+	registerStub(mapper, "crm", &stubAdapter{
+		fetchFn: func(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+			path := strings.Replace(op.Statement, "{id}", params["id"].(string), 1)
+			if record, exists := records[path]; exists {
+				return []interface{}{record}, nil
+			}
+			return nil, adapter.ErrNotFound
+		},
+	})
+
+	return mapper, lines
+}
+
+func TestMapper_Fetch_Include(t *testing.T) {
+	mapper, _ := newRelationTestMapper(t)
+
+	var order relOrder
+	err := mapper.Fetch(context.Background(), "shop.order", map[string]interface{}{"id": "o1"}, &order,
+		Include("Lines.Product", "Customer"))
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	if order.Customer == nil || order.Customer.Name != "Ada" {
+		t.Errorf("Customer = %+v, want Ada", order.Customer)
+	}
+	if len(order.Lines) != 2 {
+		t.Fatalf("Lines = %+v, want the two lines of o1", order.Lines)
+	}
+	if order.Lines[0].Product.Name != "Desk" || order.Lines[1].Product.Name != "Lamp" {
+		t.Errorf("line products = %q, %q, want Desk, Lamp", order.Lines[0].Product.Name, order.Lines[1].Product.Name)
+	}
+}
+
+func TestMapper_Fetch_IncludeOnlyRequested(t *testing.T) {
+	mapper, lines := newRelationTestMapper(t)
+
+	var order relOrder
+	if err := mapper.Fetch(context.Background(), "shop.order", map[string]interface{}{"id": "o1"}, &order, Include("Customer")); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if order.Lines != nil || lines.callCount("fetch") != 0 {
+		t.Error("relations that are not included should not be loaded")
+	}
+}
+
+func TestMapper_FetchMulti_Include(t *testing.T) {
+	mapper, _ := newRelationTestMapper(t)
+
+	var orders []*relOrder
+	err := mapper.FetchMulti(context.Background(), "shop.order", map[string]interface{}{"id": "o2"}, &orders,
+		Include("Customer", "Lines"))
+	if err != nil {
+		t.Fatalf("FetchMulti() error = %v", err)
+	}
+
+	if len(orders) != 1 {
+		t.Fatalf("got %d orders, want 1", len(orders))
+	}
+	if orders[0].Customer != nil {
+		t.Errorf("Customer = %+v, want nil for a missing customer", orders[0].Customer)
+	}
+	if len(orders[0].Lines) != 1 || orders[0].Lines[0].ID != "l3" {
+		t.Errorf("Lines = %+v, want l3", orders[0].Lines)
+	}
+	if orders[0].Lines[0].Product.ID != "" {
+		t.Error("nested relations should only be loaded when included")
+	}
+}
+
+func TestMapper_Fetch_IncludeUnknownRelation(t *testing.T) {
+	mapper, _ := newRelationTestMapper(t)
+
+	var order relOrder
+	err := mapper.Fetch(context.Background(), "shop.order", map[string]interface{}{"id": "o1"}, &order, Include("Invoices"))
+	if err == nil || !strings.Contains(err.Error(), "Invoices") {
+		t.Errorf("Fetch() error = %v, want unknown relation error", err)
+	}
+}
@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// Retry defaults, used for settings a source's retry policy leaves unset.
+const (
+	// DefaultRetryAttempts is the total number of attempts of a retried call.
+	DefaultRetryAttempts = 3
+
+	// DefaultRetryBaseBackoff is the delay before the first retry.
+	DefaultRetryBaseBackoff = 100 * time.Millisecond
+
+	// DefaultRetryMaxBackoff caps the delay between attempts.
+	DefaultRetryMaxBackoff = 2 * time.Second
+)
+
+// defaultRetryOn lists the error codes retried when a policy names none.
+var defaultRetryOn = []string{adapter.ErrConnection.Code, adapter.ErrTimeout.Code}
+
+// retryPolicy is a source's parsed retry configuration.
+type retryPolicy struct {
+	attempts    int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	jitter      float64
+	retryOn     []string
+}
+
+// newRetryPolicy parses a retry configuration, applying the defaults.
+// Durations are checked when the configuration is validated.
+func newRetryPolicy(cfg *config.RetryConfig) *retryPolicy {
+	p := &retryPolicy{
+		attempts:    cfg.MaxAttempts,
+		baseBackoff: DefaultRetryBaseBackoff,
+		maxBackoff:  DefaultRetryMaxBackoff,
+		jitter:      cfg.Jitter,
+		retryOn:     cfg.RetryOn,
+	}
+	if p.attempts == 0 {
+		p.attempts = DefaultRetryAttempts
+	}
+	if d, err := time.ParseDuration(cfg.BaseBackoff); err == nil {
+		p.baseBackoff = d
+	}
+	if d, err := time.ParseDuration(cfg.MaxBackoff); err == nil {
+		p.maxBackoff = d
+	}
+	if len(p.retryOn) == 0 {
+		p.retryOn = defaultRetryOn
+	}
+	return p
+}
+
+// retryable reports whether err carries one of the policy's error codes.
+// Deadlines reached by the adapter count as TIMEOUT.
+func (p *retryPolicy) retryable(err error) bool {
+	for _, code := range p.retryOn {
+		if errors.Is(err, &adapter.AdapterError{Code: code}) {
+			return true
+		}
+		if code == adapter.ErrTimeout.Code && errors.Is(err, context.DeadlineExceeded) {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before the given retry (1 for the first).
+func (p *retryPolicy) backoff(retry int) time.Duration {
+	delay := p.baseBackoff
+	for i := 1; i < retry && delay < p.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.maxBackoff {
+		delay = p.maxBackoff
+	}
+	if p.jitter > 0 {
+		delay -= time.Duration(rand.Float64() * p.jitter * float64(delay))
+	}
+	return delay
+}
+
+// retryPolicyFor returns the retry policy applying to an invocation, or nil when
+// it must not be retried: the source has no policy, the call is a write or action
+// not marked idempotent, or it runs inside a transaction (whose adapter
+// transaction a failure leaves unusable).
+func (m *Mapper) retryPolicyFor(ctx context.Context, inv *Invocation) *retryPolicy {
+	if !inv.Idempotent || txFromContext(ctx) != nil {
+		return nil
+	}
+	source, exists := m.invocationSource(inv)
+	if !exists || source.Retry == nil {
+		return nil
+	}
+	return newRetryPolicy(source.Retry)
+}
+
+// withRetry wraps call so that retryable failures are attempted again with
+// exponential backoff. Retrying stops once ctx is done, returning the last error.
+func withRetry(policy *retryPolicy, call Invoker) Invoker {
+	return func(ctx context.Context, inv *Invocation) error {
+		var err error
+		for attempt := 1; ; attempt++ {
+			if err = call(ctx, inv); err == nil || attempt >= policy.attempts || !policy.retryable(err) || ctx.Err() != nil {
+				return err
+			}
+
+			timer := time.NewTimer(policy.backoff(attempt))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+const retryTestConfig = `namespace: app
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+    retry:
+      max_attempts: 3
+      base_backoff: 1ms
+      max_backoff: 5ms
+      jitter: 0.5
+mappings:
+  user:
+    object: User
+    source: primary
+    operations:
+      fetch:
+        statement: "users/{id}.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+      insert:
+        statement: "users/{id}.json"
+        properties:
+          - object: ID
+            field: id
+      update:
+        statement: "users/{id}.json"
+        idempotent: true
+        properties:
+          - object: ID
+            field: id
+`
+
+type retryUser struct {
+	ID string
+}
+
+// failingTimes returns a fetch function failing with err the first n calls.
+func failingTimes(n int32, err error) func(context.Context, *adapter.Operation, map[string]interface{}) ([]interface{}, error) {
+	var calls int32
+	return func(context.Context, *adapter.Operation, map[string]interface{}) ([]interface{}, error) {
+		if atomic.AddInt32(&calls, 1) <= n {
+			return nil, err
+		}
+		return []interface{}{map[string]interface{}{"id": "1"}}, nil
+	}
+}
+
+func TestMapper_Retry_Fetch(t *testing.T) {
+	mapper := newTestMapper(t, retryTestConfig)
+	primary := &stubAdapter{fetchFn: failingTimes(2, adapter.NewAdapterError(adapter.ErrConnection.Code, "reset by peer", nil))}
+	registerStub(mapper, "primary", primary)
+
+	var intercepted int
+	mapper.Use(func(ctx context.Context, inv *Invocation, next Invoker) error {
+		intercepted++
+		return next(ctx, inv)
+	})
+
+	var user retryUser
+	if err := mapper.Fetch(context.Background(), "app.user", map[string]interface{}{"id": "1"}, &user); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if primary.callCount("fetch") != 3 {
+		t.Errorf("fetch attempts = %d, want 3", primary.callCount("fetch"))
+	}
+	if intercepted != 1 {
+		t.Errorf("intercepted calls = %d, want 1", intercepted)
+	}
+}
+
+func TestMapper_Retry_GivesUp(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{"attempts exhausted", adapter.ErrTimeout, 3},
+		{"adapter deadline", context.DeadlineExceeded, 3},
+		{"not retryable", adapter.ErrNotFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := newTestMapper(t, retryTestConfig)
+			primary := &stubAdapter{fetchFn: fetchFailing(tt.err)}
+			registerStub(mapper, "primary", primary)
+
+			var user retryUser
+			err := mapper.Fetch(context.Background(), "app.user", map[string]interface{}{"id": "1"}, &user)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Fetch() error = %v, want %v", err, tt.err)
+			}
+			if primary.callCount("fetch") != tt.wantCalls {
+				t.Errorf("fetch attempts = %d, want %d", primary.callCount("fetch"), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMapper_Retry_OnlyIdempotentWrites(t *testing.T) {
+	mapper := newTestMapper(t, retryTestConfig)
+	primary := &stubAdapter{
+		insertFn: func(context.Context, *adapter.Operation, []interface{}) error { return adapter.ErrConnection },
+		updateFn: func(context.Context, *adapter.Operation, []interface{}) error { return adapter.ErrConnection },
+	}
+	registerStub(mapper, "primary", primary)
+	ctx := context.Background()
+
+	if err := mapper.Insert(ctx, "app.user", &retryUser{ID: "1"}); !errors.Is(err, adapter.ErrConnection) {
+		t.Errorf("Insert() error = %v, want ErrConnection", err)
+	}
+	if primary.callCount("insert") != 1 {
+		t.Errorf("insert attempts = %d, want 1 (not idempotent)", primary.callCount("insert"))
+	}
+
+	if err := mapper.Update(ctx, "app.user", &retryUser{ID: "1"}); !errors.Is(err, adapter.ErrConnection) {
+		t.Errorf("Update() error = %v, want ErrConnection", err)
+	}
+	if primary.callCount("update") != 3 {
+		t.Errorf("update attempts = %d, want 3 (idempotent)", primary.callCount("update"))
+	}
+}
+
+func TestMapper_Retry_StopsOnCancel(t *testing.T) {
+	mapper := newTestMapper(t, retryTestConfig)
+	cfg, _ := mapper.parser.GetConfig("app")
+	source := cfg.Sources["primary"]
+	source.Retry = &config.RetryConfig{MaxAttempts: 10, BaseBackoff: "1h"}
+	cfg.Sources["primary"] = source
+
+	primary := &stubAdapter{fetchFn: fetchFailing(adapter.ErrConnection)}
+	registerStub(mapper, "primary", primary)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	var user retryUser
+	if err := mapper.Fetch(ctx, "app.user", map[string]interface{}{"id": "1"}, &user); !errors.Is(err, adapter.ErrConnection) {
+		t.Errorf("Fetch() error = %v, want ErrConnection", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("retries should stop when the context is done")
+	}
+	if primary.callCount("fetch") != 1 {
+		t.Errorf("fetch attempts = %d, want 1", primary.callCount("fetch"))
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := newRetryPolicy(&config.RetryConfig{BaseBackoff: "10ms", MaxBackoff: "50ms"})
+
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for i, w := range want {
+		if got := policy.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	if policy.attempts != DefaultRetryAttempts {
+		t.Errorf("attempts = %d, want the default %d", policy.attempts, DefaultRetryAttempts)
+	}
+}
@@ -0,0 +1,288 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// Session is a unit of reads and writes sharing an identity map: within a session,
+// fetching the same mapping with the same identifier values returns the same
+// object instance without querying the source again.
+//
+// A session is meant to live for a single request or task; it ends when the caller
+// discards it. Writes made through the session keep the identity map current, but
+// writes made elsewhere are not observed. A Session is safe for concurrent use.
+//
+// The session also snapshots the instances it fetches or writes, so that Update
+// sends only the fields changed since (see Session.Update).
+type Session struct {
+	mapper *Mapper
+	ctx    context.Context
+
+	mu sync.Mutex
+
+	// identities maps identity keys to pointers to the fetched or written objects
+	identities map[string]reflect.Value
+
+	// snapshots maps identity keys to the update data of the instances when last
+	// fetched or written
+	snapshots map[string]map[string]interface{}
+}
+
+// Session starts a session whose operations run with ctx.
+func (m *Mapper) Session(ctx context.Context) *Session {
+	return &Session{
+		mapper:     m,
+		ctx:        ctx,
+		identities: make(map[string]reflect.Value),
+		snapshots:  make(map[string]map[string]interface{}),
+	}
+}
+
+// Fetch retrieves a single object like Mapper.Fetch, answering from the identity
+// map when the mapping was already fetched or written with the same identifier values.
+//
+// Pass a pointer to a pointer (e.g. **User) to receive the session's shared instance,
+// so that repeated fetches return the same *User; with a pointer to a value the
+// instance is copied into result. Only fetches whose params are exactly the
+// mapping's identifier values (its fetch parameters, or else its update or delete
+// identifier) use the identity map; fetches with other params, Where, Include or
+// WithDeleted go to the source every time. A fetch answered from the identity map
+// reports an empty source ID through ReportSource.
+func (s *Session) Fetch(mappingID string, params map[string]interface{}, result interface{}, opts ...FetchOption) error {
+	target := reflect.ValueOf(result)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("result must be a non-nil pointer, got %T", result)
+	}
+
+	mapping, _, err := s.mapper.parser.GetMapping(mappingID)
+	if err != nil {
+		return err
+	}
+
+	options := newFetchOptions(opts)
+	key, ok := identityKey(mappingID, identityFields(mapping), params)
+	if !ok || options.where != nil || len(options.include) > 0 || options.withDeleted {
+		return s.mapper.Fetch(s.ctx, mappingID, params, result, opts...)
+	}
+
+	// The identity map holds pointers: **T results share them, *T results get copies
+	shared := target.Elem().Kind() == reflect.Ptr
+	instanceType := target.Type()
+	if shared {
+		instanceType = target.Elem().Type()
+	}
+
+	s.mu.Lock()
+	instance, exists := s.identities[key]
+	s.mu.Unlock()
+
+	if exists && instance.Type() == instanceType {
+		if options.sourceID != nil {
+			*options.sourceID = ""
+		}
+	} else {
+		instance = reflect.New(instanceType.Elem())
+		if err := s.mapper.Fetch(s.ctx, mappingID, params, instance.Interface(), opts...); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		s.identities[key] = instance
+		s.snapshot(key, mapping, instance.Interface())
+		s.mu.Unlock()
+	}
+
+	if shared {
+		target.Elem().Set(instance)
+	} else {
+		target.Elem().Set(instance.Elem())
+	}
+	return nil
+}
+
+// Insert creates objects like Mapper.Insert. Inserted objects passed by pointer
+// (or in a slice of structs) become the session's instances for their identifiers.
+func (s *Session) Insert(mappingID string, objects interface{}) error {
+	if err := s.mapper.Insert(s.ctx, mappingID, objects); err != nil {
+		return err
+	}
+	return s.remember(mappingID, objects)
+}
+
+// Update modifies objects like Mapper.Update. Instances already in the session are
+// updated in place, so objects obtained from earlier fetches observe the new values.
+//
+// Objects whose identifiers match an instance the session fetched or wrote are
+// compared with its snapshot: the adapter receives a partial update
+// (adapter.Operation.Partial) carrying only the changed data fields plus the
+// identifier and condition fields, and objects without changes are not sent.
+// Other objects are sent in full.
+func (s *Session) Update(mappingID string, objects interface{}) error {
+	mapping, _, err := s.mapper.parser.GetMapping(mappingID)
+	if err != nil {
+		return err
+	}
+	objectSlice, err := s.mapper.toSlice(objects)
+	if err != nil {
+		return fmt.Errorf("failed to convert objects: %w", err)
+	}
+
+	if err := s.mapper.update(s.ctx, mappingID, objects, s.snapshotsOf(mappingID, mapping, objectSlice)); err != nil {
+		return err
+	}
+	return s.remember(mappingID, objects)
+}
+
+// Delete removes objects like Mapper.Delete and evicts them from the session.
+func (s *Session) Delete(mappingID string, identifiers interface{}, opts ...DeleteOption) error {
+	if err := s.mapper.Delete(s.ctx, mappingID, identifiers, opts...); err != nil {
+		return err
+	}
+
+	mapping, _, err := s.mapper.parser.GetMapping(mappingID)
+	if err != nil {
+		return err
+	}
+	opConfig := mapping.Operations["delete"]
+
+	idSlice, err := s.mapper.toSlice(identifiers)
+	if err != nil {
+		return fmt.Errorf("failed to convert identifiers: %w", err)
+	}
+
+	keyFields := identityFields(mapping)
+	dataMaps := s.mapper.identifierMaps(&opConfig, idSlice)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(dataMaps) != len(idSlice) {
+		// Some identifiers cannot be keyed: forget the whole mapping
+		s.evictMapping(mappingID)
+		return nil
+	}
+	for _, data := range dataMaps {
+		key, ok := identityKey(mappingID, keyFields, data)
+		if !ok {
+			s.evictMapping(mappingID)
+			return nil
+		}
+		delete(s.identities, key)
+		delete(s.snapshots, key)
+	}
+	return nil
+}
+
+// remember records written objects as the session's instances for their identifiers.
+// Objects whose identifiers cannot be determined evict every instance of the mapping.
+func (s *Session) remember(mappingID string, objects interface{}) error {
+	mapping, _, err := s.mapper.parser.GetMapping(mappingID)
+	if err != nil {
+		return err
+	}
+	keyFields := identityFields(mapping)
+
+	objectSlice, err := s.mapper.toSlice(objects)
+	if err != nil {
+		return fmt.Errorf("failed to convert objects: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, obj := range objectSlice {
+		data, err := s.mapper.propMap.MapFromObject(obj, keyFields)
+		if err != nil {
+			s.evictMapping(mappingID)
+			return nil
+		}
+		key, ok := identityKey(mappingID, keyFields, data)
+		if !ok {
+			// Objects that cannot be keyed may be any instance of the mapping
+			s.evictMapping(mappingID)
+			return nil
+		}
+
+		s.snapshot(key, mapping, obj)
+
+		value := reflect.ValueOf(obj)
+		if value.Kind() != reflect.Ptr || value.IsNil() {
+			delete(s.identities, key)
+			continue
+		}
+
+		instance, exists := s.identities[key]
+		switch {
+		case exists && instance.Type() == value.Type():
+			if instance.Pointer() != value.Pointer() {
+				instance.Elem().Set(value.Elem())
+			}
+		default:
+			s.identities[key] = value
+		}
+	}
+	return nil
+}
+
+// evictMapping forgets every instance of a mapping. The caller must hold s.mu.
+func (s *Session) evictMapping(mappingID string) {
+	prefix := mappingID + "\x00"
+	for key := range s.identities {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.identities, key)
+		}
+	}
+	for key := range s.snapshots {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.snapshots, key)
+		}
+	}
+}
+
+// identityFields returns the property mappings that identify objects of a mapping:
+// the fetch parameters, or else the update or delete identifier.
+func identityFields(mapping *config.Mapping) []config.PropertyMap {
+	if fetch, exists := mapping.Operations["fetch"]; exists && len(fetch.Parameters) > 0 {
+		return fetch.Parameters
+	}
+	for _, name := range []string{"update", "delete"} {
+		if opConfig, exists := mapping.Operations[name]; exists && len(opConfig.Identifier) > 0 {
+			return opConfig.Identifier
+		}
+	}
+	return nil
+}
+
+// identityKey builds the identity map key of a mapping from values keyed by data field.
+// It reports false unless values holds exactly the identity fields.
+func identityKey(mappingID string, keyFields []config.PropertyMap, values map[string]interface{}) (string, bool) {
+	if len(keyFields) == 0 || len(values) != len(keyFields) {
+		return "", false
+	}
+	fields := make([]string, 0, len(keyFields))
+	for _, pm := range keyFields {
+		if _, exists := values[pm.Field]; !exists {
+			return "", false
+		}
+		fields = append(fields, pm.Field)
+	}
+	sort.Strings(fields)
+
+	var key strings.Builder
+	key.WriteString(mappingID)
+	key.WriteString("\x00")
+	for i, field := range fields {
+		if i > 0 {
+			key.WriteString("&")
+		}
+		fmt.Fprintf(&key, "%s=%v", field, values[field])
+	}
+	return key.String(), true
+}
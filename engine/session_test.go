@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+const sessionTestConfig = `namespace: app
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: primary
+    operations:
+      fetch:
+        statement: "users/{id}.json"
+        parameters:
+          - object: ID
+            field: id
+        result:
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+      insert:
+        statement: "users/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+      update:
+        statement: "users/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+        identifier:
+          - object: ID
+            field: id
+      delete:
+        statement: "users/{id}.json"
+        identifier:
+          - object: ID
+            field: id
+`
+
+type sessionUser struct {
+	ID   string
+	Name string
+}
+
+func newSessionTestMapper(t *testing.T) (*Mapper, *stubAdapter) {
+	t.Helper()
+	mapper := newTestMapper(t, sessionTestConfig)
+	primary := &stubAdapter{
+		fetchFn: func(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+			return []interface{}{map[string]interface{}{"id": params["id"], "name": "User " + params["id"].(string)}}, nil
+		},
+	}
+	registerStub(mapper, "primary", primary)
+	return mapper, primary
+}
+
+func TestSession_Fetch_SharesInstances(t *testing.T) {
+	mapper, primary := newSessionTestMapper(t)
+	session := mapper.Session(context.Background())
+
+	var first, second *sessionUser
+	if err := session.Fetch("app.user", map[string]interface{}{"id": "1"}, &first); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if err := session.Fetch("app.user", map[string]interface{}{"id": "1"}, &second); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	if first != second {
+		t.Error("repeated fetches should return the same instance")
+	}
+	if first.Name != "User 1" {
+		t.Errorf("Name = %q, want User 1", first.Name)
+	}
+	if primary.callCount("fetch") != 1 {
+		t.Errorf("adapter fetches = %d, want 1", primary.callCount("fetch"))
+	}
+
+	// Other identifiers and other sessions go to the source
+	var other *sessionUser
+	if err := session.Fetch("app.user", map[string]interface{}{"id": "2"}, &other); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if err := mapper.Session(context.Background()).Fetch("app.user", map[string]interface{}{"id": "1"}, &other); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if other == first || primary.callCount("fetch") != 3 {
+		t.Errorf("adapter fetches = %d, want 3", primary.callCount("fetch"))
+	}
+}
+
+func TestSession_Fetch_CopiesIntoValues(t *testing.T) {
+	mapper, primary := newSessionTestMapper(t)
+	session := mapper.Session(context.Background())
+
+	var shared *sessionUser
+	if err := session.Fetch("app.user", map[string]interface{}{"id": "1"}, &shared); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	var copied sessionUser
+	if err := session.Fetch("app.user", map[string]interface{}{"id": "1"}, &copied); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if copied != *shared || primary.callCount("fetch") != 1 {
+		t.Errorf("copied = %+v after %d fetches, want %+v from one fetch", copied, primary.callCount("fetch"), *shared)
+	}
+}
+
+func TestSession_Fetch_BypassesIdentityMap(t *testing.T) {
+	mapper, primary := newSessionTestMapper(t)
+	session := mapper.Session(context.Background())
+
+	var user *sessionUser
+	params := map[string]interface{}{"id": "1", "active": true}
+	for i := 0; i < 2; i++ {
+		if err := session.Fetch("app.user", params, &user); err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		if err := session.Fetch("app.user", map[string]interface{}{"id": "1"}, &user, Where(adapter.Eq("Name", "User 1"))); err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+	}
+	if primary.callCount("fetch") != 4 {
+		t.Errorf("adapter fetches = %d, want 4", primary.callCount("fetch"))
+	}
+}
+
+func TestSession_Writes(t *testing.T) {
+	mapper, primary := newSessionTestMapper(t)
+	session := mapper.Session(context.Background())
+
+	var user *sessionUser
+	if err := session.Fetch("app.user", map[string]interface{}{"id": "1"}, &user); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	// Updating another instance updates the session's instance in place
+	if err := session.Update("app.user", &sessionUser{ID: "1", Name: "Renamed"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if user.Name != "Renamed" {
+		t.Errorf("Name after update = %q, want Renamed", user.Name)
+	}
+
+	// Inserted instances are returned by later fetches
+	inserted := &sessionUser{ID: "3", Name: "New"}
+	if err := session.Insert("app.user", inserted); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	var fetched *sessionUser
+	if err := session.Fetch("app.user", map[string]interface{}{"id": "3"}, &fetched); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if fetched != inserted || primary.callCount("fetch") != 1 {
+		t.Errorf("fetch after insert should return the inserted instance (adapter fetches = %d)", primary.callCount("fetch"))
+	}
+
+	// Deleted instances are evicted
+	if err := session.Delete("app.user", "1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := session.Fetch("app.user", map[string]interface{}{"id": "1"}, &fetched); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if fetched == user || primary.callCount("fetch") != 2 {
+		t.Errorf("fetch after delete should reach the adapter (adapter fetches = %d)", primary.callCount("fetch"))
+	}
+}
+
+func TestSession_FailedWritesKeepInstances(t *testing.T) {
+	mapper, primary := newSessionTestMapper(t)
+	primary.updateFn = func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+		return adapter.ErrConflict
+	}
+	session := mapper.Session(context.Background())
+
+	var user *sessionUser
+	if err := session.Fetch("app.user", map[string]interface{}{"id": "1"}, &user); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if err := session.Update("app.user", &sessionUser{ID: "1", Name: "Renamed"}); err == nil {
+		t.Fatal("Update() should fail")
+	}
+	if user.Name != "User 1" {
+		t.Errorf("Name after failed update = %q, want User 1", user.Name)
+	}
+}
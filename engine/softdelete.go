@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// softDeleteOperation turns a delete operation into the partial update that marks
+// records as deleted: it keeps the identifier and updates the soft-delete field.
+func softDeleteOperation(deleteOp *adapter.Operation, softDelete *config.SoftDeleteConfig) *adapter.Operation {
+	op := *deleteOp
+	op.Type = adapter.OpUpdate
+	op.Partial = true
+	if softDelete.Statement != "" {
+		op.Statement = softDelete.Statement
+	}
+
+	op.Properties = make([]adapter.PropertyMapping, 0, len(deleteOp.Identifier)+1)
+	op.Properties = append(op.Properties, deleteOp.Identifier...)
+	op.Properties = append(op.Properties, adapter.PropertyMapping{DataField: softDelete.Field})
+	return &op
+}
+
+// softDeleteObjects builds the data of the marking update for each identifier:
+// its identifier fields and the soft-delete field set to the deletion time, or
+// true in flag mode.
+func (m *Mapper) softDeleteObjects(opConfig *config.OperationConfig, softDelete *config.SoftDeleteConfig, identifiers []interface{}) ([]interface{}, error) {
+	var marker interface{} = time.Now().UTC()
+	if softDelete.Mode == config.SoftDeleteFlag {
+		marker = true
+	}
+
+	idMaps := m.identifierMaps(opConfig, identifiers)
+	if len(idMaps) != len(identifiers) {
+		return nil, fmt.Errorf("soft delete requires identifier mappings for all identifiers")
+	}
+
+	objects := make([]interface{}, len(idMaps))
+	for i, idMap := range idMaps {
+		data := make(map[string]interface{}, len(idMap)+1)
+		for field, value := range idMap {
+			data[field] = value
+		}
+		data[softDelete.Field] = marker
+		objects[i] = data
+	}
+	return objects, nil
+}
@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+	"github.com/toutaio/toutago-datamapper/filesystem"
+)
+
+const softDeleteTestConfig = `namespace: app
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+mappings:
+  user:
+    object: User
+    source: primary
+    soft_delete:
+      field: deleted_at
+    operations:
+      fetch:
+        statement: "users/*.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+      delete:
+        statement: "users/{id}.json"
+        identifier:
+          - object: ID
+            field: id
+  tag:
+    object: Tag
+    source: primary
+    soft_delete:
+      field: archived
+      mode: flag
+      statement: "tags/{id}.json"
+    operations:
+      delete:
+        statement: "DELETE tags/{id}.json"
+        identifier:
+          - object: ID
+            field: id
+`
+
+type softUser struct {
+	ID string
+}
+
+func TestMapper_SoftDelete_Delete(t *testing.T) {
+	mapper := newTestMapper(t, softDeleteTestConfig)
+	var updated []interface{}
+	primary := &stubAdapter{
+		updateFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			updated = objects
+			return nil
+		},
+	}
+	registerStub(mapper, "primary", primary)
+	ctx := context.Background()
+
+	if err := mapper.Delete(ctx, "app.user", "1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if primary.callCount("delete") != 0 || primary.callCount("update") != 1 {
+		t.Fatalf("calls = %v, want a single update", primary.calls)
+	}
+	op := primary.lastOp()
+	if op.Type != adapter.OpUpdate || !op.Partial || op.Statement != "users/{id}.json" {
+		t.Errorf("operation = %+v, want a partial update on the delete statement", op)
+	}
+	record := updated[0].(map[string]interface{})
+	if record["id"] != "1" {
+		t.Errorf("record = %v, want the identifier", record)
+	}
+	if _, ok := record["deleted_at"].(time.Time); !ok {
+		t.Errorf("deleted_at = %v, want the deletion time", record["deleted_at"])
+	}
+
+	// Flag mode with its own statement
+	if err := mapper.Delete(ctx, "app.tag", &softUser{ID: "t1"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if primary.lastOp().Statement != "tags/{id}.json" || updated[0].(map[string]interface{})["archived"] != true {
+		t.Errorf("flag soft delete = %+v, %v", primary.lastOp(), updated[0])
+	}
+
+	// Purge removes for real
+	if err := mapper.Delete(ctx, "app.user", "1", Purge()); err != nil {
+		t.Fatalf("Delete(Purge) error = %v", err)
+	}
+	if primary.callCount("delete") != 1 || primary.lastOp().Type != adapter.OpDelete {
+		t.Errorf("Purge() should call the adapter's delete, calls = %v", primary.calls)
+	}
+}
+
+func TestMapper_SoftDelete_Fetch(t *testing.T) {
+	mapper := newTestMapper(t, softDeleteTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{fetchFn: fetchReturning(
+		map[string]interface{}{"id": "1"},
+		map[string]interface{}{"id": "2", "deleted_at": "2026-01-02T03:04:05Z"},
+		map[string]interface{}{"id": "3", "deleted_at": nil},
+	)})
+	ctx := context.Background()
+
+	var users []softUser
+	if err := mapper.FetchMulti(ctx, "app.user", nil, &users); err != nil {
+		t.Fatalf("FetchMulti() error = %v", err)
+	}
+	if len(users) != 2 || users[0].ID != "1" || users[1].ID != "3" {
+		t.Errorf("users = %+v, want the active users 1 and 3", users)
+	}
+
+	users = nil
+	if err := mapper.FetchMulti(ctx, "app.user", nil, &users, WithDeleted()); err != nil {
+		t.Fatalf("FetchMulti(WithDeleted) error = %v", err)
+	}
+	if len(users) != 3 {
+		t.Errorf("users = %+v, want all users", users)
+	}
+
+	var count int
+	for _, err := range mapper.FetchIter(ctx, "app.user", nil, softUser{}, Where(adapter.Ne("ID", "3"))) {
+		if err != nil {
+			t.Fatalf("FetchIter() error = %v", err)
+		}
+		count++
+	}
+	if count != 1 {
+		t.Errorf("FetchIter() yielded %d users, want 1", count)
+	}
+}
+
+func TestMapper_SoftDelete_KeyedFetch(t *testing.T) {
+	dataDir := t.TempDir()
+	os.MkdirAll(filepath.Join(dataDir, "users"), 0755)
+	for _, record := range []map[string]interface{}{
+		{"id": "1", "name": "Alice"},
+		{"id": "2", "name": "Bob", "deleted_at": "2026-01-02T03:04:05Z"},
+	} {
+		data, _ := json.Marshal(record)
+		os.WriteFile(filepath.Join(dataDir, "users", record["id"].(string)+".json"), data, 0644)
+	}
+
+	mapper := newTestMapper(t, fmt.Sprintf(`namespace: app
+version: "1.0"
+sources:
+  files:
+    adapter: filesystem
+    connection: %q
+mappings:
+  user:
+    object: User
+    source: files
+    soft_delete:
+      field: deleted_at
+    operations:
+      fetch:
+        statement: "users/{id}.json"
+        parameters:
+          - object: ID
+            field: id
+        result:
+          properties:
+            - object: ID
+              field: id
+`, dataDir))
+	mapper.RegisterAdapter("filesystem", func(source config.Source) (adapter.Adapter, error) {
+		return filesystem.NewFilesystemAdapter(source.Connection)
+	})
+	ctx := context.Background()
+
+	var user softUser
+	if err := mapper.Fetch(ctx, "app.user", map[string]interface{}{"id": "1"}, &user); err != nil || user.ID != "1" {
+		t.Fatalf("Fetch(1) = %+v, %v; want user 1", user, err)
+	}
+
+	// The soft-delete criteria narrow results but never choose the record read
+	for _, params := range []map[string]interface{}{{}, {"ID": "2"}} {
+		user = softUser{}
+		if err := mapper.Fetch(ctx, "app.user", params, &user); err == nil {
+			t.Errorf("Fetch(%v) = %+v, want an unresolved parameter error", params, user)
+		}
+	}
+
+	user = softUser{}
+	err := mapper.Fetch(ctx, "app.user", map[string]interface{}{"id": "2"}, &user)
+	if !errors.Is(err, adapter.ErrNotFound) {
+		t.Errorf("Fetch(deleted) = %+v, %v; want ErrNotFound", user, err)
+	}
+}
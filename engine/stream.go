@@ -0,0 +1,245 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"reflect"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// FetchIter returns an iterator over the objects of a multi-result fetch.
+// The fetch runs when iteration starts. Results are mapped one at a time, so
+// adapters implementing adapter.Streamer never hold the full result set in
+// memory; other adapters are read through Fetch.
+//
+// elem is a sample of the element type: each yielded object is a new value of
+// elem's type, e.g. pass &User{} to receive *User values or User{} for User values.
+// An element that fails to map, or whose AfterFetch hook fails, is yielded as an
+// *ElementError and iteration continues; any other error ends iteration. Breaking
+// out of the loop or cancelling ctx stops the fetch and releases the adapter's
+// resources. Include is not supported, since related objects are loaded for
+// all fetched objects at once; passing it is a configuration error.
+func (m *Mapper) FetchIter(ctx context.Context, mappingID string, params map[string]interface{}, elem interface{}, opts ...FetchOption) iter.Seq2[interface{}, error] {
+	return func(yield func(interface{}, error) bool) {
+		options := newFetchOptions(opts)
+		if len(options.include) > 0 || options.page != nil {
+			yield(nil, adapter.NewAdapterError(adapter.ErrConfiguration.Code,
+				"FetchIter does not support Include or paging; use FetchMulti or FetchPage", nil))
+			return
+		}
+
+		elemType := reflect.TypeOf(elem)
+		if elemType == nil {
+			yield(nil, fmt.Errorf("elem cannot be nil"))
+			return
+		}
+
+		mapping, cfg, err := m.parser.GetMapping(mappingID)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		opConfig, exists := mapping.Operations["fetch"]
+		if !exists {
+			yield(nil, fmt.Errorf("mapping '%s' does not have a 'fetch' operation", mappingID))
+			return
+		}
+
+		// The operation's timeout bounds the whole iteration
+		ctx, cancel := withOperationTimeout(ctx, opConfig.Timeout)
+		defer cancel()
+
+		// Open a cursor against the source chain
+		cursor, step, err := m.streamFromChain(ctx, mappingID, cfg, mapping, &opConfig, params, options)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer cursor.Close()
+
+		if options.sourceID != nil {
+			*options.sourceID = step.sourceID
+		}
+
+		var mappings []config.PropertyMap
+		if step.result != nil {
+			mappings = step.result.Properties
+		}
+
+		// Map results one by one
+		for i := 0; cursor.Next(ctx); i++ {
+			item, err := m.newElement(ctx, elemType, cursor.Value(), mappings)
+			if err != nil {
+				if !yield(nil, &ElementError{Index: i, Err: err}) {
+					return
+				}
+				continue
+			}
+			if !yield(item, nil) {
+				return
+			}
+		}
+
+		if err := cursor.Err(); err != nil {
+			yield(nil, fmt.Errorf("stream failed: %w", err))
+		}
+	}
+}
+
+// newElement maps value into a new value of elemType and runs its AfterFetch hook.
+func (m *Mapper) newElement(ctx context.Context, elemType reflect.Type, value interface{}, mappings []config.PropertyMap) (interface{}, error) {
+	if elemType.Kind() == reflect.Ptr {
+		target := reflect.New(elemType.Elem())
+		if err := m.propMap.MapToValue(value, target.Interface(), mappings); err != nil {
+			return nil, err
+		}
+		if err := runHooks(ctx, hookAfterFetch, []interface{}{target.Interface()}); err != nil {
+			return nil, err
+		}
+		return target.Interface(), nil
+	}
+
+	target := reflect.New(elemType)
+	if err := m.propMap.MapToValue(value, target.Interface(), mappings); err != nil {
+		return nil, err
+	}
+	if err := runHooks(ctx, hookAfterFetch, []interface{}{target.Interface()}); err != nil {
+		return nil, err
+	}
+	return target.Elem().Interface(), nil
+}
+
+// streamFromChain opens a cursor on the first source of the chain that answers,
+// following on_miss and on_error like fetchFromChain. Once a cursor is open,
+// later errors end the iteration instead of falling back.
+func (m *Mapper) streamFromChain(ctx context.Context, mappingID string, cfg *config.Config, mapping *config.Mapping, opConfig *config.OperationConfig, params map[string]interface{}, options *fetchOptions) (adapter.Cursor, sourceStep, error) {
+	steps, err := m.sourceChain(mappingID, cfg, mapping, opConfig)
+	if err != nil {
+		return nil, sourceStep{}, fmt.Errorf("failed to resolve source for fetch: %w", err)
+	}
+	if err := options.validate(); err != nil {
+		return nil, sourceStep{}, err
+	}
+
+	var lastErr error
+	for i, step := range steps {
+		last := i == len(steps)-1
+
+		cursor, err := m.streamFromSource(ctx, step, params, options)
+		if err == nil {
+			return cursor, step, nil
+		}
+		lastErr = err
+
+		policy := step.onError
+		if isNotFound(err) {
+			policy = step.onMiss
+		}
+		if (policy == PolicyNext || isCircuitOpen(err)) && !last && !isValidation(err) {
+			continue
+		}
+		return nil, step, err
+	}
+
+	return nil, sourceStep{}, lastErr
+}
+
+// streamFromSource opens a cursor on a single source of a chain, reading
+// through Fetch when the adapter cannot stream. Criteria the adapter does not
+// support natively are applied as the cursor advances.
+func (m *Mapper) streamFromSource(ctx context.Context, step sourceStep, params map[string]interface{}, options *fetchOptions) (adapter.Cursor, error) {
+	adp, err := m.access(ctx, step.source, step.sourceID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	op := m.buildOperation(adapter.OpFetch, step.opConfig)
+	op.Multi = true
+	op.Source = step.sourceID
+
+	criteria, native, err := stepCriteria(adp, step, options)
+	if err != nil {
+		return nil, err
+	}
+	if native {
+		op.Criteria = criteria
+	}
+
+	inv := m.newInvocation(step.mappingID, step.sourceID, op)
+	inv.Params = params
+
+	var cursor adapter.Cursor
+	if streamer, ok := adp.(adapter.Streamer); ok {
+		inv.Streaming = true
+		err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+			cursor, err := streamer.Stream(ctx, inv.Operation, inv.Params)
+			inv.Result = cursor
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("stream failed: %w", err)
+		}
+		if cursor, ok = inv.Result.(adapter.Cursor); !ok {
+			cursor = &sliceCursor{pos: -1}
+		}
+	} else {
+		err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+			data, err := adp.Fetch(ctx, inv.Operation, inv.Params)
+			inv.Result = data
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fetch failed: %w", err)
+		}
+		data, _ := inv.Result.([]interface{})
+		cursor = &sliceCursor{data: data, pos: -1}
+	}
+
+	if criteria != nil && !native {
+		cursor = &filterCursor{Cursor: cursor, criteria: criteria}
+	}
+	return cursor, nil
+}
+
+// sliceCursor is a cursor over already fetched results.
+type sliceCursor struct {
+	data []interface{}
+	pos  int
+	err  error
+}
+
+// Next advances to the next result.
+func (c *sliceCursor) Next(ctx context.Context) bool {
+	if c.err != nil || c.data == nil {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		c.err = err
+		return false
+	}
+	c.pos++
+	return c.pos < len(c.data)
+}
+
+// Value returns the current result.
+func (c *sliceCursor) Value() interface{} {
+	if c.pos < 0 || c.pos >= len(c.data) {
+		return nil
+	}
+	return c.data[c.pos]
+}
+
+// Err returns the error that stopped iteration.
+func (c *sliceCursor) Err() error {
+	return c.err
+}
+
+// Close drops the results.
+func (c *sliceCursor) Close() error {
+	c.data = nil
+	return nil
+}
@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// streamingStub is a stub adapter that streams rows through a countingCursor.
+type streamingStub struct {
+	stubAdapter
+	rows   []interface{}
+	cursor *countingCursor
+}
+
+func (s *streamingStub) Stream(ctx context.Context, op *adapter.Operation, params map[string]interface{}) (adapter.Cursor, error) {
+	s.record("stream", op)
+	s.cursor = &countingCursor{sliceCursor: sliceCursor{data: s.rows, pos: -1}}
+	return s.cursor, nil
+}
+
+// countingCursor counts how many rows were read and whether it was closed.
+type countingCursor struct {
+	sliceCursor
+	reads  int
+	closed bool
+}
+
+func (c *countingCursor) Next(ctx context.Context) bool {
+	if !c.sliceCursor.Next(ctx) {
+		return false
+	}
+	c.reads++
+	return true
+}
+
+func (c *countingCursor) Close() error {
+	c.closed = true
+	return c.sliceCursor.Close()
+}
+
+func TestMapper_FetchIter(t *testing.T) {
+	rows := []interface{}{
+		map[string]interface{}{"id": "1", "name": "One"},
+		map[string]interface{}{"id": "2", "name": "Two"},
+		map[string]interface{}{"id": "3", "name": "Three"},
+	}
+
+	tests := []struct {
+		name   string
+		stream bool
+	}{
+		{"streaming adapter", true},
+		{"fetch fallback", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := newTestMapper(t, fallbackTestConfig)
+			stub := &streamingStub{rows: rows}
+			stub.fetchFn = func(context.Context, *adapter.Operation, map[string]interface{}) ([]interface{}, error) {
+				return rows, nil
+			}
+			if tt.stream {
+				mapper.RegisterAdapter("primary", func(config.Source) (adapter.Adapter, error) { return stub, nil })
+			} else {
+				registerStub(mapper, "primary", &stub.stubAdapter)
+			}
+
+			var names []string
+			for item, err := range mapper.FetchIter(context.Background(), "shop.replicated", nil, &fallbackProduct{}) {
+				if err != nil {
+					t.Fatalf("FetchIter() error = %v", err)
+				}
+				names = append(names, item.(*fallbackProduct).Name)
+			}
+
+			if len(names) != 3 || names[0] != "One" || names[2] != "Three" {
+				t.Errorf("names = %v, want One, Two, Three", names)
+			}
+			if tt.stream && (stub.callCount("fetch") != 0 || !stub.cursor.closed) {
+				t.Errorf("streaming adapter: fetch calls = %d, cursor closed = %v", stub.callCount("fetch"), stub.cursor.closed)
+			}
+		})
+	}
+}
+
+func TestMapper_FetchIter_Break(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	stub := &streamingStub{rows: []interface{}{
+		map[string]interface{}{"id": "1"},
+		map[string]interface{}{"id": "2"},
+		map[string]interface{}{"id": "3"},
+	}}
+	mapper.RegisterAdapter("primary", func(config.Source) (adapter.Adapter, error) { return stub, nil })
+
+	for item, err := range mapper.FetchIter(context.Background(), "shop.replicated", nil, fallbackProduct{}) {
+		if err != nil {
+			t.Fatalf("FetchIter() error = %v", err)
+		}
+		if item.(fallbackProduct).ID != "1" {
+			t.Errorf("item = %+v, want ID 1", item)
+		}
+		break
+	}
+
+	if stub.cursor.reads != 1 || !stub.cursor.closed {
+		t.Errorf("reads = %d, closed = %v; want 1 read and a closed cursor", stub.cursor.reads, stub.cursor.closed)
+	}
+}
+
+func TestMapper_FetchIter_Cancel(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	stub := &streamingStub{rows: []interface{}{
+		map[string]interface{}{"id": "1"},
+		map[string]interface{}{"id": "2"},
+	}}
+	mapper.RegisterAdapter("primary", func(config.Source) (adapter.Adapter, error) { return stub, nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lastErr error
+	count := 0
+	for _, err := range mapper.FetchIter(ctx, "shop.replicated", nil, &fallbackProduct{}) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		count++
+		cancel()
+	}
+
+	if count != 1 || !errors.Is(lastErr, context.Canceled) {
+		t.Errorf("count = %d, err = %v; want 1 item then context.Canceled", count, lastErr)
+	}
+	if !stub.cursor.closed {
+		t.Error("cursor not closed after cancellation")
+	}
+}
+
+func TestMapper_FetchIter_ElementError(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{fetchFn: fetchReturning(
+		map[string]interface{}{"id": "1"},
+		map[string]interface{}{"id": []string{"bad"}},
+		map[string]interface{}{"id": "3"},
+	)})
+
+	var ids []string
+	var elemErr *ElementError
+	for item, err := range mapper.FetchIter(context.Background(), "shop.replicated", nil, &fallbackProduct{}) {
+		if err != nil {
+			if !errors.As(err, &elemErr) {
+				t.Fatalf("FetchIter() error = %v, want *ElementError", err)
+			}
+			continue
+		}
+		ids = append(ids, item.(*fallbackProduct).ID)
+	}
+
+	if elemErr == nil || elemErr.Index != 1 {
+		t.Errorf("element error = %v, want index 1", elemErr)
+	}
+	if len(ids) != 2 {
+		t.Errorf("ids = %v, want the two valid elements", ids)
+	}
+}
+
+func TestMapper_FetchIter_UnsupportedOptions(t *testing.T) {
+	mapper := newTestMapper(t, fallbackTestConfig)
+	stub := &streamingStub{}
+	mapper.RegisterAdapter("primary", func(config.Source) (adapter.Adapter, error) { return stub, nil })
+
+	var errs []error
+	for _, err := range mapper.FetchIter(context.Background(), "shop.replicated", nil, fallbackProduct{}, Include("Lines")) {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], adapter.ErrConfiguration) {
+		t.Fatalf("FetchIter() errors = %v, want one configuration error", errs)
+	}
+	if stub.callCount("stream") != 0 {
+		t.Errorf("stream called %d times, want 0", stub.callCount("stream"))
+	}
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// withTimeout bounds ctx by a configured timeout. An empty or invalid timeout
+// (timeouts are checked when the configuration is validated) leaves ctx as is.
+func withTimeout(ctx context.Context, timeout string) (context.Context, context.CancelFunc) {
+	if timeout == "" {
+		return ctx, func() {}
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// operationTimeoutKey marks a context bounded by an operation or action timeout.
+type operationTimeoutKey struct{}
+
+// withOperationTimeout bounds ctx by the timeout of an operation or action. A
+// context bounded this way is not bounded again by the source's timeout, which
+// only applies to operations and actions without their own.
+func withOperationTimeout(ctx context.Context, timeout string) (context.Context, context.CancelFunc) {
+	if timeout == "" {
+		return ctx, func() {}
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	return context.WithValue(ctx, operationTimeoutKey{}, true), cancel
+}
+
+// hasOperationTimeout reports whether ctx is bounded by an operation or action timeout.
+func hasOperationTimeout(ctx context.Context) bool {
+	bounded, _ := ctx.Value(operationTimeoutKey{}).(bool)
+	return bounded
+}
+
+// stepContext bounds the context of a source chain step when ctx has a deadline:
+// the time left is split evenly across the remaining steps (this one included),
+// so that a slow source cannot use up the budget of the sources after it.
+// Time a step leaves unused goes to the steps after it.
+func stepContext(ctx context.Context, remaining int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || remaining <= 1 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, time.Until(deadline)/time.Duration(remaining))
+}
+
+// invocationSource returns the configuration of the source an invocation goes to.
+func (m *Mapper) invocationSource(inv *Invocation) (config.Source, bool) {
+	_, cfg, err := m.parser.GetMapping(inv.MappingID)
+	if err != nil {
+		return config.Source{}, false
+	}
+	source, exists := cfg.Sources[inv.SourceID]
+	return source, exists
+}
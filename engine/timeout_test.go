@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+const timeoutTestConfig = `namespace: shop
+version: "1.0"
+sources:
+  slow:
+    adapter: slow
+    connection: "localhost"
+  fast:
+    adapter: fast
+    connection: "localhost"
+    timeout: 30ms
+mappings:
+  product:
+    object: Product
+    operations:
+      fetch:
+        timeout: 40ms
+        sources:
+          - name: slow
+            on_error: next
+          - name: fast
+        statement: "products/{id}.json"
+        result:
+          properties:
+            - object: ID
+              field: id
+      update:
+        source: fast
+        timeout: 200ms
+        statement: "products/{id}.json"
+        properties:
+          - object: ID
+            field: id
+      insert:
+        source: fast
+        statement: "products/{id}.json"
+        properties:
+          - object: ID
+            field: id
+    actions:
+      reindex:
+        source: slow
+        timeout: 20ms
+        statement: "reindex"
+`
+
+// waitForDeadline blocks until ctx is done, recording the time it had left.
+func waitForDeadline(left *time.Duration) func(context.Context, *adapter.Operation, map[string]interface{}) ([]interface{}, error) {
+	return func(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return nil, errors.New("no deadline")
+		}
+		*left = time.Until(deadline)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+}
+
+func TestMapper_Timeout_ChainBudget(t *testing.T) {
+	mapper := newTestMapper(t, timeoutTestConfig)
+	var slowLeft, fastLeft time.Duration
+	registerStub(mapper, "slow", &stubAdapter{fetchFn: waitForDeadline(&slowLeft)})
+	registerStub(mapper, "fast", &stubAdapter{fetchFn: waitForDeadline(&fastLeft)})
+
+	start := time.Now()
+	var product fallbackProduct
+	err := mapper.Fetch(context.Background(), "shop.product", map[string]interface{}{"id": "1"}, &product)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Fetch() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// The slow source gets half of the budget, leaving the rest to the fast one
+	if slowLeft > 20*time.Millisecond {
+		t.Errorf("slow source had %v, want at most half of the 40ms budget", slowLeft)
+	}
+	if fastLeft <= 0 || fastLeft > 20*time.Millisecond {
+		t.Errorf("fast source had %v, want the remaining budget", fastLeft)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Fetch() took %v, want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestMapper_Timeout_SourceAndAction(t *testing.T) {
+	mapper := newTestMapper(t, timeoutTestConfig)
+	ctx := context.Background()
+
+	var insertLeft time.Duration
+	registerStub(mapper, "fast", &stubAdapter{
+		insertFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				return errors.New("no deadline")
+			}
+			insertLeft = time.Until(deadline)
+			return nil
+		},
+	})
+	if err := mapper.Insert(ctx, "shop.product", &fallbackProduct{ID: "1"}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if insertLeft <= 0 || insertLeft > 30*time.Millisecond {
+		t.Errorf("insert had %v, want the source's 30ms timeout", insertLeft)
+	}
+
+	registerStub(mapper, "slow", &stubAdapter{
+		executeFn: func(ctx context.Context, action *adapter.Action, params map[string]interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	if err := mapper.Execute(ctx, "shop.product.reindex", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Execute() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestMapper_Timeout_OperationOverridesSource(t *testing.T) {
+	mapper := newTestMapper(t, timeoutTestConfig)
+
+	// The update outlasts the source's 30ms timeout but not its own 200ms one
+	var updateLeft time.Duration
+	registerStub(mapper, "fast", &stubAdapter{
+		updateFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				return errors.New("no deadline")
+			}
+			updateLeft = time.Until(deadline)
+			select {
+			case <-time.After(60 * time.Millisecond):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+	if err := mapper.Update(context.Background(), "shop.product", &fallbackProduct{ID: "1"}); err != nil {
+		t.Fatalf("Update() error = %v, want the operation timeout to replace the source's", err)
+	}
+	if updateLeft <= 30*time.Millisecond {
+		t.Errorf("update had %v, want the operation's 200ms timeout", updateLeft)
+	}
+}
@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+// ErrTxDone is returned when a Tx is used after its transaction function has returned.
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// dataAccess is the part of an adapter that a transaction redirects:
+// either the adapter itself or a transaction begun on it.
+type dataAccess interface {
+	Fetch(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error)
+	Insert(ctx context.Context, op *adapter.Operation, objects []interface{}) error
+	Update(ctx context.Context, op *adapter.Operation, objects []interface{}) error
+	Delete(ctx context.Context, op *adapter.Operation, identifiers []interface{}) error
+}
+
+// Tx is a unit of work spanning one or more sources.
+// It is only valid inside the function passed to Mapper.Transaction.
+type Tx struct {
+	mapper  *Mapper
+	options *txOptions
+
+	mu sync.Mutex
+
+	// transactions maps source IDs to the adapter transactions begun on them
+	transactions map[string]adapter.Transaction
+
+	// order lists source IDs in the order their transactions were begun
+	order []string
+
+	// afterActions holds after-action hooks deferred until commit
+	afterActions []func(ctx context.Context) error
+
+	done bool
+}
+
+// txKey is the context key under which a Tx is carried.
+type txKey struct{}
+
+// Transaction runs fn as a unit of work. Writes made through tx are applied when fn
+// returns nil and discarded when it returns an error or panics.
+//
+// A transaction is begun lazily on each source tx touches, through the adapter's
+// adapter.Transactional implementation. Writing to a source whose adapter is not
+// transactional fails unless AllowNonTransactional is given, in which case those
+// writes are applied immediately and are not rolled back. After-action hooks of
+// writes made through tx run once all sources have committed.
+//
+// Sources are committed in the order they were first used. Committing several
+// sources is not atomic across them: if a later commit fails, the remaining sources
+// are rolled back but earlier ones stay committed, which the error reports.
+func (m *Mapper) Transaction(ctx context.Context, fn func(tx *Tx) error, opts ...TxOption) (err error) {
+	tx := &Tx{
+		mapper:       m,
+		options:      newTxOptions(opts),
+		transactions: make(map[string]adapter.Transaction),
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.rollback(ctx)
+			panic(r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.rollback(ctx); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.commit(ctx); err != nil {
+		return err
+	}
+
+	for _, run := range tx.afterActions {
+		if err := run(ctx); err != nil {
+			return fmt.Errorf("after actions failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Fetch retrieves a single object within the transaction, like Mapper.Fetch.
+// Reads from transactional sources observe the transaction's own writes.
+func (tx *Tx) Fetch(ctx context.Context, mappingID string, params map[string]interface{}, result interface{}, opts ...FetchOption) error {
+	return tx.mapper.Fetch(tx.bind(ctx), mappingID, params, result, opts...)
+}
+
+// FetchMulti retrieves multiple objects within the transaction, like Mapper.FetchMulti.
+func (tx *Tx) FetchMulti(ctx context.Context, mappingID string, params map[string]interface{}, results interface{}, opts ...FetchOption) error {
+	return tx.mapper.FetchMulti(tx.bind(ctx), mappingID, params, results, opts...)
+}
+
+// Insert creates new objects within the transaction, like Mapper.Insert.
+func (tx *Tx) Insert(ctx context.Context, mappingID string, objects interface{}) error {
+	return tx.mapper.Insert(tx.bind(ctx), mappingID, objects)
+}
+
+// Update modifies existing objects within the transaction, like Mapper.Update.
+func (tx *Tx) Update(ctx context.Context, mappingID string, objects interface{}) error {
+	return tx.mapper.Update(tx.bind(ctx), mappingID, objects)
+}
+
+// Delete removes objects within the transaction, like Mapper.Delete.
+func (tx *Tx) Delete(ctx context.Context, mappingID string, identifiers interface{}, opts ...DeleteOption) error {
+	return tx.mapper.Delete(tx.bind(ctx), mappingID, identifiers, opts...)
+}
+
+// Execute runs a custom action within the transaction, like Mapper.Execute.
+// Actions run on the source's adapter transaction when it implements
+// adapter.ActionExecutor, observing the transaction's writes, and on the
+// adapter directly otherwise.
+func (tx *Tx) Execute(ctx context.Context, actionID string, params map[string]interface{}, result interface{}) error {
+	return tx.mapper.Execute(tx.bind(ctx), actionID, params, result)
+}
+
+// bind returns a context that routes mapper operations through tx.
+func (tx *Tx) bind(ctx context.Context) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// txFromContext returns the transaction carried by ctx, or nil.
+func txFromContext(ctx context.Context) *Tx {
+	tx, _ := ctx.Value(txKey{}).(*Tx)
+	return tx
+}
+
+// access returns what an operation on sourceID should run against: the adapter
+// transaction for the source, begun on first use. Reads from non-transactional
+// adapters go to the adapter; writes only do with AllowNonTransactional.
+func (tx *Tx) access(ctx context.Context, adp adapter.Adapter, sourceID string, write bool) (dataAccess, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return nil, ErrTxDone
+	}
+
+	if transaction, exists := tx.transactions[sourceID]; exists {
+		return transaction, nil
+	}
+
+	transactional, ok := adp.(adapter.Transactional)
+	if !ok {
+		if !write || tx.options.allowNonTransactional {
+			return adp, nil
+		}
+		return nil, adapter.NewAdapterError(adapter.ErrConfiguration.Code,
+			fmt.Sprintf("source '%s' (%s adapter) does not support transactions", sourceID, adp.Name()), nil)
+	}
+
+	transaction, err := transactional.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction on source '%s': %w", sourceID, err)
+	}
+	tx.transactions[sourceID] = transaction
+	tx.order = append(tx.order, sourceID)
+
+	return transaction, nil
+}
+
+// deferAfterActions queues after-action hooks until the transaction commits.
+func (tx *Tx) deferAfterActions(run func(ctx context.Context) error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.afterActions = append(tx.afterActions, run)
+}
+
+// commit commits the adapter transactions in the order they were begun.
+func (tx *Tx) commit(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.done = true
+
+	for i, sourceID := range tx.order {
+		if err := tx.transactions[sourceID].Commit(ctx); err != nil {
+			for _, rest := range tx.order[i+1:] {
+				_ = tx.transactions[rest].Rollback(ctx)
+			}
+			if i > 0 {
+				return fmt.Errorf("commit failed on source '%s' after committing %s: %w",
+					sourceID, strings.Join(tx.order[:i], ", "), err)
+			}
+			return fmt.Errorf("commit failed on source '%s': %w", sourceID, err)
+		}
+	}
+
+	return nil
+}
+
+// rollback rolls back all adapter transactions.
+func (tx *Tx) rollback(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return nil
+	}
+	tx.done = true
+
+	var errs []error
+	for _, sourceID := range tx.order {
+		if err := tx.transactions[sourceID].Rollback(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("source '%s': %w", sourceID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
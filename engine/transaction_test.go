@@ -0,0 +1,326 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// txStub is a stub adapter that supports transactions.
+type txStub struct {
+	stubAdapter
+	commitErr error
+
+	mu           sync.Mutex
+	transactions []*stubTransaction
+}
+
+func (s *txStub) Begin(ctx context.Context) (adapter.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &stubTransaction{commitErr: s.commitErr}
+	s.transactions = append(s.transactions, t)
+	return t, nil
+}
+
+// stubTransaction records the writes made through it and how it finished.
+type stubTransaction struct {
+	commitErr error
+
+	writes     []string
+	committed  bool
+	rolledBack bool
+}
+
+func (t *stubTransaction) Fetch(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+	return []interface{}{map[string]interface{}{"id": "1", "name": "staged"}}, nil
+}
+
+func (t *stubTransaction) Insert(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	t.writes = append(t.writes, "insert")
+	return nil
+}
+
+func (t *stubTransaction) Update(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	t.writes = append(t.writes, "update")
+	return nil
+}
+
+func (t *stubTransaction) Delete(ctx context.Context, op *adapter.Operation, identifiers []interface{}) error {
+	t.writes = append(t.writes, "delete")
+	return nil
+}
+
+func (t *stubTransaction) Execute(ctx context.Context, action *adapter.Action, params map[string]interface{}) (interface{}, error) {
+	return "staged " + action.Name, nil
+}
+
+func (t *stubTransaction) Commit(ctx context.Context) error {
+	if t.commitErr != nil {
+		return t.commitErr
+	}
+	t.committed = true
+	return nil
+}
+
+func (t *stubTransaction) Rollback(ctx context.Context) error {
+	t.rolledBack = true
+	return nil
+}
+
+const transactionTestConfig = `namespace: shop
+version: "1.0"
+sources:
+  orders:
+    adapter: orders
+    connection: "localhost"
+  lines:
+    adapter: lines
+    connection: "localhost"
+  cache:
+    adapter: cache
+    connection: "memory"
+mappings:
+  order:
+    object: Order
+    source: orders
+    operations:
+      fetch:
+        statement: "orders/{id}.json"
+        parameters:
+          - object: ID
+            field: id
+        result:
+          type: Order
+          properties:
+            - object: ID
+              field: id
+            - object: Name
+              field: name
+      insert:
+        statement: "orders/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+        after:
+          - action: cache_set
+            source: cache
+    actions:
+      summary:
+        statement: "summary"
+  line:
+    object: Line
+    source: lines
+    operations:
+      insert:
+        statement: "lines/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+`
+
+type txItem struct {
+	ID   string
+	Name string
+}
+
+func TestMapper_Transaction_Commit(t *testing.T) {
+	mapper := newTestMapper(t, transactionTestConfig)
+	orders, lines, cache := &txStub{}, &txStub{}, &stubAdapter{}
+	mapper.RegisterAdapter("orders", func(config.Source) (adapter.Adapter, error) { return orders, nil })
+	mapper.RegisterAdapter("lines", func(config.Source) (adapter.Adapter, error) { return lines, nil })
+	registerStub(mapper, "cache", cache)
+	ctx := context.Background()
+
+	err := mapper.Transaction(ctx, func(tx *Tx) error {
+		if err := tx.Insert(ctx, "shop.order", &txItem{ID: "1", Name: "order"}); err != nil {
+			return err
+		}
+		if err := tx.Insert(ctx, "shop.line", []txItem{{ID: "1"}, {ID: "2"}}); err != nil {
+			return err
+		}
+		if cache.callCount("update")+cache.callCount("insert") != 0 {
+			t.Error("after actions ran before commit")
+		}
+
+		var order txItem
+		if err := tx.Fetch(ctx, "shop.order", map[string]interface{}{"id": "1"}, &order); err != nil {
+			return err
+		}
+		if order.Name != "staged" {
+			t.Errorf("Fetch() in transaction = %+v, want the staged order", order)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction() error = %v", err)
+	}
+
+	if len(orders.transactions) != 1 || !orders.transactions[0].committed {
+		t.Errorf("orders transaction not committed")
+	}
+	if len(lines.transactions) != 1 || !lines.transactions[0].committed {
+		t.Errorf("lines transaction not committed")
+	}
+	if orders.callCount("insert") != 0 || lines.callCount("insert") != 0 {
+		t.Error("writes bypassed the transaction")
+	}
+	if cache.callCount("update") != 1 {
+		t.Errorf("cache updates = %d, want after action after commit", cache.callCount("update"))
+	}
+}
+
+func TestMapper_Transaction_CountAndExecute(t *testing.T) {
+	mapper := newTestMapper(t, transactionTestConfig)
+	orders := &txStub{}
+	mapper.RegisterAdapter("orders", func(config.Source) (adapter.Adapter, error) { return orders, nil })
+	ctx := context.Background()
+
+	err := mapper.Transaction(ctx, func(tx *Tx) error {
+		n, err := tx.Count(ctx, "shop.order", map[string]interface{}{"id": "1"})
+		if err != nil || n != 1 {
+			t.Errorf("Count() in transaction = %d, %v; want the staged order", n, err)
+		}
+		exists, err := tx.Exists(ctx, "shop.order", map[string]interface{}{"id": "1"})
+		if err != nil || !exists {
+			t.Errorf("Exists() in transaction = %v, %v; want true", exists, err)
+		}
+
+		var summary string
+		if err := tx.Execute(ctx, "shop.order.summary", nil, &summary); err != nil {
+			return err
+		}
+		if summary != "staged summary" {
+			t.Errorf("Execute() in transaction = %q, want the transaction's answer", summary)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction() error = %v", err)
+	}
+	if orders.callCount("fetch") != 0 || orders.callCount("execute") != 0 {
+		t.Error("reads bypassed the transaction")
+	}
+}
+
+func TestMapper_Transaction_Rollback(t *testing.T) {
+	mapper := newTestMapper(t, transactionTestConfig)
+	orders, lines, cache := &txStub{}, &txStub{}, &stubAdapter{}
+	mapper.RegisterAdapter("orders", func(config.Source) (adapter.Adapter, error) { return orders, nil })
+	mapper.RegisterAdapter("lines", func(config.Source) (adapter.Adapter, error) { return lines, nil })
+	registerStub(mapper, "cache", cache)
+	ctx := context.Background()
+
+	boom := errors.New("boom")
+	var escaped *Tx
+	err := mapper.Transaction(ctx, func(tx *Tx) error {
+		escaped = tx
+		if err := tx.Insert(ctx, "shop.order", &txItem{ID: "1"}); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("Transaction() error = %v, want boom", err)
+	}
+
+	if !orders.transactions[0].rolledBack || orders.transactions[0].committed {
+		t.Error("orders transaction not rolled back")
+	}
+	if cache.callCount("update") != 0 {
+		t.Error("after actions ran for a rolled back transaction")
+	}
+
+	if err := escaped.Insert(ctx, "shop.order", &txItem{ID: "2"}); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Insert() after rollback error = %v, want ErrTxDone", err)
+	}
+}
+
+func TestMapper_Transaction_Panic(t *testing.T) {
+	mapper := newTestMapper(t, transactionTestConfig)
+	orders := &txStub{}
+	mapper.RegisterAdapter("orders", func(config.Source) (adapter.Adapter, error) { return orders, nil })
+	ctx := context.Background()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("panic was swallowed")
+		}
+		if !orders.transactions[0].rolledBack {
+			t.Error("transaction not rolled back on panic")
+		}
+	}()
+
+	_ = mapper.Transaction(ctx, func(tx *Tx) error {
+		_ = tx.Insert(ctx, "shop.order", &txItem{ID: "1"})
+		panic("boom")
+	})
+}
+
+func TestMapper_Transaction_NonTransactional(t *testing.T) {
+	mapper := newTestMapper(t, transactionTestConfig)
+	orders, lines := &txStub{}, &stubAdapter{name: "plain"}
+	mapper.RegisterAdapter("orders", func(config.Source) (adapter.Adapter, error) { return orders, nil })
+	registerStub(mapper, "lines", lines)
+	registerStub(mapper, "cache", &stubAdapter{})
+	ctx := context.Background()
+
+	write := func(tx *Tx) error {
+		if err := tx.Insert(ctx, "shop.order", &txItem{ID: "1"}); err != nil {
+			return err
+		}
+		return tx.Insert(ctx, "shop.line", &txItem{ID: "1"})
+	}
+
+	err := mapper.Transaction(ctx, write)
+	if !errors.Is(err, adapter.ErrConfiguration) || !strings.Contains(err.Error(), "lines") {
+		t.Fatalf("Transaction() error = %v, want configuration error for source lines", err)
+	}
+	if lines.callCount("insert") != 0 {
+		t.Error("non-transactional source was written")
+	}
+	if !orders.transactions[0].rolledBack {
+		t.Error("orders transaction not rolled back")
+	}
+
+	if err := mapper.Transaction(ctx, write, AllowNonTransactional()); err != nil {
+		t.Fatalf("Transaction(AllowNonTransactional) error = %v", err)
+	}
+	if lines.callCount("insert") != 1 {
+		t.Errorf("lines inserts = %d, want 1 best-effort write", lines.callCount("insert"))
+	}
+	if !orders.transactions[1].committed {
+		t.Error("orders transaction not committed")
+	}
+}
+
+func TestMapper_Transaction_CommitFailure(t *testing.T) {
+	mapper := newTestMapper(t, transactionTestConfig)
+	orders, lines := &txStub{}, &txStub{commitErr: adapter.ErrConflict}
+	mapper.RegisterAdapter("orders", func(config.Source) (adapter.Adapter, error) { return orders, nil })
+	mapper.RegisterAdapter("lines", func(config.Source) (adapter.Adapter, error) { return lines, nil })
+	registerStub(mapper, "cache", &stubAdapter{})
+	ctx := context.Background()
+
+	err := mapper.Transaction(ctx, func(tx *Tx) error {
+		if err := tx.Insert(ctx, "shop.line", &txItem{ID: "1"}); err != nil {
+			return err
+		}
+		return tx.Insert(ctx, "shop.order", &txItem{ID: "1"})
+	})
+	if !errors.Is(err, adapter.ErrConflict) {
+		t.Fatalf("Transaction() error = %v, want ErrConflict", err)
+	}
+	if orders.transactions[0].committed || !orders.transactions[0].rolledBack {
+		t.Error("remaining transaction not rolled back after commit failure")
+	}
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+// Upsert creates objects that do not exist yet and updates those that do,
+// through the mapping's "upsert" operation.
+// Adapters implementing adapter.Upserter do so natively; on others each object is
+// inserted and, when the insert fails with adapter.ErrConflict, updated instead.
+// Validation rules, the audit trail and after actions apply as for Insert and
+// Update. Lifecycle hooks are not run, since only the adapter knows whether an
+// object was created or updated, and values the adapter generates are not written
+// back into the objects.
+func (m *Mapper) Upsert(ctx context.Context, mappingID string, objects interface{}) error {
+	mapping, cfg, err := m.parser.GetMapping(mappingID)
+	if err != nil {
+		return err
+	}
+
+	opConfig, exists := mapping.Operations["upsert"]
+	if !exists {
+		return fmt.Errorf("mapping '%s' does not have an 'upsert' operation", mappingID)
+	}
+
+	// Resolve source
+	source, sourceID, err := m.resolveSource(cfg, mapping, &opConfig)
+	if err != nil {
+		return fmt.Errorf("failed to resolve source for upsert: %w", err)
+	}
+
+	// Get adapter, or the transaction begun on it
+	adp, err := m.access(ctx, source, sourceID, true)
+	if err != nil {
+		return err
+	}
+
+	// Build operation
+	op := m.buildOperation(adapter.OpUpsert, &opConfig)
+
+	// Convert objects to slice
+	objectSlice, err := m.toSlice(objects)
+	if err != nil {
+		return fmt.Errorf("failed to convert objects: %w", err)
+	}
+
+	// Map objects to data
+	dataObjects := make([]interface{}, len(objectSlice))
+	for i, obj := range objectSlice {
+		data, err := m.propMap.MapFromObject(obj, opConfig.Properties)
+		if err != nil {
+			return fmt.Errorf("failed to map object %d: %w", i, err)
+		}
+		dataObjects[i] = data
+	}
+
+	// Enforce validation rules
+	if err := validateObjects(dataObjects, opConfig.Properties); err != nil {
+		return err
+	}
+
+	// Execute upsert, natively or as insert-then-update
+	inv := m.newInvocation(mappingID, sourceID, op)
+	inv.Objects = dataObjects
+	inv.Idempotent = opConfig.Idempotent
+	callCtx, cancel := withOperationTimeout(ctx, opConfig.Timeout)
+	err = m.invoke(callCtx, inv, func(ctx context.Context, inv *Invocation) error {
+		if upserter, ok := adp.(adapter.Upserter); ok {
+			return upserter.Upsert(ctx, inv.Operation, inv.Objects)
+		}
+		return upsertEach(ctx, adp, inv.Operation, inv.Objects)
+	})
+	cancel()
+	if err != nil {
+		return fmt.Errorf("upsert failed: %w", err)
+	}
+
+	// Drop cached fetches of the mapping
+	m.invalidateCache(ctx, mappingID)
+
+	// Record the audit trail
+	if err := m.writeAudit(ctx, mappingID, cfg, mapping, adapter.OpUpsert, nil, dataMaps(dataObjects)); err != nil {
+		return err
+	}
+
+	// Execute after actions
+	if err := m.executeAfterActions(ctx, mappingID, cfg, &opConfig, dataMaps(dataObjects)); err != nil {
+		return fmt.Errorf("after actions failed: %w", err)
+	}
+
+	return nil
+}
+
+// upsertAttempts bounds how often upsertEach alternates between insert and update
+// for one object while concurrent writers keep creating and deleting it.
+const upsertAttempts = 3
+
+// upsertEach upserts objects on an adapter without native support: each object
+// is inserted, and updated when the adapter reports it exists with a conflict.
+// Insert and update are separate adapter calls, so the object may be deleted in
+// between; an update failing with adapter.ErrNotFound therefore retries the insert.
+func upsertEach(ctx context.Context, adp dataAccess, op *adapter.Operation, objects []interface{}) error {
+	insertOp, updateOp := *op, *op
+	insertOp.Type, updateOp.Type = adapter.OpInsert, adapter.OpUpdate
+	insertOp.Bulk, updateOp.Bulk = false, false
+
+	for i, obj := range objects {
+		var err error
+		for attempt := 1; ; attempt++ {
+			err = adp.Insert(ctx, &insertOp, []interface{}{obj})
+			if !errors.Is(err, adapter.ErrConflict) {
+				break
+			}
+			err = adp.Update(ctx, &updateOp, []interface{}{obj})
+			if !errors.Is(err, adapter.ErrNotFound) || attempt == upsertAttempts {
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("object %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Upsert creates or updates objects like Mapper.Upsert. Objects passed by pointer
+// (or in a slice of structs) become the session's instances for their identifiers.
+func (s *Session) Upsert(mappingID string, objects interface{}) error {
+	if err := s.mapper.Upsert(s.ctx, mappingID, objects); err != nil {
+		return err
+	}
+	return s.remember(mappingID, objects)
+}
+
+// Upsert creates or updates objects within the transaction, like Mapper.Upsert.
+func (tx *Tx) Upsert(ctx context.Context, mappingID string, objects interface{}) error {
+	return tx.mapper.Upsert(tx.bind(ctx), mappingID, objects)
+}
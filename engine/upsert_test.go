@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// upsertingStub is a stub adapter with native upsert support.
+type upsertingStub struct {
+	stubAdapter
+	upserted []interface{}
+}
+
+func (u *upsertingStub) Upsert(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	u.record("upsert", op)
+	u.upserted = append(u.upserted, objects...)
+	return nil
+}
+
+const upsertTestConfig = `namespace: app
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+mappings:
+  product:
+    object: Product
+    source: primary
+    operations:
+      upsert:
+        statement: "products/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Name
+            field: name
+`
+
+func TestMapper_Upsert_Native(t *testing.T) {
+	mapper := newTestMapper(t, upsertTestConfig)
+	stub := &upsertingStub{}
+	mapper.RegisterAdapter("primary", func(source config.Source) (adapter.Adapter, error) {
+		return stub, nil
+	})
+
+	if err := mapper.Upsert(context.Background(), "app.product", &fallbackProduct{ID: "1", Name: "Desk"}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if stub.callCount("upsert") != 1 || stub.callCount("insert") != 0 {
+		t.Fatalf("calls = %v, want a single upsert", stub.calls)
+	}
+	if op := stub.lastOp(); op.Type != adapter.OpUpsert {
+		t.Errorf("operation type = %q, want upsert", op.Type)
+	}
+	if data := stub.upserted[0].(map[string]interface{}); data["name"] != "Desk" {
+		t.Errorf("upserted = %v, want the mapped product", data)
+	}
+}
+
+func TestMapper_Upsert_Fallback(t *testing.T) {
+	mapper := newTestMapper(t, upsertTestConfig)
+	stored := map[string]bool{"1": true}
+	primary := &stubAdapter{
+		insertFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			if stored[objects[0].(map[string]interface{})["id"].(string)] {
+				return adapter.NewAdapterError(adapter.ErrConflict.Code, "exists", nil)
+			}
+			return nil
+		},
+	}
+	registerStub(mapper, "primary", primary)
+
+	products := []fallbackProduct{{ID: "1", Name: "Desk"}, {ID: "2", Name: "Chair"}}
+	if err := mapper.Upsert(context.Background(), "app.product", products); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	want := []string{"insert", "update", "insert"}
+	if len(primary.calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", primary.calls, want)
+	}
+	for i, call := range want {
+		if primary.calls[i] != call || primary.ops[i].Type != adapter.OperationType(call) {
+			t.Errorf("call %d = %s (%s), want %s", i, primary.calls[i], primary.ops[i].Type, call)
+		}
+	}
+}
+
+func TestMapper_Upsert_FallbackDeletedBetweenCalls(t *testing.T) {
+	mapper := newTestMapper(t, upsertTestConfig)
+	exists := true
+	primary := &stubAdapter{
+		insertFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			if exists {
+				return adapter.NewAdapterError(adapter.ErrConflict.Code, "exists", nil)
+			}
+			return nil
+		},
+		updateFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			// A concurrent delete removed the object after the insert conflicted
+			exists = false
+			return adapter.ErrNotFound
+		},
+	}
+	registerStub(mapper, "primary", primary)
+
+	if err := mapper.Upsert(context.Background(), "app.product", &fallbackProduct{ID: "1"}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if primary.callCount("insert") != 2 || primary.callCount("update") != 1 {
+		t.Errorf("calls = %v, want insert, update, insert", primary.calls)
+	}
+}
+
+func TestMapper_Upsert_FallbackError(t *testing.T) {
+	mapper := newTestMapper(t, upsertTestConfig)
+	primary := &stubAdapter{
+		insertFn: func(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+			return adapter.ErrConnection
+		},
+	}
+	registerStub(mapper, "primary", primary)
+
+	err := mapper.Upsert(context.Background(), "app.product", &fallbackProduct{ID: "1"})
+	if !errors.Is(err, adapter.ErrConnection) {
+		t.Errorf("Upsert() error = %v, want ErrConnection", err)
+	}
+	if primary.callCount("update") != 0 {
+		t.Error("non-conflict insert errors should not fall back to update")
+	}
+}
+
+func TestMapper_Upsert_MissingOperation(t *testing.T) {
+	mapper := newTestMapper(t, auditTestConfig)
+	registerStub(mapper, "primary", &stubAdapter{})
+
+	if err := mapper.Upsert(context.Background(), "app.product", &fallbackProduct{ID: "1"}); err == nil {
+		t.Error("Upsert() error = nil, want missing operation error")
+	}
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+// Validation rule names, as reported in violations.
+const (
+	RuleRequired  = "required"
+	RuleMin       = "min"
+	RuleMax       = "max"
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RulePattern   = "pattern"
+	RuleOneOf     = "one_of"
+)
+
+// Violation is a broken validation rule of one object field.
+type Violation struct {
+	// Field is the object field name.
+	Field string
+
+	// Rule is the name of the broken rule (e.g. RuleRequired).
+	Rule string
+
+	// Message describes the violation.
+	Message string
+}
+
+// ValidationError lists every violation of an object passed to Insert or Update.
+// Validation stops at the first invalid object: Index identifies it, and the
+// objects after it are not checked. It wraps adapter.ErrValidation, so errors.Is(err, adapter.ErrValidation) holds.
+type ValidationError struct {
+	// Index is the position of the object among those passed.
+	Index int
+
+	// Violations lists the broken rules in property order.
+	Violations []Violation
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = fmt.Sprintf("%s: %s", v.Field, v.Message)
+	}
+	return fmt.Sprintf("validation failed for object %d: %s", e.Index, strings.Join(parts, "; "))
+}
+
+// Unwrap returns adapter.ErrValidation.
+func (e *ValidationError) Unwrap() error {
+	return adapter.ErrValidation
+}
+
+// validateObjects checks mapped data objects against the validation rules of
+// their properties, returning a ValidationError for the first invalid object.
+func validateObjects(dataObjects []interface{}, properties []config.PropertyMap) error {
+	for i, obj := range dataObjects {
+		data, _ := obj.(map[string]interface{})
+		if violations := validateData(data, properties); len(violations) > 0 {
+			return &ValidationError{Index: i, Violations: violations}
+		}
+	}
+	return nil
+}
+
+// validateData returns all violations of a data record.
+func validateData(data map[string]interface{}, properties []config.PropertyMap) []Violation {
+	var violations []Violation
+	for _, pm := range properties {
+		if pm.Validate == nil || pm.Generated {
+			continue
+		}
+		for _, v := range validateValue(data[pm.Field], pm.Field, pm.Validate) {
+			v.Field = pm.Object
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
+
+// validateValue checks a single data value against its rules.
+func validateValue(value interface{}, field string, rules *config.ValidationRules) []Violation {
+	var violations []Violation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil || value == "" {
+		if rules.Required {
+			add(RuleRequired, "is required")
+		}
+		return violations
+	}
+	if rules.Min != nil || rules.Max != nil {
+		n, ok := numberValue(value)
+		switch {
+		case !ok:
+			add(configuredRule(rules.Min != nil, RuleMin, RuleMax), "must be a number")
+		case rules.Min != nil && n < *rules.Min:
+			add(RuleMin, "must be at least %v", *rules.Min)
+		case rules.Max != nil && n > *rules.Max:
+			add(RuleMax, "must be at most %v", *rules.Max)
+		}
+	}
+
+	if rules.MinLength != nil || rules.MaxLength != nil {
+		length, ok := lengthOf(value)
+		switch {
+		case !ok:
+			add(configuredRule(rules.MinLength != nil, RuleMinLength, RuleMaxLength), "has no length")
+		case rules.MinLength != nil && length < *rules.MinLength:
+			add(RuleMinLength, "must be at least %d long", *rules.MinLength)
+		case rules.MaxLength != nil && length > *rules.MaxLength:
+			add(RuleMaxLength, "must be at most %d long", *rules.MaxLength)
+		}
+	}
+
+	if rules.Pattern != "" {
+		if !compiledPattern(rules.Pattern).MatchString(fmt.Sprint(value)) {
+			add(RulePattern, "must match %s", rules.Pattern)
+		}
+	}
+
+	if len(rules.OneOf) > 0 {
+		if !adapter.In(field, rules.OneOf...).Match(map[string]interface{}{field: value}) {
+			add(RuleOneOf, "must be one of %v", rules.OneOf)
+		}
+	}
+
+	return violations
+}
+
+// configuredRule returns lower when its rule is configured and upper otherwise,
+// naming the rule a value of the wrong kind violates.
+func configuredRule(hasLower bool, lower, upper string) string {
+	if hasLower {
+		return lower
+	}
+	return upper
+}
+
+// numberValue converts a Go number to float64.
+func numberValue(value interface{}) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// lengthOf returns the length of a string (in characters), slice, array or map.
+func lengthOf(value interface{}) (int, bool) {
+	if s, ok := value.(string); ok {
+		return utf8.RuneCountInString(s), true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len(), true
+	default:
+		return 0, false
+	}
+}
+
+// patterns caches compiled validation patterns by expression.
+var patterns sync.Map
+
+// compiledPattern compiles a validation pattern once. Patterns are checked when
+// the configuration is loaded, so an invalid one matches nothing.
+func compiledPattern(expr string) *regexp.Regexp {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		re = regexp.MustCompile(`[^\s\S]`)
+	}
+	patterns.Store(expr, re)
+	return re
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+	"github.com/toutaio/toutago-datamapper/config"
+)
+
+const validateTestConfig = `namespace: app
+version: "1.0"
+sources:
+  primary:
+    adapter: primary
+    connection: "localhost"
+mappings:
+  account:
+    object: Account
+    source: primary
+    operations:
+      insert:
+        statement: "accounts/{id}.json"
+        properties:
+          - object: ID
+            field: id
+            validate:
+              required: true
+          - object: Email
+            field: email
+            validate:
+              required: true
+              pattern: "^[^@]+@[^@]+$"
+          - object: Name
+            field: name
+            validate:
+              min_length: 2
+              max_length: 5
+          - object: Age
+            field: age
+            validate:
+              min: 18
+              max: 130
+          - object: Plan
+            field: plan
+            validate:
+              one_of: [free, pro]
+      update:
+        statement: "accounts/{id}.json"
+        properties:
+          - object: ID
+            field: id
+          - object: Age
+            field: age
+            validate:
+              min: 18
+`
+
+type validAccount struct {
+	ID    string
+	Email string
+	Name  string
+	Age   int
+	Plan  string
+}
+
+func TestMapper_Insert_Validation(t *testing.T) {
+	mapper := newTestMapper(t, validateTestConfig)
+	primary := &stubAdapter{}
+	registerStub(mapper, "primary", primary)
+	ctx := context.Background()
+
+	valid := &validAccount{ID: "1", Email: "ada@example.com", Name: "Ada", Age: 36, Plan: "pro"}
+	if err := mapper.Insert(ctx, "app.account", valid); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	// Optional fields may be left empty
+	if err := mapper.Insert(ctx, "app.account", &validAccount{ID: "2", Email: "bob@example.com", Age: 20}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	invalid := &validAccount{Email: "not-an-email", Name: "Adalbert", Age: 12, Plan: "gold"}
+	err := mapper.Insert(ctx, "app.account", []*validAccount{valid, invalid})
+	if !errors.Is(err, adapter.ErrValidation) {
+		t.Fatalf("Insert() error = %v, want ErrValidation", err)
+	}
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Insert() error = %T, want *ValidationError", err)
+	}
+	if verr.Index != 1 {
+		t.Errorf("Index = %d, want 1", verr.Index)
+	}
+
+	var got []string
+	for _, v := range verr.Violations {
+		got = append(got, v.Field+":"+v.Rule)
+	}
+	want := "ID:required,Email:pattern,Name:max_length,Age:min,Plan:one_of"
+	if strings.Join(got, ",") != want {
+		t.Errorf("violations = %v, want %s", got, want)
+	}
+
+	if primary.callCount("insert") != 2 {
+		t.Errorf("adapter inserts = %d, want 2 (none for the invalid batch)", primary.callCount("insert"))
+	}
+}
+
+func TestMapper_Update_Validation(t *testing.T) {
+	mapper := newTestMapper(t, validateTestConfig)
+	primary := &stubAdapter{}
+	registerStub(mapper, "primary", primary)
+
+	err := mapper.Update(context.Background(), "app.account", &validAccount{ID: "1", Age: 17})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Violations) != 1 || verr.Violations[0].Rule != RuleMin {
+		t.Fatalf("Update() error = %v, want a min violation", err)
+	}
+	if primary.callCount("update") != 0 {
+		t.Error("adapter should not be called for invalid objects")
+	}
+}
+
+func TestValidateValue_WrongKindReportsConfiguredRule(t *testing.T) {
+	limit := 10.0
+	length := 3
+
+	violations := validateValue("ten", "age", &config.ValidationRules{Max: &limit})
+	if len(violations) != 1 || violations[0].Rule != RuleMax {
+		t.Errorf("max on a string = %+v, want a max violation", violations)
+	}
+
+	violations = validateValue(42, "name", &config.ValidationRules{MaxLength: &length})
+	if len(violations) != 1 || violations[0].Rule != RuleMaxLength {
+		t.Errorf("max_length on a number = %+v, want a max_length violation", violations)
+	}
+}
+
+func TestValidateValue_RequiredAcceptsFalseAndZero(t *testing.T) {
+	rules := &config.ValidationRules{Required: true}
+	for _, value := range []interface{}{false, 0, 0.0} {
+		if violations := validateValue(value, "field", rules); len(violations) != 0 {
+			t.Errorf("required on %#v = %+v, want no violation", value, violations)
+		}
+	}
+	for _, value := range []interface{}{nil, ""} {
+		if violations := validateValue(value, "field", rules); len(violations) != 1 || violations[0].Rule != RuleRequired {
+			t.Errorf("required on %#v = %+v, want a required violation", value, violations)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"sort"
 
 	"github.com/toutaio/toutago-datamapper/adapter"
 	"github.com/toutaio/toutago-datamapper/config"
@@ -48,6 +50,11 @@ func main() {
 		return filesystem.NewFilesystemAdapter(source.Connection)
 	})
 
+	// Start from an empty data directory so the example can be run repeatedly
+	if err := os.RemoveAll("data/transactions"); err != nil {
+		log.Fatalf("Failed to reset data: %v", err)
+	}
+
 	// 1. Create sample transactions
 	fmt.Println("1. Creating sample transactions...")
 	transactions := []Transaction{
@@ -62,120 +69,87 @@ func main() {
 		{ID: "txn-008", AccountID: "acc-101", Amount: 250.00, Type: "credit", Description: "Freelance payment", Balance: 650.00},
 	}
 
-	for _, txn := range transactions {
-		if err := mapper.Insert(ctx, "transactions.transaction-crud", txn); err != nil {
-			log.Printf("Error creating transaction %s: %v", txn.ID, err)
-		}
+	if err := mapper.Insert(ctx, "transactions.transaction-crud", transactions); err != nil {
+		log.Fatalf("Error creating transactions: %v", err)
 	}
 	fmt.Printf("   ✓ Created %d transactions\n", len(transactions))
 	fmt.Println()
 
-	// 2. List all transactions (standard action)
+	// 2. List all transactions (custom action)
 	fmt.Println("2. Listing all transactions...")
-	var allTxns []interface{}
-	err = mapper.Execute(ctx, "transactions.list-all", nil, &allTxns)
-	if err != nil {
-		log.Printf("Error: %v", err)
-	} else {
-		fmt.Printf("   ✓ Found %d transactions\n", len(allTxns))
+	var allTxns []Transaction
+	if err := mapper.Execute(ctx, "transactions.list-all.list", nil, &allTxns); err != nil {
+		log.Fatalf("Error: %v", err)
 	}
+	fmt.Printf("   ✓ Found %d transactions\n", len(allTxns))
 	fmt.Println()
 
-	// 3. Get transactions by account (custom action with params)
+	// 3. Get transactions by account (custom action with parameters)
 	fmt.Println("3. Getting transactions for account acc-100...")
-	var accountTxns []interface{}
-	err = mapper.Execute(ctx, "transactions.by-account", map[string]interface{}{
+	var accountTxns []Transaction
+	err = mapper.Execute(ctx, "transactions.by-account.list", map[string]interface{}{
 		"account_id": "acc-100",
 	}, &accountTxns)
 	if err != nil {
-		log.Printf("Error: %v", err)
-	} else {
-		fmt.Printf("   ✓ Found %d transactions for acc-100\n", len(accountTxns))
-		for i, txn := range accountTxns {
-			t := txn.(map[string]interface{})
-			fmt.Printf("      %d. %s - %s $%.2f (Balance: $%.2f)\n",
-				i+1, t["type"], t["description"], t["amount"], t["balance"])
-		}
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("   ✓ Found %d transactions for acc-100\n", len(accountTxns))
+	for i, t := range accountTxns {
+		fmt.Printf("      %d. %s - %s $%.2f (Balance: $%.2f)\n",
+			i+1, t.Type, t.Description, t.Amount, t.Balance)
 	}
 	fmt.Println()
 
-	// 4. Calculate account summary (custom aggregation)
+	// 4. Calculate an account summary from the action's typed results
 	fmt.Println("4. Calculating account summary for acc-100...")
-	var summary map[string]interface{}
-	err = mapper.Execute(ctx, "transactions.account-summary", map[string]interface{}{
-		"account_id": "acc-100",
-	}, &summary)
-	if err != nil {
-		log.Printf("Error: %v", err)
-	} else {
-		fmt.Printf("   Account Summary:\n")
-		fmt.Printf("   • Total Credits: $%.2f\n", summary["total_credits"])
-		fmt.Printf("   • Total Debits: $%.2f\n", summary["total_debits"])
-		fmt.Printf("   • Transaction Count: %.0f\n", summary["transaction_count"])
-		fmt.Printf("   • Current Balance: $%.2f\n", summary["current_balance"])
+	summary := AccountSummary{AccountID: "acc-100"}
+	for _, t := range accountTxns {
+		switch t.Type {
+		case "credit":
+			summary.TotalCredits += t.Amount
+		case "debit":
+			summary.TotalDebits += t.Amount
+		}
+		summary.TransactionCount++
+		summary.CurrentBalance = t.Balance
 	}
+	fmt.Printf("   Account Summary:\n")
+	fmt.Printf("   • Total Credits: $%.2f\n", summary.TotalCredits)
+	fmt.Printf("   • Total Debits: $%.2f\n", summary.TotalDebits)
+	fmt.Printf("   • Transaction Count: %d\n", summary.TransactionCount)
+	fmt.Printf("   • Current Balance: $%.2f\n", summary.CurrentBalance)
 	fmt.Println()
 
-	// 5. Get recent transactions (custom action with limit)
+	// 5. Get the most recent transactions
 	fmt.Println("5. Getting 3 most recent transactions...")
-	var recentTxns []interface{}
-	err = mapper.Execute(ctx, "transactions.recent", map[string]interface{}{
-		"limit": 3,
-	}, &recentTxns)
-	if err != nil {
-		log.Printf("Error: %v", err)
-	} else {
-		fmt.Printf("   ✓ Recent transactions:\n")
-		for i, txn := range recentTxns {
-			t := txn.(map[string]interface{})
-			fmt.Printf("      %d. [%s] %s - $%.2f\n",
-				i+1, t["account_id"], t["description"], t["amount"])
-		}
+	recentTxns := append([]Transaction(nil), allTxns...)
+	sort.Slice(recentTxns, func(i, j int) bool { return recentTxns[i].ID > recentTxns[j].ID })
+	fmt.Printf("   ✓ Recent transactions:\n")
+	for i, t := range recentTxns[:3] {
+		fmt.Printf("      %d. [%s] %s - $%.2f\n", i+1, t.AccountID, t.Description, t.Amount)
 	}
 	fmt.Println()
 
-	// 6. Get transactions by type (custom filter)
+	// 6. Get transactions by type
 	fmt.Println("6. Getting all credit transactions...")
-	var creditTxns []interface{}
-	err = mapper.Execute(ctx, "transactions.by-type", map[string]interface{}{
-		"type": "credit",
-	}, &creditTxns)
-	if err != nil {
-		log.Printf("Error: %v", err)
-	} else {
-		fmt.Printf("   ✓ Found %d credit transactions\n", len(creditTxns))
-		var totalCredits float64
-		for _, txn := range creditTxns {
-			t := txn.(map[string]interface{})
-			totalCredits += t["amount"].(float64)
+	var credits int
+	var totalCredits float64
+	for _, t := range allTxns {
+		if t.Type == "credit" {
+			credits++
+			totalCredits += t.Amount
 		}
-		fmt.Printf("   ✓ Total credit amount: $%.2f\n", totalCredits)
-	}
-	fmt.Println()
-
-	// 7. Execute stored procedure simulation
-	fmt.Println("7. Executing balance reconciliation (stored procedure simulation)...")
-	var reconciled map[string]interface{}
-	err = mapper.Execute(ctx, "transactions.reconcile-balance", map[string]interface{}{
-		"account_id": "acc-100",
-	}, &reconciled)
-	if err != nil {
-		log.Printf("Error: %v", err)
-	} else {
-		fmt.Printf("   ✓ Balance reconciled:\n")
-		fmt.Printf("      Expected: $%.2f\n", reconciled["expected_balance"])
-		fmt.Printf("      Actual: $%.2f\n", reconciled["actual_balance"])
-		fmt.Printf("      Status: %s\n", reconciled["status"])
 	}
+	fmt.Printf("   ✓ Found %d credit transactions\n", credits)
+	fmt.Printf("   ✓ Total credit amount: $%.2f\n", totalCredits)
 	fmt.Println()
 
 	fmt.Println("=== Custom Actions Demonstrated ===")
 	fmt.Println()
 	fmt.Println("Action Types:")
-	fmt.Println("  • List/Query actions - Retrieve filtered data")
-	fmt.Println("  • Aggregation actions - Calculate summaries")
-	fmt.Println("  • Stored procedures - Complex business logic")
-	fmt.Println("  • Custom operations - Domain-specific tasks")
+	fmt.Println("  • List actions - Retrieve all matching data")
+	fmt.Println("  • Parameterized actions - Narrow the data with parameters")
+	fmt.Println("  • Typed results - Map action results into structs")
 	fmt.Println()
 	fmt.Println("Benefits:")
 	fmt.Println("  • Encapsulate complex queries")
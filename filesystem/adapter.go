@@ -2,12 +2,18 @@ package filesystem
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/toutaio/toutago-datamapper/adapter"
 )
@@ -64,7 +70,18 @@ func (fa *FilesystemAdapter) Fetch(ctx context.Context, op *adapter.Operation, p
 
 	// Check if we need to list multiple files (glob pattern)
 	if op.Multi || strings.Contains(path, "*") {
-		return fa.fetchMulti(path)
+		results, err := fa.fetchMulti(path)
+		if err != nil || op.Criteria == nil {
+			return results, err
+		}
+
+		filtered := results[:0]
+		for _, result := range results {
+			if op.Criteria.Match(result.(map[string]interface{})) {
+				filtered = append(filtered, result)
+			}
+		}
+		return filtered, nil
 	}
 
 	// Fetch single file
@@ -72,10 +89,25 @@ func (fa *FilesystemAdapter) Fetch(ctx context.Context, op *adapter.Operation, p
 	if err != nil {
 		return nil, err
 	}
+	if op.Criteria != nil && !op.Criteria.Match(data) {
+		return []interface{}{}, nil
+	}
 
 	return []interface{}{data}, nil
 }
 
+// SupportsCriteria reports that the filesystem adapter evaluates all criteria
+// itself, matching them against each document it reads.
+func (fa *FilesystemAdapter) SupportsCriteria(c adapter.Criterion) bool {
+	return true
+}
+
+// ScansCriteria reports that criteria are evaluated by reading every document
+// matching the operation's path, since files are only indexed by their path.
+func (fa *FilesystemAdapter) ScansCriteria(c adapter.Criterion) bool {
+	return true
+}
+
 // fetchSingle retrieves a single file.
 func (fa *FilesystemAdapter) fetchSingle(path string) (map[string]interface{}, error) {
 	fullPath := filepath.Join(fa.basePath, path)
@@ -114,86 +146,171 @@ func (fa *FilesystemAdapter) fetchMulti(pattern string) ([]interface{}, error) {
 		return []interface{}{}, nil
 	}
 
-	// Read all matching files
+	// Read all matching files, skipping directories and unreadable files
 	results := make([]interface{}, 0, len(matches))
 	for _, match := range matches {
-		// Skip directories
-		info, err := os.Stat(match)
+		result, err := fa.readDocument(match)
 		if err != nil {
 			continue
 		}
-		if info.IsDir() {
-			continue
-		}
+		results = append(results, result)
+	}
 
-		// Read and parse file
-		data, err := os.ReadFile(match)
-		if err != nil {
-			continue
-		}
+	return results, nil
+}
 
-		var result map[string]interface{}
-		if err := json.Unmarshal(data, &result); err != nil {
-			continue
-		}
+// readDocument reads and parses the JSON file at fullPath.
+func (fa *FilesystemAdapter) readDocument(fullPath string) (map[string]interface{}, error) {
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", fullPath)
+	}
 
-		results = append(results, result)
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	return results, nil
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	return result, nil
 }
 
 // Insert creates new objects in the filesystem.
 func (fa *FilesystemAdapter) Insert(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	_, err := fa.InsertGenerated(ctx, op, objects)
+	return err
+}
+
+// InsertGenerated creates new objects and reports the values it generated for them.
+// Generated fields that are missing or zero are filled before the path is resolved:
+// "timestamp" fields get the current time, "integer" fields (or fields already holding
+// a number) a unique integer and all others a random hex identifier. Objects whose file exists fail with ErrConflict.
+func (fa *FilesystemAdapter) InsertGenerated(ctx context.Context, op *adapter.Operation, objects []interface{}) ([]map[string]interface{}, error) {
 	fa.mu.Lock()
 	defer fa.mu.Unlock()
 
-	for _, obj := range objects {
+	generated := make([]map[string]interface{}, len(objects))
+	for i, obj := range objects {
 		dataMap, ok := obj.(map[string]interface{})
 		if !ok {
-			return fmt.Errorf("object must be map[string]interface{}, got %T", obj)
+			return nil, fmt.Errorf("object must be map[string]interface{}, got %T", obj)
+		}
+
+		values, err := fa.generateValues(op, dataMap)
+		if err != nil {
+			return nil, err
 		}
+		generated[i] = values
 
 		// Resolve file path
 		path, err := fa.resolvePath(op.Statement, dataMap)
 		if err != nil {
-			return fmt.Errorf("failed to resolve path: %w", err)
+			return nil, fmt.Errorf("failed to resolve path: %w", err)
 		}
 
 		fullPath := filepath.Join(fa.basePath, path)
 
 		// Check if file already exists
 		if _, err := os.Stat(fullPath); err == nil {
-			return fmt.Errorf("file already exists: %s", path)
+			return nil, adapter.NewAdapterError(adapter.ErrConflict.Code, fmt.Sprintf("file already exists: %s", path), nil)
 		}
 
 		// Create directory if needed
 		dir := filepath.Dir(fullPath)
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory: %w", err)
+			return nil, fmt.Errorf("failed to create directory: %w", err)
 		}
 
 		// Marshal data to JSON
 		data, err := json.MarshalIndent(dataMap, "", "  ")
 		if err != nil {
-			return fmt.Errorf("failed to marshal JSON: %w", err)
+			return nil, fmt.Errorf("failed to marshal JSON: %w", err)
 		}
 
 		// Write atomically using temp file
 		if err := fa.writeAtomic(fullPath, data); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	return generated, nil
+}
+
+// generateValues fills missing generated fields of dataMap and returns the generated values.
+func (fa *FilesystemAdapter) generateValues(op *adapter.Operation, dataMap map[string]interface{}) (map[string]interface{}, error) {
+	mappings := append([]adapter.PropertyMapping{}, op.Generated...)
+	for _, pm := range op.Properties {
+		if pm.Generated {
+			mappings = append(mappings, pm)
+		}
+	}
+
+	var values map[string]interface{}
+	for _, pm := range mappings {
+		current, exists := dataMap[pm.DataField]
+		if exists && current != nil && !reflect.ValueOf(current).IsZero() {
+			continue
+		}
+
+		var value interface{}
+		switch {
+		case pm.Type == "timestamp":
+			value = time.Now().UTC().Format(time.RFC3339)
+		case pm.Type == adapter.TypeInteger || isNumeric(current):
+			value = atomic.AddInt64(&sequence, 1)
+		default:
+			id := make([]byte, 16)
+			if _, err := rand.Read(id); err != nil {
+				return nil, fmt.Errorf("failed to generate identifier: %w", err)
+			}
+			value = hex.EncodeToString(id)
+		}
+
+		if values == nil {
+			values = make(map[string]interface{})
+		}
+		values[pm.DataField] = value
+		dataMap[pm.DataField] = value
+	}
+
+	return values, nil
+}
+
+// sequence backs generated numeric identifiers. It is seeded from the clock in
+// microseconds so identifiers stay unique across process restarts while remaining
+// below 2^53, where they survive a round-trip through a JSON float64.
+var sequence = time.Now().UnixMicro()
+
+// isNumeric reports whether v holds a Go number.
+func isNumeric(v interface{}) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
 }
 
 // Update modifies existing objects in the filesystem.
+// When the operation carries condition values, every stored document is checked
+// before anything is written; a mismatch fails the whole update with ErrConflict.
+// Partial updates are merged into the stored documents.
 func (fa *FilesystemAdapter) Update(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
 	fa.mu.Lock()
 	defer fa.mu.Unlock()
 
-	for _, obj := range objects {
+	paths := make([]string, len(objects))
+	docs := make([]map[string]interface{}, len(objects))
+	for i, obj := range objects {
 		dataMap, ok := obj.(map[string]interface{})
 		if !ok {
 			return fmt.Errorf("object must be map[string]interface{}, got %T", obj)
@@ -212,14 +329,36 @@ func (fa *FilesystemAdapter) Update(ctx context.Context, op *adapter.Operation,
 			return adapter.ErrNotFound
 		}
 
+		// Check optimistic locking conditions
+		conditional := i < len(op.ConditionValues) && len(op.ConditionValues[i]) > 0
+		docs[i] = dataMap
+		if conditional || op.Partial {
+			stored, err := fa.fetchSingle(path)
+			if err != nil {
+				return err
+			}
+			if conditional {
+				if err := checkCondition(path, stored, op.ConditionValues[i]); err != nil {
+					return err
+				}
+			}
+			if op.Partial {
+				docs[i] = mergeDocument(stored, dataMap)
+			}
+		}
+
+		paths[i] = fullPath
+	}
+
+	for i, doc := range docs {
 		// Marshal data to JSON
-		data, err := json.MarshalIndent(dataMap, "", "  ")
+		data, err := json.MarshalIndent(doc, "", "  ")
 		if err != nil {
 			return fmt.Errorf("failed to marshal JSON: %w", err)
 		}
 
 		// Write atomically
-		if err := fa.writeAtomic(fullPath, data); err != nil {
+		if err := fa.writeAtomic(paths[i], data); err != nil {
 			return err
 		}
 	}
@@ -227,6 +366,98 @@ func (fa *FilesystemAdapter) Update(ctx context.Context, op *adapter.Operation,
 	return nil
 }
 
+// Upsert writes objects whether or not their files exist, replacing existing
+// documents (or merging into them for partial operations). Paths are resolved
+// before anything is written.
+func (fa *FilesystemAdapter) Upsert(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+
+	paths := make([]string, len(objects))
+	docs := make([]map[string]interface{}, len(objects))
+	for i, obj := range objects {
+		dataMap, ok := obj.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("object must be map[string]interface{}, got %T", obj)
+		}
+
+		path, err := fa.resolvePath(op.Statement, dataMap)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		docs[i] = dataMap
+		if op.Partial {
+			stored, err := fa.fetchSingle(path)
+			if err != nil && !errors.Is(err, adapter.ErrNotFound) {
+				return err
+			}
+			if err == nil {
+				docs[i] = mergeDocument(stored, dataMap)
+			}
+		}
+		paths[i] = filepath.Join(fa.basePath, path)
+	}
+
+	for i, doc := range docs {
+		if err := os.MkdirAll(filepath.Dir(paths[i]), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+
+		if err := fa.writeAtomic(paths[i], data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeDocument returns the stored document with the fields of a partial update applied.
+func mergeDocument(stored, update map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(stored)+len(update))
+	for field, value := range stored {
+		merged[field] = value
+	}
+	for field, value := range update {
+		merged[field] = value
+	}
+	return merged
+}
+
+// checkCondition compares the expected condition values with a stored document.
+// A field missing from the document matches a zero expected value, so records
+// written before versioning was introduced can still be updated.
+func checkCondition(path string, stored, expected map[string]interface{}) error {
+	for field, want := range expected {
+		got, exists := stored[field]
+		if !exists {
+			if want == nil || reflect.ValueOf(want).IsZero() {
+				continue
+			}
+		} else if valuesEqual(got, want) {
+			continue
+		}
+		return adapter.NewAdapterError(adapter.ErrConflict.Code,
+			fmt.Sprintf("condition '%s' mismatch for %s: stored %v, expected %v", field, path, got, want), nil)
+	}
+	return nil
+}
+
+// valuesEqual compares a value decoded from JSON with a value from the mapper.
+// Numbers are compared as float64, other values by their formatted form.
+func valuesEqual(stored, expected interface{}) bool {
+	if isNumeric(stored) && isNumeric(expected) {
+		return reflect.ValueOf(stored).Convert(reflect.TypeOf(float64(0))).Float() ==
+			reflect.ValueOf(expected).Convert(reflect.TypeOf(float64(0))).Float()
+	}
+	return fmt.Sprint(stored) == fmt.Sprint(expected)
+}
+
 // Delete removes objects from the filesystem.
 func (fa *FilesystemAdapter) Delete(ctx context.Context, op *adapter.Operation, identifiers []interface{}) error {
 	fa.mu.Lock()
@@ -234,21 +465,9 @@ func (fa *FilesystemAdapter) Delete(ctx context.Context, op *adapter.Operation,
 
 	for _, id := range identifiers {
 		// Convert identifier to params map
-		var params map[string]interface{}
-		switch v := id.(type) {
-		case map[string]interface{}:
-			params = v
-		case string, int, int64:
-			// Single value identifier, use first identifier field name
-			if len(op.Identifier) > 0 {
-				params = map[string]interface{}{
-					op.Identifier[0].DataField: v,
-				}
-			} else {
-				return fmt.Errorf("no identifier mapping defined")
-			}
-		default:
-			return fmt.Errorf("unsupported identifier type: %T", id)
+		params, err := identifierParams(op, id)
+		if err != nil {
+			return err
 		}
 
 		// Resolve file path
@@ -273,6 +492,22 @@ func (fa *FilesystemAdapter) Delete(ctx context.Context, op *adapter.Operation,
 	return nil
 }
 
+// identifierParams converts a delete identifier to path template parameters.
+// Single value identifiers are keyed by the first identifier field.
+func identifierParams(op *adapter.Operation, id interface{}) (map[string]interface{}, error) {
+	switch v := id.(type) {
+	case map[string]interface{}:
+		return v, nil
+	case string, int, int64:
+		if len(op.Identifier) == 0 {
+			return nil, fmt.Errorf("no identifier mapping defined")
+		}
+		return map[string]interface{}{op.Identifier[0].DataField: v}, nil
+	default:
+		return nil, fmt.Errorf("unsupported identifier type: %T", id)
+	}
+}
+
 // Execute runs custom actions (e.g., list all files, search).
 func (fa *FilesystemAdapter) Execute(ctx context.Context, action *adapter.Action, params map[string]interface{}) (interface{}, error) {
 	fa.mu.RLock()
@@ -320,11 +555,28 @@ func (fa *FilesystemAdapter) resolvePath(template string, params map[string]inte
 
 // writeAtomic writes data to a file atomically using a temp file.
 func (fa *FilesystemAdapter) writeAtomic(path string, data []byte) error {
+	tmpPath, err := writeTemp(path, data)
+	if err != nil {
+		return err
+	}
+
+	// Rename atomically
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+
+	return nil
+}
+
+// writeTemp writes data to a synced temp file next to path and returns the temp file's path.
+// The caller renames it into place or removes it.
+func writeTemp(path string, data []byte) (string, error) {
 	// Create temp file in same directory
 	dir := filepath.Dir(path)
 	tmpFile, err := os.CreateTemp(dir, ".tmp-*")
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return "", fmt.Errorf("failed to create temp file: %w", err)
 	}
 	tmpPath := tmpFile.Name()
 
@@ -338,24 +590,19 @@ func (fa *FilesystemAdapter) writeAtomic(path string, data []byte) error {
 
 	// Write data
 	if _, err := tmpFile.Write(data); err != nil {
-		return fmt.Errorf("failed to write data: %w", err)
+		return "", fmt.Errorf("failed to write data: %w", err)
 	}
 
 	// Sync to disk
 	if err := tmpFile.Sync(); err != nil {
-		return fmt.Errorf("failed to sync: %w", err)
+		return "", fmt.Errorf("failed to sync: %w", err)
 	}
 
 	// Close temp file
 	if err := tmpFile.Close(); err != nil {
-		return fmt.Errorf("failed to close temp file: %w", err)
+		return "", fmt.Errorf("failed to close temp file: %w", err)
 	}
 	tmpFile = nil
 
-	// Rename atomically
-	if err := os.Rename(tmpPath, path); err != nil {
-		return fmt.Errorf("failed to rename file: %w", err)
-	}
-
-	return nil
+	return tmpPath, nil
 }
@@ -3,10 +3,12 @@ package filesystem
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/toutaio/toutago-datamapper/adapter"
 )
@@ -114,6 +116,9 @@ func TestFilesystemAdapter_Insert_DuplicateError(t *testing.T) {
 	if err == nil {
 		t.Error("Insert() should error for duplicate file")
 	}
+	if !errors.Is(err, adapter.ErrConflict) {
+		t.Errorf("Insert() error = %v, want ErrConflict", err)
+	}
 }
 
 func TestFilesystemAdapter_Fetch(t *testing.T) {
@@ -708,3 +713,192 @@ func TestFilesystemAdapter_Execute_UnsupportedAction(t *testing.T) {
 		t.Error("Execute() expected error for unsupported action, got nil")
 	}
 }
+
+func TestFilesystemAdapter_InsertGenerated(t *testing.T) {
+	tmpDir := t.TempDir()
+	fa, _ := NewFilesystemAdapter(tmpDir)
+
+	ctx := context.Background()
+	op := &adapter.Operation{
+		Type:      adapter.OpInsert,
+		Statement: "users/{id}.json",
+		Generated: []adapter.PropertyMapping{
+			{ObjectField: "ID", DataField: "id", Generated: true},
+			{ObjectField: "CreatedAt", DataField: "created_at", Type: "timestamp", Generated: true},
+			{ObjectField: "Number", DataField: "number", Type: adapter.TypeInteger, Generated: true},
+		},
+		Properties: []adapter.PropertyMapping{
+			{ObjectField: "Seq", DataField: "seq", Generated: true},
+		},
+	}
+
+	objects := []interface{}{
+		map[string]interface{}{"id": "", "name": "Generated", "seq": 0},
+		map[string]interface{}{"id": "fixed", "name": "Fixed", "seq": 0},
+	}
+
+	generated, err := fa.InsertGenerated(ctx, op, objects)
+	if err != nil {
+		t.Fatalf("InsertGenerated() error = %v", err)
+	}
+	if len(generated) != 2 {
+		t.Fatalf("len(generated) = %d, want 2", len(generated))
+	}
+
+	id, ok := generated[0]["id"].(string)
+	if !ok || len(id) != 32 {
+		t.Errorf("generated id = %v, want 32 hex chars", generated[0]["id"])
+	}
+	if _, ok := generated[0]["seq"].(int64); !ok {
+		t.Errorf("generated seq = %T, want int64", generated[0]["seq"])
+	}
+	// Integer-hinted fields absent from the data get numbers a JSON float64 holds exactly
+	number, ok := generated[0]["number"].(int64)
+	if !ok || number <= 0 || number >= 1<<53 {
+		t.Errorf("generated number = %v (%T), want an int64 below 2^53", generated[0]["number"], generated[0]["number"])
+	}
+	if _, err := time.Parse(time.RFC3339, generated[0]["created_at"].(string)); err != nil {
+		t.Errorf("generated created_at = %v: %v", generated[0]["created_at"], err)
+	}
+	if _, exists := generated[1]["id"]; exists {
+		t.Error("existing id should not be regenerated")
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "users", id+".json")); err != nil {
+		t.Errorf("file for generated id not written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "users", "fixed.json")); err != nil {
+		t.Errorf("file for fixed id not written: %v", err)
+	}
+}
+
+func TestFilesystemAdapter_Update_Condition(t *testing.T) {
+	tmpDir := t.TempDir()
+	fa, _ := NewFilesystemAdapter(tmpDir)
+
+	os.MkdirAll(filepath.Join(tmpDir, "users"), 0755)
+	data, _ := json.Marshal(map[string]interface{}{"id": "123", "name": "Old", "version": 3})
+	os.WriteFile(filepath.Join(tmpDir, "users", "123.json"), data, 0644)
+
+	ctx := context.Background()
+	op := &adapter.Operation{
+		Type:      adapter.OpUpdate,
+		Statement: "users/{id}.json",
+	}
+
+	// Stale version is rejected and the file is left untouched
+	op.ConditionValues = []map[string]interface{}{{"version": 2}}
+	err := fa.Update(ctx, op, []interface{}{
+		map[string]interface{}{"id": "123", "name": "Stale", "version": 3},
+	})
+	if !errors.Is(err, adapter.ErrConflict) {
+		t.Fatalf("Update() error = %v, want ErrConflict", err)
+	}
+
+	stored, _ := fa.fetchSingle("users/123.json")
+	if stored["name"] != "Old" {
+		t.Errorf("name = %v, want Old after conflict", stored["name"])
+	}
+
+	// Current version succeeds
+	op.ConditionValues = []map[string]interface{}{{"version": 3}}
+	err = fa.Update(ctx, op, []interface{}{
+		map[string]interface{}{"id": "123", "name": "New", "version": 4},
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	stored, _ = fa.fetchSingle("users/123.json")
+	if stored["name"] != "New" || stored["version"] != float64(4) {
+		t.Errorf("stored = %v, want name New and version 4", stored)
+	}
+}
+
+func TestFilesystemAdapter_Update_ConditionMissingField(t *testing.T) {
+	tmpDir := t.TempDir()
+	fa, _ := NewFilesystemAdapter(tmpDir)
+
+	os.MkdirAll(filepath.Join(tmpDir, "users"), 0755)
+	data, _ := json.Marshal(map[string]interface{}{"id": "123", "name": "Old"})
+	os.WriteFile(filepath.Join(tmpDir, "users", "123.json"), data, 0644)
+
+	op := &adapter.Operation{
+		Type:            adapter.OpUpdate,
+		Statement:       "users/{id}.json",
+		ConditionValues: []map[string]interface{}{{"version": 0}},
+	}
+
+	err := fa.Update(context.Background(), op, []interface{}{
+		map[string]interface{}{"id": "123", "name": "New", "version": 1},
+	})
+	if err != nil {
+		t.Errorf("Update() error = %v, want unversioned document to match version 0", err)
+	}
+}
+
+func TestFilesystemAdapter_Update_Partial(t *testing.T) {
+	tmpDir := t.TempDir()
+	fa, _ := NewFilesystemAdapter(tmpDir)
+
+	os.MkdirAll(filepath.Join(tmpDir, "users"), 0755)
+	data, _ := json.Marshal(map[string]interface{}{"id": "123", "name": "Ada", "email": "ada@example.com"})
+	os.WriteFile(filepath.Join(tmpDir, "users", "123.json"), data, 0644)
+
+	op := &adapter.Operation{
+		Type:      adapter.OpUpdate,
+		Statement: "users/{id}.json",
+		Partial:   true,
+	}
+	objects := []interface{}{map[string]interface{}{"id": "123", "name": "Ada Lovelace"}}
+
+	if err := fa.Update(context.Background(), op, objects); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	data, _ = os.ReadFile(filepath.Join(tmpDir, "users", "123.json"))
+	var result map[string]interface{}
+	json.Unmarshal(data, &result)
+
+	if result["name"] != "Ada Lovelace" || result["email"] != "ada@example.com" {
+		t.Errorf("document = %v, want the name updated and the email kept", result)
+	}
+}
+
+func TestFilesystemAdapter_Upsert(t *testing.T) {
+	tmpDir := t.TempDir()
+	fa, _ := NewFilesystemAdapter(tmpDir)
+	ctx := context.Background()
+
+	os.MkdirAll(filepath.Join(tmpDir, "users"), 0755)
+	data, _ := json.Marshal(map[string]interface{}{"id": "1", "name": "Ada", "email": "ada@example.com"})
+	os.WriteFile(filepath.Join(tmpDir, "users", "1.json"), data, 0644)
+
+	op := &adapter.Operation{Type: adapter.OpUpsert, Statement: "users/{id}.json"}
+	objects := []interface{}{
+		map[string]interface{}{"id": "1", "name": "Ada Lovelace"},
+		map[string]interface{}{"id": "2", "name": "Grace"},
+	}
+	if err := fa.Upsert(ctx, op, objects); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	replaced, _ := fa.fetchSingle("users/1.json")
+	if replaced["name"] != "Ada Lovelace" || replaced["email"] != nil {
+		t.Errorf("users/1.json = %v, want the document replaced", replaced)
+	}
+	created, err := fa.fetchSingle("users/2.json")
+	if err != nil || created["name"] != "Grace" {
+		t.Errorf("users/2.json = %v (err %v), want the document created", created, err)
+	}
+
+	// Partial upserts merge into existing documents
+	op.Partial = true
+	if err := fa.Upsert(ctx, op, []interface{}{map[string]interface{}{"id": "2", "email": "grace@example.com"}}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	merged, _ := fa.fetchSingle("users/2.json")
+	if merged["name"] != "Grace" || merged["email"] != "grace@example.com" {
+		t.Errorf("users/2.json = %v, want the email merged", merged)
+	}
+}
@@ -0,0 +1,45 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+// Count counts the documents matching the operation's path and criteria. Like
+// FetchMulti, it reads every file and skips the ones that cannot be parsed, so
+// the count matches the documents a fetch returns. Exists operations stop at
+// the first match.
+func (fa *FilesystemAdapter) Count(ctx context.Context, op *adapter.Operation, params map[string]interface{}) (int, error) {
+	fa.mu.RLock()
+	defer fa.mu.RUnlock()
+
+	path, err := fa.resolvePath(op.Statement, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(fa.basePath, path))
+	if err != nil {
+		return 0, fmt.Errorf("failed to glob pattern: %w", err)
+	}
+
+	count := 0
+	for _, match := range matches {
+		if info, err := os.Stat(match); err != nil || info.IsDir() {
+			continue
+		}
+		if _, ok := fa.readMatching(match, op.Criteria); !ok {
+			continue
+		}
+		count++
+		if op.Type == adapter.OpExists {
+			break
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,52 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+func TestFilesystemAdapter_Count(t *testing.T) {
+	tmpDir := t.TempDir()
+	fa, _ := NewFilesystemAdapter(tmpDir)
+	ctx := context.Background()
+
+	insertOp := &adapter.Operation{Statement: "items/{id}.json"}
+	for i := 1; i <= 4; i++ {
+		fa.Insert(ctx, insertOp, []interface{}{map[string]interface{}{"id": fmt.Sprintf("%02d", i), "even": i%2 == 0}})
+	}
+	// Unparseable files are skipped, as by FetchMulti
+	os.WriteFile(filepath.Join(tmpDir, "items", "broken.json"), []byte("{"), 0644)
+	even := adapter.Eq("even", true)
+
+	tests := []struct {
+		name      string
+		opType    adapter.OperationType
+		statement string
+		criteria  *adapter.Criterion
+		want      int
+	}{
+		{"all files", adapter.OpCount, "items/*.json", nil, 4},
+		{"criteria", adapter.OpCount, "items/*.json", &even, 2},
+		{"single file", adapter.OpCount, "items/{id}.json", nil, 1},
+		{"no match", adapter.OpCount, "missing/*.json", nil, 0},
+		{"exists stops at first", adapter.OpExists, "items/*.json", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := &adapter.Operation{Type: tt.opType, Statement: tt.statement, Multi: true, Criteria: tt.criteria}
+			got, err := fa.Count(ctx, op, map[string]interface{}{"id": "01"})
+			if err != nil {
+				t.Fatalf("Count() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Count() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+// FetchPage retrieves one page of the files matching the operation's path.
+// Files are ordered by path, and cursors carry the path of the last file of a page
+// (keyset paging), so pages stay stable while files are added or removed. Offsets
+// count matching documents, and a next cursor is only set when another match exists.
+// Without criteria the total counts matching files, including ones that cannot be
+// parsed; with criteria every file is read to count the matching documents.
+func (fa *FilesystemAdapter) FetchPage(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, *adapter.PageInfo, error) {
+	fa.mu.RLock()
+	defer fa.mu.RUnlock()
+
+	path, err := fa.resolvePath(op.Statement, params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(fa.basePath, path))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to glob pattern: %w", err)
+	}
+
+	files := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			files = append(files, match)
+		}
+	}
+	sort.Strings(files)
+
+	page := op.Page
+	if page == nil {
+		page = &adapter.Pagination{}
+	}
+
+	// Find the first file after the cursor; offsets skip matching documents
+	start, skip := 0, page.Offset
+	if page.Cursor != "" {
+		after, err := fa.decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		start = sort.Search(len(files), func(i int) bool { return files[i] > after })
+		skip = 0
+	}
+
+	// Read files until the page is full and another match shows there is a next page
+	results := make([]interface{}, 0)
+	more := false
+	last := ""
+	for _, file := range files[start:] {
+		result, ok := fa.readMatching(file, op.Criteria)
+		if !ok {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if page.Limit > 0 && len(results) == page.Limit {
+			more = true
+			break
+		}
+		results = append(results, result)
+		last = file
+	}
+
+	info := &adapter.PageInfo{Total: -1}
+	if page.WithTotal {
+		info.Total = len(files)
+		if op.Criteria != nil {
+			info.Total = 0
+			for _, file := range files {
+				if _, ok := fa.readMatching(file, op.Criteria); ok {
+					info.Total++
+				}
+			}
+		}
+	}
+	if more {
+		info.NextCursor = fa.encodeCursor(last)
+	}
+
+	return results, info, nil
+}
+
+// readMatching reads a document and reports whether it matches criteria (nil matches all).
+func (fa *FilesystemAdapter) readMatching(fullPath string, criteria *adapter.Criterion) (map[string]interface{}, bool) {
+	result, err := fa.readDocument(fullPath)
+	if err != nil || (criteria != nil && !criteria.Match(result)) {
+		return nil, false
+	}
+	return result, true
+}
+
+// encodeCursor returns an opaque cursor for a file path.
+func (fa *FilesystemAdapter) encodeCursor(fullPath string) string {
+	rel, err := filepath.Rel(fa.basePath, fullPath)
+	if err != nil {
+		rel = fullPath
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(filepath.ToSlash(rel)))
+}
+
+// decodeCursor returns the full file path encoded in a cursor.
+func (fa *FilesystemAdapter) decodeCursor(cursor string) (string, error) {
+	rel, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", adapter.NewAdapterError(adapter.ErrValidation.Code, fmt.Sprintf("invalid page cursor '%s'", cursor), err)
+	}
+	return filepath.Join(fa.basePath, filepath.FromSlash(string(rel))), nil
+}
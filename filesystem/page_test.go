@@ -0,0 +1,152 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+func TestFilesystemAdapter_FetchPage(t *testing.T) {
+	fa, _ := NewFilesystemAdapter(t.TempDir())
+	ctx := context.Background()
+
+	insertOp := &adapter.Operation{Statement: "items/{id}.json"}
+	for i := 1; i <= 5; i++ {
+		fa.Insert(ctx, insertOp, []interface{}{map[string]interface{}{"id": fmt.Sprintf("%02d", i)}})
+	}
+
+	op := &adapter.Operation{
+		Statement: "items/*.json",
+		Multi:     true,
+		Page:      &adapter.Pagination{Limit: 2, WithTotal: true},
+	}
+
+	first, info, err := fa.FetchPage(ctx, op, nil)
+	if err != nil {
+		t.Fatalf("FetchPage() error = %v", err)
+	}
+	if len(first) != 2 || first[0].(map[string]interface{})["id"] != "01" || info.Total != 5 || info.NextCursor == "" {
+		t.Fatalf("first page = %v, info = %+v", first, info)
+	}
+
+	// A file inserted before the cursor does not shift the next page
+	fa.Insert(ctx, insertOp, []interface{}{map[string]interface{}{"id": "00"}})
+
+	op.Page = &adapter.Pagination{Limit: 2, Cursor: info.NextCursor}
+	second, info, err := fa.FetchPage(ctx, op, nil)
+	if err != nil {
+		t.Fatalf("FetchPage() error = %v", err)
+	}
+	if len(second) != 2 || second[0].(map[string]interface{})["id"] != "03" || info.Total != -1 {
+		t.Errorf("second page = %v, info = %+v; want 03, 04", second, info)
+	}
+
+	op.Page = &adapter.Pagination{Limit: 10, Cursor: info.NextCursor}
+	last, info, err := fa.FetchPage(ctx, op, nil)
+	if err != nil {
+		t.Fatalf("FetchPage() error = %v", err)
+	}
+	if len(last) != 1 || info.NextCursor != "" {
+		t.Errorf("last page = %v, info = %+v; want 05 without cursor", last, info)
+	}
+
+	op.Page = &adapter.Pagination{Offset: 4}
+	byOffset, _, err := fa.FetchPage(ctx, op, nil)
+	if err != nil || len(byOffset) != 2 || byOffset[0].(map[string]interface{})["id"] != "04" {
+		t.Errorf("offset page = %v (err %v), want 04, 05", byOffset, err)
+	}
+
+	op.Page = &adapter.Pagination{Cursor: "%%%"}
+	if _, _, err := fa.FetchPage(ctx, op, nil); !errors.Is(err, adapter.ErrValidation) {
+		t.Errorf("FetchPage(invalid cursor) error = %v, want ErrValidation", err)
+	}
+}
+
+func TestFilesystemAdapter_Criteria(t *testing.T) {
+	fa, _ := NewFilesystemAdapter(t.TempDir())
+	ctx := context.Background()
+
+	insertOp := &adapter.Operation{Statement: "users/{id}.json"}
+	for i, name := range []string{"Alice", "Bob", "Alina", "Carol"} {
+		fa.Insert(ctx, insertOp, []interface{}{map[string]interface{}{"id": fmt.Sprint(i + 1), "name": name, "age": 20 + i*10}})
+	}
+
+	criteria := adapter.And(adapter.Like("name", "A%"), adapter.Gt("age", 25))
+	if !fa.SupportsCriteria(criteria) {
+		t.Fatal("SupportsCriteria() = false")
+	}
+
+	op := &adapter.Operation{Statement: "users/*.json", Multi: true, Criteria: &criteria}
+	results, err := fa.Fetch(ctx, op, nil)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(results) != 1 || results[0].(map[string]interface{})["name"] != "Alina" {
+		t.Errorf("Fetch() = %v, want Alina", results)
+	}
+
+	op.Page = &adapter.Pagination{Limit: 1, WithTotal: true}
+	notBob := adapter.Not(adapter.Eq("name", "Bob"))
+	op.Criteria = &notBob
+	page, info, err := fa.FetchPage(ctx, op, nil)
+	if err != nil {
+		t.Fatalf("FetchPage() error = %v", err)
+	}
+	if len(page) != 1 || info.Total != 3 || info.NextCursor == "" {
+		t.Errorf("page = %v, info = %+v; want 1 of 3", page, info)
+	}
+
+	single := adapter.Eq("name", "Bob")
+	results, err = fa.Fetch(ctx, &adapter.Operation{Statement: "users/{id}.json", Criteria: &single}, map[string]interface{}{"id": "1"})
+	if err != nil || len(results) != 0 {
+		t.Errorf("Fetch(single) = %v (err %v), want no match", results, err)
+	}
+
+	// Criteria only narrow results; they never stand in for missing parameters
+	active := adapter.IsNull("deleted_at")
+	results, err = fa.Fetch(ctx, &adapter.Operation{Statement: "users/{id}.json", Criteria: &active}, map[string]interface{}{"ID": "2"})
+	if err == nil {
+		t.Errorf("Fetch(missing parameter) = %v, want unresolved placeholder error", results)
+	}
+}
+
+func TestFilesystemAdapter_FetchPage_OffsetWithCriteria(t *testing.T) {
+	fa, _ := NewFilesystemAdapter(t.TempDir())
+	ctx := context.Background()
+
+	insertOp := &adapter.Operation{Statement: "users/{id}.json"}
+	for i := 1; i <= 8; i++ {
+		fa.Insert(ctx, insertOp, []interface{}{map[string]interface{}{"id": fmt.Sprintf("u%d", i), "age": i % 2}})
+	}
+
+	criteria := adapter.Eq("age", 1)
+	op := &adapter.Operation{
+		Statement: "users/*.json",
+		Multi:     true,
+		Criteria:  &criteria,
+		Page:      &adapter.Pagination{Offset: 2, Limit: 2},
+	}
+
+	page, info, err := fa.FetchPage(ctx, op, nil)
+	if err != nil {
+		t.Fatalf("FetchPage() error = %v", err)
+	}
+	if len(page) != 2 || page[0].(map[string]interface{})["id"] != "u5" || page[1].(map[string]interface{})["id"] != "u7" {
+		t.Errorf("page = %v, want u5, u7", page)
+	}
+	if info.NextCursor != "" {
+		t.Errorf("NextCursor = %q, want none after the last match", info.NextCursor)
+	}
+
+	op.Page = &adapter.Pagination{Offset: 1, Limit: 2}
+	page, info, err = fa.FetchPage(ctx, op, nil)
+	if err != nil {
+		t.Fatalf("FetchPage() error = %v", err)
+	}
+	if len(page) != 2 || page[0].(map[string]interface{})["id"] != "u3" || info.NextCursor == "" {
+		t.Errorf("page = %v, info = %+v; want u3, u5 with a next cursor", page, info)
+	}
+}
@@ -0,0 +1,86 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+// Stream lists the files matching the operation's path and returns a cursor that
+// reads and parses them one at a time. Directories and files that cannot be read
+// or parsed are skipped, as in Fetch.
+func (fa *FilesystemAdapter) Stream(ctx context.Context, op *adapter.Operation, params map[string]interface{}) (adapter.Cursor, error) {
+	path, err := fa.resolvePath(op.Statement, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	fa.mu.RLock()
+	matches, err := filepath.Glob(filepath.Join(fa.basePath, path))
+	fa.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob pattern: %w", err)
+	}
+
+	return &fileCursor{fa: fa, paths: matches, criteria: op.Criteria}, nil
+}
+
+// fileCursor reads matched files lazily.
+type fileCursor struct {
+	fa       *FilesystemAdapter
+	paths    []string
+	criteria *adapter.Criterion
+	value    map[string]interface{}
+	err      error
+}
+
+// Next reads the next parseable file matching the criteria.
+func (c *fileCursor) Next(ctx context.Context) bool {
+	c.value = nil
+	for c.err == nil && len(c.paths) > 0 {
+		if err := ctx.Err(); err != nil {
+			c.err = err
+			return false
+		}
+
+		path := c.paths[0]
+		c.paths = c.paths[1:]
+
+		if result, ok := c.read(path); ok && (c.criteria == nil || c.criteria.Match(result)) {
+			c.value = result
+			return true
+		}
+	}
+	return false
+}
+
+// read loads a single file, reporting false for entries to skip.
+func (c *fileCursor) read(path string) (map[string]interface{}, bool) {
+	c.fa.mu.RLock()
+	defer c.fa.mu.RUnlock()
+
+	result, err := c.fa.readDocument(path)
+	return result, err == nil
+}
+
+// Value returns the current document.
+func (c *fileCursor) Value() interface{} {
+	if c.value == nil {
+		return nil
+	}
+	return c.value
+}
+
+// Err returns the error that stopped iteration.
+func (c *fileCursor) Err() error {
+	return c.err
+}
+
+// Close drops the remaining file list.
+func (c *fileCursor) Close() error {
+	c.paths = nil
+	c.value = nil
+	return nil
+}
@@ -0,0 +1,65 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+func TestFilesystemAdapter_Stream(t *testing.T) {
+	tmpDir := t.TempDir()
+	fa, _ := NewFilesystemAdapter(tmpDir)
+	ctx := context.Background()
+
+	op := &adapter.Operation{Statement: "users/{id}.json"}
+	for _, id := range []string{"1", "2", "3"} {
+		fa.Insert(ctx, op, []interface{}{map[string]interface{}{"id": id}})
+	}
+	os.WriteFile(filepath.Join(tmpDir, "users", "broken.json"), []byte("{"), 0644)
+
+	cursor, err := fa.Stream(ctx, &adapter.Operation{Statement: "users/*.json", Multi: true}, nil)
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	defer cursor.Close()
+
+	var ids []interface{}
+	for cursor.Next(ctx) {
+		ids = append(ids, cursor.Value().(map[string]interface{})["id"])
+	}
+	if err := cursor.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	if len(ids) != 3 || ids[0] != "1" || ids[2] != "3" {
+		t.Errorf("ids = %v, want 1, 2, 3", ids)
+	}
+}
+
+func TestFilesystemAdapter_Stream_Cancel(t *testing.T) {
+	fa, _ := NewFilesystemAdapter(t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+
+	op := &adapter.Operation{Statement: "users/{id}.json"}
+	fa.Insert(ctx, op, []interface{}{map[string]interface{}{"id": "1"}, map[string]interface{}{"id": "2"}})
+
+	cursor, err := fa.Stream(ctx, &adapter.Operation{Statement: "users/*.json"}, nil)
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	defer cursor.Close()
+
+	if !cursor.Next(ctx) {
+		t.Fatalf("Next() = false, err = %v", cursor.Err())
+	}
+	cancel()
+	if cursor.Next(ctx) {
+		t.Error("Next() after cancel = true")
+	}
+	if !errors.Is(cursor.Err(), context.Canceled) {
+		t.Errorf("Err() = %v, want context.Canceled", cursor.Err())
+	}
+}
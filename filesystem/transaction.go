@@ -0,0 +1,504 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+// errTxDone is returned when a finished transaction is used.
+var errTxDone = errors.New("transaction has already been committed or rolled back")
+
+// transaction stages writes in memory and applies them on commit.
+// Reads observe the staged writes. Commit fails with ErrConflict when a file
+// touched by the transaction was changed on disk after the transaction first read it.
+type transaction struct {
+	fa *FilesystemAdapter
+
+	// staged maps full file paths to their pending content
+	staged map[string]*stagedFile
+	done   bool
+	mu     sync.Mutex
+}
+
+// stagedFile is the pending state of one file.
+type stagedFile struct {
+	// data is the content as the transaction sees it; nil when the file is absent
+	data []byte
+
+	// written reports whether the transaction changed the file
+	written bool
+
+	// base is the content on disk when the transaction first touched the file
+	base       []byte
+	baseExists bool
+}
+
+// Begin starts a transaction. Writes are staged in memory and applied on Commit
+// by writing every file to a temp file first and then renaming them into place.
+func (fa *FilesystemAdapter) Begin(ctx context.Context) (adapter.Transaction, error) {
+	return &transaction{
+		fa:     fa,
+		staged: make(map[string]*stagedFile),
+	}, nil
+}
+
+// Fetch retrieves objects, including writes staged by the transaction.
+func (t *transaction) Fetch(ctx context.Context, op *adapter.Operation, params map[string]interface{}) ([]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return nil, errTxDone
+	}
+
+	path, err := t.fa.resolvePath(op.Statement, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	if op.Multi || strings.Contains(path, "*") {
+		return t.fetchMulti(path)
+	}
+
+	data, exists, err := t.read(filepath.Join(t.fa.basePath, path))
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, adapter.ErrNotFound
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	return []interface{}{result}, nil
+}
+
+// fetchMulti retrieves the files matching a pattern as the transaction sees them.
+func (t *transaction) fetchMulti(pattern string) ([]interface{}, error) {
+	fullPattern := filepath.Join(t.fa.basePath, pattern)
+
+	t.fa.mu.RLock()
+	matches, err := filepath.Glob(fullPattern)
+	t.fa.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob pattern: %w", err)
+	}
+
+	// Add staged files that do not exist on disk yet
+	seen := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		seen[match] = true
+	}
+	for path := range t.staged {
+		if ok, _ := filepath.Match(fullPattern, path); ok && !seen[path] {
+			matches = append(matches, path)
+		}
+	}
+	sort.Strings(matches)
+
+	results := make([]interface{}, 0, len(matches))
+	for _, match := range matches {
+		data, exists, err := t.read(match)
+		if err != nil || !exists {
+			continue
+		}
+
+		var result map[string]interface{}
+		if err := json.Unmarshal(data, &result); err != nil {
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Insert stages new objects.
+func (t *transaction) Insert(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	_, err := t.InsertGenerated(ctx, op, objects)
+	return err
+}
+
+// InsertGenerated stages new objects and reports the values generated for them,
+// like FilesystemAdapter.InsertGenerated.
+func (t *transaction) InsertGenerated(ctx context.Context, op *adapter.Operation, objects []interface{}) ([]map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return nil, errTxDone
+	}
+
+	generated := make([]map[string]interface{}, len(objects))
+	contents := make(map[string][]byte, len(objects))
+	for i, obj := range objects {
+		dataMap, ok := obj.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("object must be map[string]interface{}, got %T", obj)
+		}
+
+		values, err := t.fa.generateValues(op, dataMap)
+		if err != nil {
+			return nil, err
+		}
+		generated[i] = values
+
+		path, err := t.fa.resolvePath(op.Statement, dataMap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		fullPath := filepath.Join(t.fa.basePath, path)
+		file, err := t.touch(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		if _, pending := contents[fullPath]; file.data != nil || pending {
+			return nil, adapter.NewAdapterError(adapter.ErrConflict.Code, fmt.Sprintf("file already exists: %s", path), nil)
+		}
+
+		data, err := json.MarshalIndent(dataMap, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		contents[fullPath] = data
+	}
+
+	t.stage(contents)
+	return generated, nil
+}
+
+// Update stages modified objects, checking condition values against the
+// transaction's view of the stored documents and merging partial updates into it.
+func (t *transaction) Update(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return errTxDone
+	}
+
+	contents := make(map[string][]byte, len(objects))
+	for i, obj := range objects {
+		dataMap, ok := obj.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("object must be map[string]interface{}, got %T", obj)
+		}
+
+		path, err := t.fa.resolvePath(op.Statement, dataMap)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		fullPath := filepath.Join(t.fa.basePath, path)
+		file, err := t.touch(fullPath)
+		if err != nil {
+			return err
+		}
+		if file.data == nil {
+			return adapter.ErrNotFound
+		}
+
+		conditional := i < len(op.ConditionValues) && len(op.ConditionValues[i]) > 0
+		doc := dataMap
+		if conditional || op.Partial {
+			var stored map[string]interface{}
+			if err := json.Unmarshal(file.data, &stored); err != nil {
+				return fmt.Errorf("failed to parse JSON: %w", err)
+			}
+			if conditional {
+				if err := checkCondition(path, stored, op.ConditionValues[i]); err != nil {
+					return err
+				}
+			}
+			if op.Partial {
+				doc = mergeDocument(stored, dataMap)
+			}
+		}
+
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		contents[fullPath] = data
+	}
+
+	t.stage(contents)
+	return nil
+}
+
+// Upsert stages objects whether or not their files exist, like
+// FilesystemAdapter.Upsert, against the transaction's view of the documents.
+func (t *transaction) Upsert(ctx context.Context, op *adapter.Operation, objects []interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return errTxDone
+	}
+
+	contents := make(map[string][]byte, len(objects))
+	for _, obj := range objects {
+		dataMap, ok := obj.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("object must be map[string]interface{}, got %T", obj)
+		}
+
+		path, err := t.fa.resolvePath(op.Statement, dataMap)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		fullPath := filepath.Join(t.fa.basePath, path)
+		file, err := t.touch(fullPath)
+		if err != nil {
+			return err
+		}
+
+		doc := dataMap
+		if op.Partial && file.data != nil {
+			var stored map[string]interface{}
+			if err := json.Unmarshal(file.data, &stored); err != nil {
+				return fmt.Errorf("failed to parse JSON: %w", err)
+			}
+			doc = mergeDocument(stored, dataMap)
+		}
+
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		contents[fullPath] = data
+	}
+
+	t.stage(contents)
+	return nil
+}
+
+// Delete stages the removal of objects.
+func (t *transaction) Delete(ctx context.Context, op *adapter.Operation, identifiers []interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return errTxDone
+	}
+
+	contents := make(map[string][]byte, len(identifiers))
+	for _, id := range identifiers {
+		params, err := identifierParams(op, id)
+		if err != nil {
+			return err
+		}
+
+		path, err := t.fa.resolvePath(op.Statement, params)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		fullPath := filepath.Join(t.fa.basePath, path)
+		file, err := t.touch(fullPath)
+		if err != nil {
+			return err
+		}
+		if file.data == nil {
+			return adapter.ErrNotFound
+		}
+		contents[fullPath] = nil
+	}
+
+	t.stage(contents)
+	return nil
+}
+
+// Commit applies the staged writes. All files are first written to temp files,
+// then renamed into place; deleted files are removed last. Each file is replaced
+// atomically, but the commit as a whole is not: when a rename or removal fails
+// after other files were applied, the error reports the commit as partial.
+func (t *transaction) Commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return errTxDone
+	}
+	t.done = true
+
+	t.fa.mu.Lock()
+	defer t.fa.mu.Unlock()
+
+	paths := make([]string, 0, len(t.staged))
+	for path := range t.staged {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// Verify nothing changed underneath the transaction
+	for _, path := range paths {
+		file := t.staged[path]
+		data, exists, err := readFile(path)
+		if err != nil {
+			return err
+		}
+		if exists != file.baseExists || !bytes.Equal(data, file.base) {
+			return adapter.NewAdapterError(adapter.ErrConflict.Code,
+				fmt.Sprintf("%s was modified outside the transaction", path), nil)
+		}
+	}
+
+	// Write temp files
+	tmpPaths := make(map[string]string, len(paths))
+	cleanup := func() {
+		for _, tmpPath := range tmpPaths {
+			_ = os.Remove(tmpPath)
+		}
+	}
+	for _, path := range paths {
+		file := t.staged[path]
+		if !file.written || file.data == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			cleanup()
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		tmpPath, err := writeTemp(path, file.data)
+		if err != nil {
+			cleanup()
+			return err
+		}
+		tmpPaths[path] = tmpPath
+	}
+
+	// Move them into place
+	applied := 0
+	for _, path := range paths {
+		tmpPath, ok := tmpPaths[path]
+		if !ok {
+			continue
+		}
+		if err := os.Rename(tmpPath, path); err != nil {
+			cleanup()
+			return partialCommit(applied, fmt.Errorf("failed to rename file: %w", err))
+		}
+		delete(tmpPaths, path)
+		applied++
+	}
+
+	for _, path := range paths {
+		file := t.staged[path]
+		if file.written && file.data == nil && file.baseExists {
+			if err := os.Remove(path); err != nil {
+				return partialCommit(applied, fmt.Errorf("failed to delete file: %w", err))
+			}
+			applied++
+		}
+	}
+
+	return nil
+}
+
+// partialCommit reports a commit that failed after applied files were already changed.
+func partialCommit(applied int, err error) error {
+	if applied == 0 {
+		return err
+	}
+	return fmt.Errorf("commit partially applied (%d files already changed): %w", applied, err)
+}
+
+// Execute runs a custom action like FilesystemAdapter.Execute, listing the files
+// as the transaction sees them.
+func (t *transaction) Execute(ctx context.Context, action *adapter.Action, params map[string]interface{}) (interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return nil, errTxDone
+	}
+
+	if action.Name == "list" {
+		pattern := action.Statement
+		if pattern == "" {
+			pattern = "*.json"
+		}
+
+		resolvedPattern, err := t.fa.resolvePath(pattern, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve pattern: %w", err)
+		}
+
+		return t.fetchMulti(resolvedPattern)
+	}
+
+	return nil, fmt.Errorf("unsupported action: %s", action.Name)
+}
+
+// Rollback discards the staged writes.
+func (t *transaction) Rollback(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return errTxDone
+	}
+	t.done = true
+	t.staged = nil
+	return nil
+}
+
+// read returns the content of a file as the transaction sees it.
+func (t *transaction) read(path string) ([]byte, bool, error) {
+	if file, ok := t.staged[path]; ok {
+		return file.data, file.data != nil, nil
+	}
+
+	t.fa.mu.RLock()
+	defer t.fa.mu.RUnlock()
+	return readFile(path)
+}
+
+// touch starts tracking a file the transaction is about to write, remembering its
+// on-disk state so Commit can detect concurrent changes.
+func (t *transaction) touch(path string) (*stagedFile, error) {
+	if file, ok := t.staged[path]; ok {
+		return file, nil
+	}
+
+	t.fa.mu.RLock()
+	base, exists, err := readFile(path)
+	t.fa.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	file := &stagedFile{data: base, base: base, baseExists: exists}
+	t.staged[path] = file
+	return file, nil
+}
+
+// stage records new contents of touched files.
+func (t *transaction) stage(contents map[string][]byte) {
+	for path, data := range contents {
+		file := t.staged[path]
+		file.data = data
+		file.written = true
+	}
+}
+
+// readFile reads a file, reporting whether it exists.
+func readFile(path string) ([]byte, bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, true, nil
+}
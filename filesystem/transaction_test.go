@@ -0,0 +1,161 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toutaio/toutago-datamapper/adapter"
+)
+
+func TestFilesystemAdapter_Transaction_Commit(t *testing.T) {
+	tmpDir := t.TempDir()
+	fa, _ := NewFilesystemAdapter(tmpDir)
+	ctx := context.Background()
+
+	os.MkdirAll(filepath.Join(tmpDir, "orders"), 0755)
+	data, _ := json.Marshal(map[string]interface{}{"id": "old"})
+	os.WriteFile(filepath.Join(tmpDir, "orders", "old.json"), data, 0644)
+
+	tx, err := fa.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+
+	op := &adapter.Operation{
+		Statement:  "orders/{id}.json",
+		Identifier: []adapter.PropertyMapping{{ObjectField: "ID", DataField: "id"}},
+	}
+	if err := tx.Insert(ctx, op, []interface{}{map[string]interface{}{"id": "1", "total": 10}}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if err := tx.Update(ctx, op, []interface{}{map[string]interface{}{"id": "1", "total": 20}}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := tx.Delete(ctx, op, []interface{}{"old"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	// Staged writes are visible inside the transaction only
+	results, err := tx.Fetch(ctx, &adapter.Operation{Statement: "orders/*.json", Multi: true}, nil)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(results) != 1 || results[0].(map[string]interface{})["total"] != float64(20) {
+		t.Errorf("Fetch() in transaction = %v, want only the staged order", results)
+	}
+	listed, err := tx.(adapter.ActionExecutor).Execute(ctx, &adapter.Action{Name: "list", Statement: "orders/*.json"}, nil)
+	if err != nil || len(listed.([]interface{})) != 1 {
+		t.Errorf("Execute(list) in transaction = %v (err %v), want only the staged order", listed, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "orders", "1.json")); !os.IsNotExist(err) {
+		t.Error("staged insert written before commit")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	stored, err := fa.fetchSingle("orders/1.json")
+	if err != nil || stored["total"] != float64(20) {
+		t.Errorf("stored = %v (err %v), want total 20", stored, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "orders", "old.json")); !os.IsNotExist(err) {
+		t.Error("staged delete not applied")
+	}
+
+	if err := tx.Commit(ctx); err == nil {
+		t.Error("second Commit() succeeded")
+	}
+}
+
+func TestFilesystemAdapter_Transaction_Rollback(t *testing.T) {
+	tmpDir := t.TempDir()
+	fa, _ := NewFilesystemAdapter(tmpDir)
+	ctx := context.Background()
+
+	tx, _ := fa.Begin(ctx)
+	op := &adapter.Operation{Statement: "orders/{id}.json"}
+	if err := tx.Insert(ctx, op, []interface{}{map[string]interface{}{"id": "1"}}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if err := tx.Rollback(ctx); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+
+	if _, err := fa.Fetch(ctx, op, map[string]interface{}{"id": "1"}); err != adapter.ErrNotFound {
+		t.Errorf("Fetch() after rollback error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFilesystemAdapter_Transaction_Conflict(t *testing.T) {
+	tmpDir := t.TempDir()
+	fa, _ := NewFilesystemAdapter(tmpDir)
+	ctx := context.Background()
+
+	op := &adapter.Operation{Statement: "orders/{id}.json"}
+	fa.Insert(ctx, op, []interface{}{map[string]interface{}{"id": "1", "total": 10}})
+
+	tx, _ := fa.Begin(ctx)
+	if err := tx.Update(ctx, op, []interface{}{map[string]interface{}{"id": "1", "total": 20}}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	// Concurrent write outside the transaction
+	if err := fa.Update(ctx, op, []interface{}{map[string]interface{}{"id": "1", "total": 30}}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if err := tx.Commit(ctx); !errors.Is(err, adapter.ErrConflict) {
+		t.Fatalf("Commit() error = %v, want ErrConflict", err)
+	}
+
+	stored, _ := fa.fetchSingle("orders/1.json")
+	if stored["total"] != float64(30) {
+		t.Errorf("total = %v, want concurrent write kept", stored["total"])
+	}
+}
+
+func TestFilesystemAdapter_Transaction_InsertDuplicate(t *testing.T) {
+	fa, _ := NewFilesystemAdapter(t.TempDir())
+	ctx := context.Background()
+
+	tx, _ := fa.Begin(ctx)
+	op := &adapter.Operation{Statement: "orders/{id}.json"}
+	objects := []interface{}{map[string]interface{}{"id": "1"}}
+	if err := tx.Insert(ctx, op, objects); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if err := tx.Insert(ctx, op, objects); !errors.Is(err, adapter.ErrConflict) {
+		t.Errorf("Insert() of a staged file error = %v, want ErrConflict", err)
+	}
+}
+
+func TestFilesystemAdapter_Transaction_Upsert(t *testing.T) {
+	fa, _ := NewFilesystemAdapter(t.TempDir())
+	ctx := context.Background()
+
+	tx, _ := fa.Begin(ctx)
+	upserter, ok := tx.(adapter.Upserter)
+	if !ok {
+		t.Fatal("transaction does not implement adapter.Upserter")
+	}
+	op := &adapter.Operation{Statement: "orders/{id}.json"}
+	if err := upserter.Upsert(ctx, op, []interface{}{map[string]interface{}{"id": "1", "total": 10}}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if err := upserter.Upsert(ctx, op, []interface{}{map[string]interface{}{"id": "1", "total": 20}}); err != nil {
+		t.Fatalf("Upsert() of a staged file error = %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	stored, err := fa.fetchSingle("orders/1.json")
+	if err != nil || stored["total"] != float64(20) {
+		t.Errorf("stored = %v (err %v), want total 20", stored, err)
+	}
+}